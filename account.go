@@ -0,0 +1,220 @@
+package callosum
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+//The sources of the tweets of the authenticating account, recorded in the
+//`account_tweets` table.
+const (
+	SourceHome     = "home"
+	SourceLike     = "like"
+	SourceBookmark = "bookmark"
+)
+
+//accountPhases are the phases run by WithAccountOnly
+var accountPhases = []string{PhaseAccount}
+
+//WithAccountOnly collects only the home timeline and the likes of the
+//account the collector authenticates as, for personal archives that don't
+//crawl other users. It is the same as WithPhases(PhaseAccount). Bookmarks are
+//only available from Twitter's v2 API, see ImportBookmarks.
+func WithAccountOnly() Option {
+	return WithPhases(accountPhases...)
+}
+
+//accountAPI is implemented by APIs that can get the timelines of the
+//authenticating account
+type accountAPI interface {
+	GetAccount() (*User, error)
+	GetHomeTimeline(maxID int64) (Tweets, error)
+	GetLikes(screenNameOrID interface{}, maxID int64) (Tweets, error)
+}
+
+//GetAccount makes one API request to get the User the Network authenticates as.
+func (n *Network) GetAccount() (*User, error) {
+	var u *User
+
+	v := url.Values{}
+	v.Add("skip_status", "true")
+	data, err := n.get("account/verify_credentials", v)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &u)
+	if err != nil {
+		log.Fatal(err, data)
+	}
+	u.Blob = json.RawMessage(data)
+	return u, nil
+}
+
+//GetHomeTimeline makes one API request to the home timeline of the account
+//the Network authenticates as, the tweets of the accounts it follows, and
+//sets max_id like GetUserTimeline. Twitter only returns the latest 800
+//tweets of the home timeline.
+func (n *Network) GetHomeTimeline(maxID int64) (Tweets, error) {
+	v := url.Values{}
+	v.Add("count", "200")
+	if maxID != 0 {
+		v.Add("max_id", strconv.FormatInt(maxID-1, 10))
+	}
+	return n.getTweets("statuses/home_timeline", v)
+}
+
+//GetLikes makes one API request to the tweets screenNameOrID liked, most
+//recently liked first, and sets max_id like GetUserTimeline.
+func (n *Network) GetLikes(screenNameOrID interface{}, maxID int64) (Tweets, error) {
+	v := url.Values{}
+	n.addscreenNameOrID(&v, screenNameOrID)
+	v.Add("count", "200")
+	if maxID != 0 {
+		v.Add("max_id", strconv.FormatInt(maxID-1, 10))
+	}
+	return n.getTweets("favorites/list", v)
+}
+
+//CollectAccount collects the tweets of the account the collector
+//authenticates as, see CollectAccountTweets, as one collection round.
+func (t *TwitterCollector) CollectAccount() {
+	t.runPhase(PhaseAccount, t.CollectAccountTweets)
+}
+
+//CollectAccountTweets stores the account the collector authenticates as, and
+//the new tweets on its home timeline and the tweets it newly liked, with
+//their authors, like ImportJSONL does. The tweets are recorded in the
+//`account_tweets` table with their source, SourceHome or SourceLike, see
+//GetAccountTweets. A timeline is read until a tweet recorded before is
+//found. CollectAccountTweets returns the number of new tweets recorded, and
+//0 when the API can't get the account's timelines.
+func (t *TwitterCollector) CollectAccountTweets() int {
+	a, ok := t.n.(accountAPI)
+	if !ok {
+		return 0
+	}
+	t.countRequest(PhaseAccount, "account/verify_credentials")
+	account, err := a.GetAccount()
+	if err != nil {
+		log.Fatal(err)
+	}
+	t.storeUser(account)
+
+	stats := &ImportStats{}
+	seenUsers := map[int64]bool{account.ID: true}
+	var collected int
+	for _, timeline := range []struct {
+		source   string
+		endpoint string
+		get      func(maxID int64) (Tweets, error)
+	}{
+		{SourceHome, "statuses/home_timeline", a.GetHomeTimeline},
+		{SourceLike, "favorites/list", func(maxID int64) (Tweets, error) {
+			return a.GetLikes(account.ID, maxID)
+		}},
+	} {
+		source := timeline.source
+		var maxID int64
+		for {
+			t.waitForBudget()
+			t.waitForWriter()
+			t.countRequest(PhaseAccount, timeline.endpoint)
+			tweets, err := timeline.get(maxID)
+			if err != nil {
+				log.Fatal(err)
+			}
+			t.consume(len(tweets))
+			if len(tweets) == 0 {
+				break
+			}
+
+			var seen bool
+			for _, tweet := range tweets {
+				//likes are ordered by when they were liked, not by ID
+				if maxID == 0 || tweet.ID < maxID {
+					maxID = tweet.ID
+				}
+				if t.s.hasAccountTweet(account.ID, source, tweet.ID) {
+					seen = true
+					continue
+				}
+				t.importLine(tweet.Blob, stats, seenUsers)
+				t.s.StoreAccountTweet(account.ID, source, tweet.ID)
+				collected++
+			}
+			if seen {
+				break
+			}
+		}
+	}
+	if collected > 0 {
+		t.logger.Printf("collected %d tweets of account %d", collected, account.ID)
+	}
+	return collected
+}
+
+//ImportBookmarks stores the tweets bookmarked by the account accountID from
+//r, the response pages of the bookmarks endpoint of Twitter's v2 API, one
+//per line, as saved by twarc2 or any HTTP client. Bookmarks are private to
+//the account and v1.1 has no endpoint for them. The tweets are converted and
+//stored like ImportTwarc does and recorded in the `account_tweets` table as
+//SourceBookmark.
+func (t *TwitterCollector) ImportBookmarks(accountID int64, r io.Reader) (*ImportStats, error) {
+	stats := &ImportStats{}
+	defer t.analyzeImport(stats)
+	seenUsers := make(map[int64]bool)
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			for _, converted := range v2Lines(line) {
+				var record importRecord
+				if json.Unmarshal(converted, &record) == nil && record.ID != 0 {
+					t.s.StoreAccountTweet(accountID, SourceBookmark, record.ID)
+				}
+				t.importLine(converted, stats, seenUsers)
+			}
+			stats.Includes += t.s.storeIncludes(line)
+		}
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+	}
+}
+
+//StoreAccountTweet records the tweet in the `account_tweets` table as a
+//tweet of the account from the source.
+func (s *Storage) StoreAccountTweet(accountID int64, source string, tweetID int64) {
+	chQueryArgs <- &queryArgs{`INSERT OR IGNORE INTO account_tweets (account_id, source, tweet_id, collected_at)
+		VALUES (?, ?, ?, ?)`, []interface{}{accountID, source, tweetID, time.Now().Unix()}}
+}
+
+func (s *Storage) hasAccountTweet(accountID int64, source string, tweetID int64) bool {
+	var found int
+	err := s.db.QueryRow("SELECT 1 FROM account_tweets WHERE account_id=? AND source=? AND tweet_id=?",
+		accountID, source, tweetID).Scan(&found)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Fatal(err)
+	}
+	return found == 1
+}
+
+//GetAccountTweets gets the stored tweets of the account from the source,
+//SourceHome, SourceLike or SourceBookmark, most recently collected first.
+func (s *Storage) GetAccountTweets(accountID int64, source string) Tweets {
+	return s.queryTweets(`SELECT tweets.tweet_id, created_at, langugage, "desc", blob FROM account_tweets
+		JOIN tweets ON tweets.tweet_id = account_tweets.tweet_id
+		WHERE account_id=? AND source=?
+		ORDER BY collected_at DESC, account_tweets.rowid DESC`, accountID, source)
+}
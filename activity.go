@@ -0,0 +1,88 @@
+package callosum
+
+import (
+	"encoding/csv"
+	"io"
+	"log"
+	"strconv"
+	"time"
+)
+
+//Granularity is the length of the periods tweets are counted over
+type Granularity int
+
+//The granularities of an activity time series
+const (
+	Daily Granularity = iota
+	Weekly
+)
+
+//periodExpression returns the SQL expression for the start of the period
+//each tweet falls in, as a YYYY-MM-DD date. Weeks start on Monday.
+func (g Granularity) periodExpression() string {
+	switch g {
+	case Daily:
+		return "date(created_at, 'unixepoch')"
+	case Weekly:
+		return "date(created_at, 'unixepoch', 'weekday 0', '-6 days')"
+	}
+	log.Fatalf("unknown granularity %d", g)
+	return ""
+}
+
+//ActivityPoint is the number of tweets of a user in the period starting at Period
+type ActivityPoint struct {
+	UserID int64
+	Period time.Time
+	Tweets int
+}
+
+func (s *Storage) activity(g Granularity, where string, args ...interface{}) []ActivityPoint {
+	rows, err := s.db.Query(`
+		SELECT user_id, `+g.periodExpression()+` AS period, COUNT(*)
+		FROM tweets `+where+`
+		GROUP BY user_id, period
+		ORDER BY user_id, period`, args...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var points []ActivityPoint
+	for rows.Next() {
+		var p ActivityPoint
+		var period string
+		err = rows.Scan(&p.UserID, &period, &p.Tweets)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p.Period, err = time.Parse("2006-01-02", period)
+		if err != nil {
+			log.Fatal(err)
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+//Activity aggregates the stored tweets of userID into daily or weekly counts,
+//in chronological order. Periods without tweets are left out.
+func (s *Storage) Activity(userID int64, g Granularity) []ActivityPoint {
+	return s.activity(g, "WHERE user_id=?", userID)
+}
+
+//ExportActivityCSV writes the daily or weekly tweet counts of every user as
+//CSV with a user_id, period, tweets header.
+func (s *Storage) ExportActivityCSV(w io.Writer, g Granularity) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"user_id", "period", "tweets"})
+	for _, p := range s.activity(g, "") {
+		cw.Write([]string{
+			strconv.FormatInt(p.UserID, 10),
+			p.Period.Format("2006-01-02"),
+			strconv.Itoa(p.Tweets),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
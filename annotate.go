@@ -0,0 +1,89 @@
+package callosum
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+)
+
+//Annotator is any function that takes in a byte blob with twitter's JSON
+//response for a tweet and returns annotations for it, for example sentiment,
+//toxicity or named entity scores, keyed by name. Annotations are stored in
+//the `annotations` table.
+type Annotator func(blob []byte) map[string]interface{}
+
+//StoreAnnotations stores the annotations of the tweet in the `annotations`
+//table, replacing earlier annotations with the same key. Strings, numbers
+//and booleans are stored as they are, anything else as JSON.
+func (s *Storage) StoreAnnotations(tweetID int64, annotations map[string]interface{}) {
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := annotations[key]
+		switch value.(type) {
+		case string, bool, int, int64, float32, float64, nil:
+		default:
+			blob, err := json.Marshal(value)
+			if err != nil {
+				log.Fatal(err)
+			}
+			value = string(blob)
+		}
+		chQueryArgs <- &queryArgs{"INSERT OR REPLACE INTO annotations (tweet_id, key, value) VALUES (?, ?, ?)",
+			[]interface{}{tweetID, key, value}}
+	}
+}
+
+//AnnotateTweets runs the annotator over every stored tweet, as a batch pass
+//over tweets collected before the annotator was set with WithAnnotator, and
+//returns the number of tweets annotated.
+func (s *Storage) AnnotateTweets(a Annotator) int {
+	rows, err := s.db.Query("SELECT tweet_id, blob FROM tweets ORDER BY tweet_id")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var count int
+	for rows.Next() {
+		var tweetID int64
+		var blob []byte
+		err = rows.Scan(&tweetID, &blob)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if annotations := a(blob); len(annotations) > 0 {
+			s.StoreAnnotations(tweetID, annotations)
+			count++
+		}
+	}
+	return count
+}
+
+//GetAnnotations gets the annotations of a tweet from the `annotations` table
+func (s *Storage) GetAnnotations(tweetID int64) map[string]interface{} {
+	rows, err := s.db.Query("SELECT key, value FROM annotations WHERE tweet_id=?", tweetID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	annotations := make(map[string]interface{})
+	for rows.Next() {
+		var key string
+		var value interface{}
+		err = rows.Scan(&key, &value)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		annotations[key] = value
+	}
+	return annotations
+}
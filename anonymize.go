@@ -0,0 +1,327 @@
+package callosum
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+)
+
+//Anonymizer replaces user and tweet IDs and screen names with salted
+//pseudonyms and keeps only the fields of user and tweet blobs known not to
+//identify anyone, so a corpus can be shared under privacy constraints. The
+//same salt always gives the same pseudonyms, so follow edges, replies,
+//retweets and mentions still match across exports. Keep the salt secret,
+//anyone who has it can recompute the pseudonyms of known users.
+type Anonymizer struct {
+	salt []byte
+}
+
+//NewAnonymizer returns an Anonymizer using salt for its pseudonyms.
+func NewAnonymizer(salt []byte) *Anonymizer {
+	if len(salt) == 0 {
+		log.Fatal("anonymizer needs a salt")
+	}
+	return &Anonymizer{salt: salt}
+}
+
+//anonymizedUserFields are the fields kept in anonymized user blobs. All others,
+//like name, description, location, url and the profile images, are dropped.
+var anonymizedUserFields = map[string]bool{
+	"id": true, "id_str": true, "screen_name": true, "created_at": true,
+	"followers_count": true, "friends_count": true, "statuses_count": true,
+	"favourites_count": true, "listed_count": true, "lang": true,
+	"protected": true, "verified": true, "status": true,
+}
+
+//anonymizedTweetFields are the fields kept in anonymized tweet blobs, once
+//the IDs and screen names in them are replaced by pseudonyms. All others,
+//like the place, the coordinates, the source and permalinks, are dropped.
+var anonymizedTweetFields = map[string]bool{
+	"id": true, "id_str": true, "text": true, "full_text": true, "created_at": true,
+	"lang": true, "user": true, "truncated": true, "display_text_range": true,
+	"in_reply_to_status_id": true, "in_reply_to_status_id_str": true,
+	"in_reply_to_user_id": true, "in_reply_to_user_id_str": true, "in_reply_to_screen_name": true,
+	"is_quote_status": true, "quoted_status_id": true, "quoted_status_id_str": true,
+	"quoted_status": true, "retweeted_status": true, "extended_tweet": true, "entities": true,
+	"retweet_count": true, "favorite_count": true, "reply_count": true, "quote_count": true,
+	"conversation_id": true, "edit_history": true, "edit_history_tweet_ids": true,
+}
+
+//anonymizedExtendedFields are the fields kept in the extended_tweet of
+//anonymized tweet blobs
+var anonymizedExtendedFields = map[string]bool{"full_text": true, "display_text_range": true, "entities": true}
+
+//anonymizedEntities are the entities kept in anonymized tweet blobs. URLs and
+//media, which may link to identifying content, are dropped.
+var anonymizedEntities = map[string]bool{"hashtags": true, "symbols": true, "user_mentions": true}
+
+//anonymizedMentionFields are the fields kept in the user mentions of
+//anonymized tweet blobs
+var anonymizedMentionFields = map[string]bool{"id": true, "id_str": true, "screen_name": true, "indices": true}
+
+func (a *Anonymizer) pseudonym(kind string, key string) string {
+	h := hmac.New(sha256.New, a.salt)
+	h.Write([]byte(kind + ":" + key))
+	return kind + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+//UserPseudonym returns the pseudonym of the user ID. It stands in for both
+//the ID and the screen name of the user.
+func (a *Anonymizer) UserPseudonym(userID int64) string {
+	return a.pseudonym("u", strconv.FormatInt(userID, 10))
+}
+
+//TweetPseudonym returns the pseudonym of the tweet ID.
+func (a *Anonymizer) TweetPseudonym(tweetID int64) string {
+	return a.pseudonym("t", strconv.FormatInt(tweetID, 10))
+}
+
+//screenNamePseudonym is used for mentions of users whose ID is not known
+func (a *Anonymizer) screenNamePseudonym(screenName string) string {
+	return a.pseudonym("n", strings.ToLower(screenName))
+}
+
+//User returns the anonymized user blob.
+func (a *Anonymizer) User(blob []byte) ([]byte, error) {
+	var u map[string]interface{}
+	err := decodeBlob(blob, &u)
+	if err != nil {
+		return nil, err
+	}
+	a.anonymizeUser(u)
+	return json.Marshal(u)
+}
+
+//Tweet returns the anonymized tweet blob. Mentions in the text are replaced
+//by the pseudonyms of the mentioned users.
+func (a *Anonymizer) Tweet(blob []byte) ([]byte, error) {
+	var t map[string]interface{}
+	err := decodeBlob(blob, &t)
+	if err != nil {
+		return nil, err
+	}
+	a.anonymizeTweet(t)
+	return json.Marshal(t)
+}
+
+//decodeBlob decodes with UseNumber so that 64 bit IDs survive the round trip
+func decodeBlob(blob []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(blob))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+func blobID(v interface{}) (int64, bool) {
+	switch x := v.(type) {
+	case json.Number:
+		ID, err := x.Int64()
+		return ID, err == nil
+	case string:
+		ID, err := strconv.ParseInt(x, 10, 64)
+		return ID, err == nil
+	}
+	return 0, false
+}
+
+//keepFields deletes the fields of m that are not in fields
+func keepFields(m map[string]interface{}, fields map[string]bool) {
+	for key := range m {
+		if !fields[key] {
+			delete(m, key)
+		}
+	}
+}
+
+func (a *Anonymizer) anonymizeUser(u map[string]interface{}) {
+	keepFields(u, anonymizedUserFields)
+	ID, ok := blobID(u["id"])
+	if !ok {
+		ID, ok = blobID(u["id_str"])
+	}
+	if ok {
+		pseudonym := a.UserPseudonym(ID)
+		u["id"], u["id_str"], u["screen_name"] = pseudonym, pseudonym, pseudonym
+	} else {
+		delete(u, "id")
+		delete(u, "id_str")
+		delete(u, "screen_name")
+	}
+	if status, ok := u["status"].(map[string]interface{}); ok {
+		a.anonymizeTweet(status)
+	}
+}
+
+func (a *Anonymizer) anonymizeTweet(t map[string]interface{}) {
+	keepFields(t, anonymizedTweetFields)
+	a.replaceIDs(t, a.TweetPseudonym, "id", "id_str", "in_reply_to_status_id",
+		"in_reply_to_status_id_str", "quoted_status_id", "quoted_status_id_str", "conversation_id")
+	if IDs, ok := t["edit_history_tweet_ids"].([]interface{}); ok {
+		t["edit_history_tweet_ids"] = a.tweetPseudonyms(IDs)
+	} else {
+		delete(t, "edit_history_tweet_ids")
+	}
+	if history, ok := t["edit_history"].(map[string]interface{}); ok {
+		IDs, _ := history["edit_tweet_ids"].([]interface{})
+		anonymized := map[string]interface{}{"edit_tweet_ids": a.tweetPseudonyms(IDs)}
+		if ID, ok := blobID(history["initial_tweet_id"]); ok {
+			anonymized["initial_tweet_id"] = a.TweetPseudonym(ID)
+		}
+		t["edit_history"] = anonymized
+	} else {
+		delete(t, "edit_history")
+	}
+	if ID, ok := blobID(t["in_reply_to_user_id"]); ok {
+		t["in_reply_to_screen_name"] = a.UserPseudonym(ID)
+	} else {
+		delete(t, "in_reply_to_screen_name")
+	}
+	a.replaceIDs(t, a.UserPseudonym, "in_reply_to_user_id", "in_reply_to_user_id_str")
+	if u, ok := t["user"].(map[string]interface{}); ok {
+		a.anonymizeUser(u)
+	}
+	for _, key := range []string{"retweeted_status", "quoted_status"} {
+		if nested, ok := t[key].(map[string]interface{}); ok {
+			a.anonymizeTweet(nested)
+		}
+	}
+
+	mentions := a.anonymizeEntities(t)
+	if extended, ok := t["extended_tweet"].(map[string]interface{}); ok {
+		keepFields(extended, anonymizedExtendedFields)
+		for screenName, pseudonym := range a.anonymizeEntities(extended) {
+			mentions[screenName] = pseudonym
+		}
+		a.anonymizeText(extended, "full_text", mentions)
+	}
+	a.anonymizeText(t, "text", mentions)
+	a.anonymizeText(t, "full_text", mentions)
+}
+
+//tweetPseudonyms returns the pseudonyms of the tweet IDs, leaving out the
+//values that are not IDs
+func (a *Anonymizer) tweetPseudonyms(IDs []interface{}) []interface{} {
+	pseudonyms := []interface{}{}
+	for _, x := range IDs {
+		if ID, ok := blobID(x); ok {
+			pseudonyms = append(pseudonyms, a.TweetPseudonym(ID))
+		}
+	}
+	return pseudonyms
+}
+
+func (a *Anonymizer) replaceIDs(m map[string]interface{}, pseudonym func(int64) string, keys ...string) {
+	for _, key := range keys {
+		if ID, ok := blobID(m[key]); ok {
+			m[key] = pseudonym(ID)
+		} else if m[key] != nil {
+			delete(m, key)
+		}
+	}
+}
+
+//anonymizeEntities replaces the mentioned users with their pseudonyms, keeps
+//only anonymizedEntities and returns the pseudonyms by lowercased screen name
+//for rewriting the text.
+func (a *Anonymizer) anonymizeEntities(m map[string]interface{}) map[string]string {
+	mentions := make(map[string]string)
+	entities, ok := m["entities"].(map[string]interface{})
+	if !ok {
+		delete(m, "entities")
+		return mentions
+	}
+	keepFields(entities, anonymizedEntities)
+	userMentions, _ := entities["user_mentions"].([]interface{})
+	for _, x := range userMentions {
+		mention, ok := x.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		screenName, _ := mention["screen_name"].(string)
+		ID, ok := blobID(mention["id"])
+		if !ok {
+			ID, ok = blobID(mention["id_str"])
+		}
+		var pseudonym string
+		if ok {
+			pseudonym = a.UserPseudonym(ID)
+		} else {
+			pseudonym = a.screenNamePseudonym(screenName)
+		}
+		mentions[strings.ToLower(screenName)] = pseudonym
+		keepFields(mention, anonymizedMentionFields)
+		mention["id"], mention["id_str"], mention["screen_name"] = pseudonym, pseudonym, pseudonym
+	}
+	return mentions
+}
+
+func (a *Anonymizer) anonymizeText(m map[string]interface{}, key string, mentions map[string]string) {
+	text, ok := m[key].(string)
+	if !ok {
+		return
+	}
+	text = urlPattern.ReplaceAllString(text, "https://t.co/")
+	m[key] = mentionPattern.ReplaceAllStringFunc(text, func(mention string) string {
+		if pseudonym, ok := mentions[strings.ToLower(mention[1:])]; ok {
+			return "@" + pseudonym
+		}
+		return "@" + a.screenNamePseudonym(mention[1:])
+	})
+}
+
+//AnonymizingExporter anonymizes users and tweets before handing them to
+//another Exporter, for example:
+//
+//	e := callosum.NewAnonymizingExporter(callosum.NewJSONLExporter("export"), salt)
+//	t := callosum.NewTwitterCollector(callosum.WithExporter(e))
+type AnonymizingExporter struct {
+	Exporter
+	*Anonymizer
+}
+
+//NewAnonymizingExporter returns an AnonymizingExporter in front of e.
+func NewAnonymizingExporter(e Exporter, salt []byte) *AnonymizingExporter {
+	return &AnonymizingExporter{e, NewAnonymizer(salt)}
+}
+
+//ExportUser exports the anonymized user blob. Blobs that can not be decoded are dropped.
+func (e *AnonymizingExporter) ExportUser(blob []byte) {
+	blob, err := e.User(blob)
+	if err != nil {
+		log.Printf("anonymizing user: %s", err)
+		return
+	}
+	e.Exporter.ExportUser(blob)
+}
+
+//ExportTweet exports the anonymized tweet blob. Blobs that can not be decoded are dropped.
+func (e *AnonymizingExporter) ExportTweet(blob []byte) {
+	blob, err := e.Tweet(blob)
+	if err != nil {
+		log.Printf("anonymizing tweet: %s", err)
+		return
+	}
+	e.Exporter.ExportTweet(blob)
+}
+
+//ExportAnonymizedEdges writes every stored follow edge to w as CSV with the
+//users replaced by their pseudonyms, so the graph can be shared along with
+//an anonymized export.
+func (s *Storage) ExportAnonymizedEdges(w io.Writer, a *Anonymizer) error {
+	g := s.FullGraph()
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"follower", "followed"})
+	for _, from := range g.NodeIDs() {
+		for _, to := range g.Out[from] {
+			cw.Write([]string{a.UserPseudonym(from), a.UserPseudonym(to)})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
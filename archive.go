@@ -0,0 +1,262 @@
+package callosum
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ImportStats counts what an import stored. Records already in the database
+//are counted too, they are deduplicated by ID when stored.
+type ImportStats struct {
+	Users     int
+	Tweets    int
+	Following int
+	Followers int
+	//Includes counts the expanded objects of v2 response pages stored by
+	//ImportTwarc.
+	Includes int
+	//Skipped counts the records that could not be parsed.
+	Skipped int
+}
+
+//archiveAccount is the account.js entry of a Twitter archive
+type archiveAccount struct {
+	AccountID          string `json:"accountId"`
+	Username           string `json:"username"`
+	AccountDisplayName string `json:"accountDisplayName"`
+	CreatedAt          string `json:"createdAt"`
+}
+
+//archiveProfile is the profile.js entry of a Twitter archive
+type archiveProfile struct {
+	Description struct {
+		Bio      string `json:"bio"`
+		Website  string `json:"website"`
+		Location string `json:"location"`
+	} `json:"description"`
+}
+
+//archiveIDFields are the fields of archived tweets holding IDs or counts as
+//strings, which are turned into numbers to match the API's tweet objects.
+var archiveIDFields = []string{"id", "in_reply_to_status_id", "in_reply_to_user_id",
+	"quoted_status_id", "retweet_count", "favorite_count"}
+
+//ImportArchive loads the account, tweets, following and followers of the
+//official Twitter data export in the ZIP file into the database, so that
+//personal archives can seed or supplement a corpus. The account is stored
+//and filtered like a collected user, unless it is already stored, in which
+//case it is left as it is, and the accounts it follows and is
+//followed by are stored as user IDs to be collected. Archived tweets are
+//converted to the API's tweet objects as far as the archive allows, they
+//carry no user object other than the ID. Large imports are followed by
+//Analyze.
+func (t *TwitterCollector) ImportArchive(fileName string) (*ImportStats, error) {
+	r, err := zip.OpenReader(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	files := make(map[string]*zip.File)
+	var names []string
+	for _, f := range r.File {
+		files[f.Name] = f
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	//parts of a file after the first are named like data/tweets-part1.js
+	parts := func(name string) []*zip.File {
+		var matched []*zip.File
+		for _, n := range names {
+			base := path.Base(n)
+			if path.Base(path.Dir(n)) == "data" && (base == name+".js" || strings.HasPrefix(base, name+"-part")) {
+				matched = append(matched, files[n])
+			}
+		}
+		return matched
+	}
+
+	var accounts []struct {
+		Account archiveAccount `json:"account"`
+	}
+	err = readArchiveFiles(parts("account"), &accounts)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("%s: no data/account.js, not a Twitter archive", fileName)
+	}
+	account := accounts[0].Account
+	userID, err := strconv.ParseInt(account.AccountID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: account ID: %s", fileName, err)
+	}
+
+	var profiles []struct {
+		Profile archiveProfile `json:"profile"`
+	}
+	err = readArchiveFiles(parts("profile"), &profiles)
+	if err != nil {
+		return nil, err
+	}
+	var profile archiveProfile
+	if len(profiles) > 0 {
+		profile = profiles[0].Profile
+	}
+
+	stats := &ImportStats{}
+	defer t.analyzeImport(stats)
+	u := &User{
+		ID:          userID,
+		Name:        account.AccountDisplayName,
+		ScreenName:  account.Username,
+		Description: profile.Description.Bio,
+	}
+	u.Blob = mustMarshal(map[string]interface{}{
+		"id":          userID,
+		"id_str":      account.AccountID,
+		"name":        account.AccountDisplayName,
+		"screen_name": account.Username,
+		"description": profile.Description.Bio,
+		"location":    profile.Description.Location,
+		"url":         profile.Description.Website,
+		"created_at":  account.CreatedAt,
+	})
+	t.importUser(u)
+	stats.Users++
+
+	//older archives name the file tweet.js
+	var tweets []struct {
+		Tweet map[string]interface{} `json:"tweet"`
+	}
+	err = readArchiveFiles(append(parts("tweets"), parts("tweet")...), &tweets)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range tweets {
+		tweet, ok := archiveTweet(entry.Tweet, userID)
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+		t.storeTweet(userID, tweet)
+		stats.Tweets++
+	}
+
+	var following []struct {
+		Following struct {
+			AccountID string `json:"accountId"`
+		} `json:"following"`
+	}
+	err = readArchiveFiles(parts("following"), &following)
+	if err != nil {
+		return nil, err
+	}
+	var friendIDs []int64
+	for _, f := range following {
+		if ID, err := strconv.ParseInt(f.Following.AccountID, 10, 64); err == nil {
+			friendIDs = append(friendIDs, ID)
+		} else {
+			stats.Skipped++
+		}
+	}
+	t.s.StoreFriends(userID, friendIDs)
+	t.s.StoreUserIDs(friendIDs)
+	stats.Following = len(friendIDs)
+
+	var followers []struct {
+		Follower struct {
+			AccountID string `json:"accountId"`
+		} `json:"follower"`
+	}
+	err = readArchiveFiles(parts("follower"), &followers)
+	if err != nil {
+		return nil, err
+	}
+	var followerIDs []int64
+	for _, f := range followers {
+		if ID, err := strconv.ParseInt(f.Follower.AccountID, 10, 64); err == nil {
+			followerIDs = append(followerIDs, ID)
+		} else {
+			stats.Skipped++
+		}
+	}
+	t.s.StoreFollowers(userID, followerIDs)
+	t.s.StoreUserIDs(followerIDs)
+	stats.Followers = len(followerIDs)
+
+	return stats, nil
+}
+
+//readArchiveFiles decodes the JSON arrays in the archive's .js files into
+//v, a pointer to a slice, appending the arrays of all the files. The files
+//assign the array to a variable, as in window.YTD.tweets.part0 = [...].
+func readArchiveFiles(files []*zip.File, v interface{}) error {
+	var all []json.RawMessage
+	for _, f := range files {
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if i := bytes.IndexByte(data, '='); i >= 0 && bytes.IndexByte(data, '[') > i {
+			data = data[i+1:]
+		}
+		var entries []json.RawMessage
+		err = json.Unmarshal(data, &entries)
+		if err != nil {
+			return fmt.Errorf("%s: %s", f.Name, err)
+		}
+		all = append(all, entries...)
+	}
+	return json.Unmarshal(mustMarshal(all), v)
+}
+
+//archiveTweet converts an archived tweet to the API's tweet object
+func archiveTweet(archived map[string]interface{}, userID int64) (*Tweet, bool) {
+	for _, key := range archiveIDFields {
+		if s, ok := archived[key].(string); ok {
+			archived[key] = json.Number(s)
+		}
+	}
+	if entities, ok := archived["entities"].(map[string]interface{}); ok {
+		mentions, _ := entities["user_mentions"].([]interface{})
+		for _, m := range mentions {
+			if mention, ok := m.(map[string]interface{}); ok {
+				if s, ok := mention["id"].(string); ok {
+					mention["id"] = json.Number(s)
+				}
+			}
+		}
+	}
+	if _, ok := archived["text"]; !ok {
+		archived["text"] = archived["full_text"]
+	}
+	archived["user"] = map[string]interface{}{"id": userID, "id_str": strconv.FormatInt(userID, 10)}
+
+	blob, err := json.Marshal(archived)
+	if err != nil {
+		return nil, false
+	}
+	tweet := &Tweet{Blob: blob}
+	err = json.Unmarshal(blob, tweet)
+	if err != nil || tweet.ID == 0 {
+		return nil, false
+	}
+	if _, err := time.Parse(time.RubyDate, tweet.CreatedAt); err != nil {
+		return nil, false
+	}
+	return tweet, true
+}
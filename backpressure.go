@@ -0,0 +1,37 @@
+package callosum
+
+import (
+	"time"
+)
+
+//writeQueueSize is the number of statements each lane of the writer queues
+//up before the goroutines storing them block
+const writeQueueSize = 100
+
+//backpressurePoll is how often the collector checks whether the writer
+//caught up while it holds off API requests
+const backpressurePoll = 100 * time.Millisecond
+
+//Backpressure reports whether more than watermark statements and checkpoint
+//updates are queued up for the writer, the signal that SQLite can't keep up
+//with the collection.
+func (s *Storage) Backpressure(watermark int) bool {
+	queued, _ := s.WriterStatus()
+	return queued > watermark
+}
+
+//waitForWriter holds off the next API request while the writer has more
+//than the write watermark queued up, until it is down to half of it, see
+//WithWriteWatermark. Collecting IDs and tweets faster than they are written
+//otherwise keeps growing the pages held in memory by the waiting
+//goroutines.
+func (t *TwitterCollector) waitForWriter() {
+	if t.writeWatermark <= 0 || !t.s.Backpressure(t.writeWatermark) {
+		return
+	}
+	start := time.Now()
+	for t.s.Backpressure(t.writeWatermark / 2) {
+		time.Sleep(backpressurePoll)
+	}
+	t.logger.Printf("held off API requests for %s while the writer caught up", time.Since(start).Round(time.Millisecond))
+}
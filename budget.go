@@ -0,0 +1,116 @@
+package callosum
+
+import (
+	"sync"
+	"time"
+)
+
+//MonthlyCap limits the tweets read from Twitter's API each calendar month,
+//in UTC, as Twitter caps the tweets a v2 project can consume per month and
+//locks projects out that go over. The tweets read are counted in the
+//`tweet_consumption` table whether a cap is set or not.
+type MonthlyCap struct {
+	//Tweets is the number of tweets that can be read in a month. Zero means no limit.
+	Tweets int
+	//WarnAt is the fraction of Tweets at which a warning is logged, defaults to DefaultCapWarnAt.
+	WarnAt float64
+	//PauseAt is the fraction of Tweets at which reading tweets pauses until
+	//the next month, defaults to DefaultCapPauseAt.
+	PauseAt float64
+}
+
+//The fractions of the monthly cap used when none are given
+const (
+	DefaultCapWarnAt  = 0.8
+	DefaultCapPauseAt = 0.95
+)
+
+//budget keeps track of the tweets read in the current month
+type budget struct {
+	mutex    sync.Mutex
+	month    string
+	consumed int
+	warned   bool
+}
+
+//month returns the calendar month of at, in UTC, as used in the `tweet_consumption` table
+func month(at time.Time) string {
+	return at.UTC().Format("2006-01")
+}
+
+//refreshBudget reads the tweets consumed so far from the database when the
+//month changed. The budget mutex must be held.
+func (t *TwitterCollector) refreshBudget() {
+	if m := month(time.Now()); m != t.budget.month {
+		t.budget.month = m
+		t.budget.consumed = t.s.GetTweetConsumption(m)
+		t.budget.warned = false
+	}
+}
+
+//consume counts tweets read from Twitter's API against the monthly cap
+func (t *TwitterCollector) consume(tweets int) {
+	if tweets == 0 {
+		return
+	}
+	t.budget.mutex.Lock()
+	defer t.budget.mutex.Unlock()
+	t.refreshBudget()
+	t.budget.consumed += tweets
+	t.s.AddTweetConsumption(t.budget.month, tweets)
+
+	c := t.monthlyCap
+	if c.Tweets > 0 && !t.budget.warned && float64(t.budget.consumed) >= c.WarnAt*float64(c.Tweets) {
+		t.budget.warned = true
+		t.logger.Printf("read %d of the %d tweets the monthly cap allows", t.budget.consumed, c.Tweets)
+	}
+}
+
+//waitForBudget blocks until tweets can be read without getting close to
+//the monthly cap, pausing until the next month when needed, or until the
+//collection is complete.
+func (t *TwitterCollector) waitForBudget() {
+	c := t.monthlyCap
+	if c.Tweets == 0 {
+		return
+	}
+	for {
+		consumed, paused := t.budgetPaused()
+		if !paused {
+			return
+		}
+
+		now := time.Now().UTC()
+		nextMonth := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		t.logger.Printf("pausing until %s, read %d of the %d tweets the monthly cap allows",
+			nextMonth.Format("2006-01-02"), consumed, c.Tweets)
+		select {
+		case <-t.done:
+			return
+		case <-time.After(nextMonth.Sub(now)):
+		}
+	}
+}
+
+//budgetPaused returns the tweets read this month, and whether reading tweets
+//is paused until the next month as they got close to the monthly cap.
+func (t *TwitterCollector) budgetPaused() (int, bool) {
+	c := t.monthlyCap
+	t.budget.mutex.Lock()
+	defer t.budget.mutex.Unlock()
+	t.refreshBudget()
+	return t.budget.consumed, c.Tweets > 0 && float64(t.budget.consumed) >= c.PauseAt*float64(c.Tweets)
+}
+
+//AddTweetConsumption adds to the number of tweets read from Twitter's API
+//in the month, formatted as 2006-01, in the `tweet_consumption` table.
+func (s *Storage) AddTweetConsumption(month string, tweets int) {
+	chQueryArgs <- &queryArgs{`INSERT INTO tweet_consumption (month, tweets) VALUES (?, ?)
+		ON CONFLICT (month) DO UPDATE SET tweets=tweets+excluded.tweets`, []interface{}{month, tweets}}
+}
+
+//GetTweetConsumption gets the number of tweets read from Twitter's API in
+//the month, formatted as 2006-01.
+func (s *Storage) GetTweetConsumption(month string) int {
+	return s.count("SELECT COALESCE(SUM(tweets), 0) FROM tweet_consumption WHERE month=?", month)
+}
@@ -5,9 +5,15 @@
 // up from where it left off.
 package callosum
 
-import "time"
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
-type listGetter func(interface{}, int64) ([]int64, int64)
+type listGetter func(interface{}, int64) ([]int64, int64, error)
 
 //FilterUser is any function that takes in a byte blob with twitter's JSON response
 //for a user and returns true if the user matches the filtering criteria. A true will
@@ -36,38 +42,123 @@ func trimTillID(IDs []int64, seenID int64) ([]int64, bool) {
 //
 //Collect* methods both get the objects and also write them to the database.
 type TwitterCollector struct {
-	n          *Network
-	s          *Storage
-	filterUser FilterUser
+	n           API
+	s           *Storage
+	filterUser  FilterUser
+	tierUser    TierUser
+	logger      *log.Logger
+	concurrency int
+	intervals   Intervals
+	phases      map[string]bool
+	caps        Caps
+	exporter    Exporter
+	annotator   Annotator
+
+	backupStore    ObjectStore
+	backupInterval time.Duration
+
+	maintenanceInterval time.Duration
+
+	protectedRecheckInterval time.Duration
+	unavailableAfter         int
+
+	writeWatermark  int
+	lookupChunkSize int
+
+	tailInterval time.Duration
+
+	monthlyCap MonthlyCap
+	budget     budget
+
+	run   string
+	usage usage
+
+	healthAddr  string
+	credentials credentialsCheck
+
+	progress       progress
+	done           chan struct{}
+	rounds         sync.WaitGroup
+	completionHook func()
 }
 
-//NewTwitterCollector returns a new Twitter Collector.
-//
-//A sqlite database is created with DBName as the file name.
+//NewTwitterCollector returns a new Twitter Collector configured with the given options.
 //
-//authFileName and window are parameters needed for using Twitter's API.
+//WithStorage sets the sqlite database the users and tweets are collected into.
 //
-//authFileName contains the authentication tokens you get from Twitter's
-//Application Management portal - apps.twitter.com, Checkout template_auth.json for the format
+//WithNetwork sets up the use of Twitter's API. NewNetwork needs the file with the
+//authentication tokens you get from Twitter's Application Management portal - apps.twitter.com,
+//Checkout template_auth.json for the format, and Twitter's rate limit rollover window.
 //
-//window specifies Twitter's rate limit rollover window
-//
-//fu specifies a filter function that takes the byte blob with Twitter's JSON response for a user object lookup
+//WithFilter specifies a filter function that takes the byte blob with Twitter's JSON response for a user object lookup
 //and returns true if the user meets the criteron to follow up to get their tweets and their friends and followers.
-func NewTwitterCollector(DBName, authFileName string, window time.Duration, fu FilterUser) *TwitterCollector {
-	t := &TwitterCollector{}
-	t.n = NewNetwork(authFileName, window)
-	t.s = NewStorage(DBName)
-	t.filterUser = fu
+//
+//Options that are not given fall back to their defaults, see the With* functions.
+func NewTwitterCollector(opts ...Option) *TwitterCollector {
+	t := &TwitterCollector{
+		filterUser:       AcceptAll,
+		concurrency:      1,
+		unavailableAfter: DefaultUnavailableAfter,
+		writeWatermark:   DefaultWriteWatermark,
+		lookupChunkSize:  DefaultLookupChunkSize,
+		intervals:        DefaultIntervals,
+		run:              time.Now().UTC().Format("20060102T150405Z"),
+		done:             make(chan struct{}),
+	}
+	WithPhases(allPhases...)(t)
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.logger == nil {
+		t.logger = defaultLogger()
+	}
+	if t.s == nil {
+		t.s = NewStorage(DefaultDBName)
+	}
+	if t.n == nil {
+		t.n = NewNetwork(DefaultAuthFileName, DefaultWindow)
+	}
 	return t
 }
 
-func (t *TwitterCollector) getRelatedUsers(screenNameOrID interface{}, getter listGetter, lastUserID int64) []int64 {
+//Storage returns the Storage the collector writes to, for example to run
+//the analysis utilities over the collected corpus.
+func (t *TwitterCollector) Storage() *Storage {
+	return t.s
+}
+
+//eachUser calls collect for every userID, running up to t.concurrency
+//calls at the same time, and returns the sum of what the calls returned.
+func (t *TwitterCollector) eachUser(userIDs []int64, collect func(userID int64) int) int {
+	sem := make(chan struct{}, t.concurrency)
+	var wg sync.WaitGroup
+	var total int64
+	for _, userID := range userIDs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(userID int64) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			atomic.AddInt64(&total, int64(collect(userID)))
+		}(userID)
+	}
+	wg.Wait()
+	return int(total)
+}
+
+func (t *TwitterCollector) getRelatedUsers(screenNameOrID interface{}, getter listGetter, lastUserID int64) ([]int64, error) {
 	var cursorID int64 = -1
 	var userIDs []int64
 	for {
 		var IDs []int64
-		IDs, cursorID = getter(screenNameOrID, cursorID)
+		var err error
+		t.waitForWriter()
+		IDs, cursorID, err = getter(screenNameOrID, cursorID)
+		if err != nil {
+			return userIDs, err
+		}
 		if len(IDs) == 0 {
 			break
 		}
@@ -77,17 +168,41 @@ func (t *TwitterCollector) getRelatedUsers(screenNameOrID interface{}, getter li
 			break
 		}
 	}
-	return userIDs
+	return userIDs, nil
 }
 
 //GetTweets gets all the Tweets from the timeline for a given screenNameOrID, starting from the latestTweetID.
 //set latestTweetID to 0 to get all Tweets constrained by Twitter's max. limit
-func (t *TwitterCollector) GetTweets(screenNameOrID interface{}, latestTweetID int64) Tweets {
+//and the MaxTweetsPerUser cap.
+//
+//GetTweets holds all the tweets in memory, use StreamTweets to handle them a page at a time.
+func (t *TwitterCollector) GetTweets(screenNameOrID interface{}, latestTweetID int64) (Tweets, error) {
 	var allTweets Tweets
+	_, err := t.StreamTweets(screenNameOrID, latestTweetID, func(tweets Tweets) {
+		allTweets = append(allTweets, tweets...)
+	})
+	return allTweets, err
+}
+
+//StreamTweets gets the same Tweets as GetTweets but calls handle with each page
+//of Tweets as it arrives from Twitter instead of accumulating them, so that memory
+//use is bounded by the page size. Every page read counts against the monthly
+//cap, see WithMonthlyCap. Pages are handed over from the most recent to the
+//least recent tweet. StreamTweets returns the number of tweets handled, also
+//when it fails part way.
+func (t *TwitterCollector) StreamTweets(screenNameOrID interface{}, latestTweetID int64, handle func(Tweets)) (int, error) {
+	var count int
 	var maxID int64
 
 	for {
-		tweets := t.n.GetUserTimeline(screenNameOrID, maxID)
+		t.waitForBudget()
+		t.waitForWriter()
+		t.countRequest(PhaseTweets, "statuses/user_timeline")
+		tweets, err := t.n.GetUserTimeline(screenNameOrID, maxID)
+		if err != nil {
+			return count, err
+		}
+		t.consume(len(tweets))
 
 		if len(tweets) == 0 {
 			break
@@ -95,73 +210,257 @@ func (t *TwitterCollector) GetTweets(screenNameOrID interface{}, latestTweetID i
 
 		maxID = tweets[len(tweets)-1].ID //the array is sorted from most recent to least recent tweet
 		tweets = tweets.trimTillID(latestTweetID)
-		allTweets = append(allTweets, tweets...)
 
-		if !(maxID > latestTweetID) {
+		capped := t.caps.MaxTweetsPerUser > 0 && count+len(tweets) >= t.caps.MaxTweetsPerUser
+		if capped {
+			tweets = tweets[:t.caps.MaxTweetsPerUser-count]
+		}
+		if len(tweets) > 0 {
+			handle(tweets)
+			count += len(tweets)
+		}
+
+		if capped || !(maxID > latestTweetID) {
 			break
 		}
 	}
-	return allTweets
+	return count, nil
 }
 
 //GetFriends gets the IDs of all Twitter users screenNameOrID is following, stopping at latestFriendID.
 //set latestFriendID to 0 to get all the friends.
-func (t *TwitterCollector) GetFriends(screenNameOrID interface{}, latestFriendID int64) []int64 {
-	return t.getRelatedUsers(screenNameOrID, t.n.GetFriendIDs, latestFriendID)
+func (t *TwitterCollector) GetFriends(screenNameOrID interface{}, latestFriendID int64) ([]int64, error) {
+	return t.getRelatedUsers(screenNameOrID, t.countingGetter(PhaseFriends, "friends/ids", t.n.GetFriendIDs), latestFriendID)
 }
 
 //GetFollowers gets the IDs of Twitter users following screenNameOrID, stopping at latestFollowerID.
 //set latestFollowerID to 0 to get all followers
-func (t *TwitterCollector) GetFollowers(screenNameOrID interface{}, latestFollowerID int64) []int64 {
-	return t.getRelatedUsers(screenNameOrID, t.n.GetFollowerIDs, latestFollowerID)
+func (t *TwitterCollector) GetFollowers(screenNameOrID interface{}, latestFollowerID int64) ([]int64, error) {
+	return t.getRelatedUsers(screenNameOrID, t.countingGetter(PhaseFollowers, "followers/ids", t.n.GetFollowerIDs), latestFollowerID)
+}
+
+//SkipReasonProtected is recorded in the `skip_reason` column for users
+//whose tweets, friends and followers can not be collected because their
+//account is protected.
+const SkipReasonProtected = "protected"
+
+//SkipReasonNotFound is recorded in the `skip_reason` column for users
+//Twitter no longer knows, because the account was deleted or suspended.
+const SkipReasonNotFound = "not found"
+
+//SkipReasonUnavailable is recorded in the `skip_reason` column for users
+//Twitter refused the collection for too many times in a row, see
+//WithUnavailableAfter. They are not collected again.
+const SkipReasonUnavailable = "unavailable"
+
+//skipProtected reports whether userID is stored as a protected or an
+//unavailable user, and if so records why the collection for the user is
+//skipped. The skip is counted as an attempt, see MarkUserAttempt.
+func (t *TwitterCollector) skipProtected(userID int64, phase string) bool {
+	u := t.s.GetUserByScreenNameOrID(userID)
+	if u == nil {
+		return false
+	}
+	if u.SkipReason == SkipReasonUnavailable {
+		t.s.MarkUserAttempt(userID, phase, time.Now().Unix(), errors.New("skipped unavailable user"))
+		t.logger.Printf("skipping %s of unavailable user %d", phase, userID)
+		return true
+	}
+	if u.Protected == 0 {
+		return false
+	}
+	if u.SkipReason != SkipReasonProtected {
+		t.s.MarkUserSkipped(userID, SkipReasonProtected)
+	}
+	t.s.MarkUserAttempt(userID, phase, time.Now().Unix(), errors.New("skipped protected user"))
+	t.logger.Printf("skipping %s of protected user %d", phase, userID)
+	return true
+}
+
+//skipUnavailable reports whether err is Twitter refusing the collection for
+//userID because the account is protected or doesn't exist, and if so records
+//why the collection for the user is skipped. Refusals in a row are counted by
+//phase in the `user_failures` table, so that one bad round of the tweets,
+//friends and followers phases counts once, and once a phase has
+//unavailableAfter of them the user is marked unavailable. Users refused as
+//protected are marked protected, for RecheckProtectedUsers to find them when
+//they make their account public. When the rate limit is used up, the user is
+//skipped without counting a refusal, the next request waits for the window
+//and the user is collected again by the next round. Other errors are fatal. Every call is counted as an
+//attempt at collecting the user, see MarkUserAttempt.
+func (t *TwitterCollector) skipUnavailable(userID int64, phase string, err error) bool {
+	t.s.MarkUserAttempt(userID, phase, time.Now().Unix(), err)
+	var reason string
+	switch {
+	case err == nil:
+		if t.s.GetUserFailures(userID, phase) > 0 {
+			t.s.MarkUserFailures(userID, phase, 0)
+		}
+		return false
+	case errors.Is(err, ErrProtected):
+		reason = SkipReasonProtected
+		t.s.MarkUserProtected(userID)
+	case errors.Is(err, ErrNotFound):
+		reason = SkipReasonNotFound
+	case errors.Is(err, ErrRateLimited):
+		t.logger.Printf("skipping %s of user %d until the next round: %s", phase, userID, err)
+		return true
+	default:
+		log.Fatal(err)
+	}
+	failures := t.s.GetUserFailures(userID, phase) + 1
+	if t.unavailableAfter > 0 && failures >= t.unavailableAfter {
+		reason = SkipReasonUnavailable
+	}
+	t.s.MarkUserFailures(userID, phase, failures)
+	t.s.MarkUserSkipped(userID, reason)
+	t.logger.Printf("skipping %s of user %d: %s", phase, userID, err)
+	if reason == SkipReasonUnavailable {
+		t.logger.Printf("marked user %d unavailable after %d failures of %s in a row", userID, failures, phase)
+	}
+	return true
 }
 
 //CollectFriends gets all Twitter users that userID is following, stopping at latestFriendID
 //and stores the mapping between the userID and the friendID for all friends in the
 //`following` table, addes the followingIDs to the queue of users ids to be processed,
 //in the `userids` table and updates the `latest_following_id` column in the `users` table.
-func (t *TwitterCollector) CollectFriends(userID int64, latestFriendID int64) {
-	friends := t.GetFriends(userID, latestFriendID)
-	t.s.StoreFriends(userID, friends)
-	t.s.StoreUserIDs(friends)
-	t.s.MarkUserLatestFriendsCollected(userID, latestFriendID)
+//The friends are stored a page at a time and a collection cut short resumes at the page
+//it stopped at, see collectRelatedUsers.
+//Protected users, and users Twitter no longer knows, are skipped and the reason is recorded in the `skip_reason` column.
+//CollectFriends returns the number of new friends collected.
+func (t *TwitterCollector) CollectFriends(userID int64, latestFriendID int64) int {
+	if t.skipProtected(userID, PhaseFriends) {
+		return 0
+	}
+	count, err := t.collectRelatedUsers(userID, PhaseFriends, t.countingGetter(PhaseFriends, "friends/ids", t.n.GetFriendIDs), latestFriendID, func(friends []int64) {
+		t.s.StoreFriends(userID, friends)
+		t.s.StoreUserIDs(friends)
+	}, t.s.MarkUserLatestFriendsCollected)
+	t.s.AddUserCollected(userID, 0, count)
+	if t.skipUnavailable(userID, PhaseFriends, err) {
+		return 0
+	}
+	t.logger.Printf("collected %d friends of user %d", count, userID)
+	return count
 }
 
 //CollectFollowers gets all Twitter followers of userID, stopping at latestFollowerID
 //and stores the mapping between the userID and the follower for all followers in the
 //`followers` table, adds the follower IDs to the queue of user ids to be processed,
 //in the `userids` table and updates the `latest_follower_id` column  in the `users` table.
-func (t *TwitterCollector) CollectFollowers(userID int64, latestFollowerID int64) {
-	followers := t.GetFollowers(userID, latestFollowerID)
-	t.s.StoreFollowers(userID, followers)
-	t.s.StoreUserIDs(followers)
-	t.s.MarkUserLatestFollowersCollected(userID, latestFollowerID)
+//The followers are stored a page at a time and a collection cut short resumes at the page
+//it stopped at, see collectRelatedUsers.
+//Protected users, and users Twitter no longer knows, are skipped and the reason is recorded in the `skip_reason` column.
+//CollectFollowers returns the number of new followers collected.
+func (t *TwitterCollector) CollectFollowers(userID int64, latestFollowerID int64) int {
+	if t.skipProtected(userID, PhaseFollowers) {
+		return 0
+	}
+	count, err := t.collectRelatedUsers(userID, PhaseFollowers, t.countingGetter(PhaseFollowers, "followers/ids", t.n.GetFollowerIDs), latestFollowerID, func(followers []int64) {
+		t.s.StoreFollowers(userID, followers)
+		t.s.StoreUserIDs(followers)
+	}, t.s.MarkUserLatestFollowersCollected)
+	t.s.AddUserCollected(userID, 0, count)
+	if t.skipUnavailable(userID, PhaseFollowers, err) {
+		return 0
+	}
+	t.logger.Printf("collected %d followers of user %d", count, userID)
+	return count
 }
 
 //CollectUser gets the user from Twitter for the given screenNameOrID and stores
 //the user in the `users` table. For users without protected tweets, applies the
 //given filter function and applies the return truth value to the `accepted`
 //table while also setting the `processed` column to mark the user as processed.
-func (t *TwitterCollector) CollectUser(screenNameOrID interface{}) {
-	u := t.n.GetUser(screenNameOrID)
-	t.s.StoreUser(u.ID, u.Name, u.Description, u.Protected, u.Blob)
+//The error matches ErrNotFound when Twitter doesn't know the user.
+func (t *TwitterCollector) CollectUser(screenNameOrID interface{}) error {
+	t.countRequest(PhaseUsers, "users/show")
+	u, err := t.n.GetUser(screenNameOrID)
+	if err != nil {
+		return err
+	}
+	t.storeUser(u)
+	return nil
+}
+
+//storeUser stores the user, applies the filter function, or the TierUser,
+//to users without protected tweets and hands the user over to the exporter.
+func (t *TwitterCollector) storeUser(u *User) {
+	t.s.StoreUser(u.ID, u.ScreenName, u.Description, u.Protected, u.Blob)
+	t.markUser(u)
+}
+
+//importUser stores a user read from a dump or an archive like storeUser,
+//unless the user is already stored. Dumps are usually older than what was
+//collected, so the stored profile, screen name and tier are left as they are.
+func (t *TwitterCollector) importUser(u *User) {
+	if t.s.GetUserByScreenNameOrID(u.ID) != nil {
+		return
+	}
+	t.s.StoreNewUser(u.ID, u.ScreenName, u.Description, u.Protected, u.Blob)
+	t.markUser(u)
+}
+
+//markUser applies the filter function, or the TierUser, to users without
+//protected tweets and hands the user over to the exporter.
+func (t *TwitterCollector) markUser(u *User) {
 	if !u.Protected {
-		t.s.MarkUserProcessed(u.ID, true, t.filterUser(u.Blob))
+		t.s.MarkUserTier(u.ID, true, t.tier(u.Blob))
+	}
+	if t.exporter != nil {
+		t.exporter.ExportUser(u.Blob)
 	}
 }
 
-//CollectTweets gets all the tweets of userID from Twitter, since the latestTweetID
-//and updates the `last_looked_at` timestamp and the `latest_tweet_id` for the user.
-func (t *TwitterCollector) CollectTweets(userID, latestTweetID int64) {
-	tweets := t.GetTweets(userID, latestTweetID)
-	for index, tweet := range tweets {
-		t.s.StoreTweet(tweet.ID, tweet.CreatedAtTime().Unix(), userID, tweet.Language, tweet.Text, tweet.Blob)
-		if index == 0 { //the first tweet in the list is the latest tweet from the user
-			t.s.MarkUserLatestTweetsCollected(userID, time.Now().UTC().Unix(), tweet.ID)
+//storeTweet stores a tweet of userID, annotates it and hands it over to the
+//exporter once the writer inserted it, so that tweets stored again, for
+//example by an overlapping page, are exported once.
+func (t *TwitterCollector) storeTweet(userID int64, tweet *Tweet) {
+	var export func()
+	if t.exporter != nil {
+		export = func() { t.exporter.ExportTweet(tweet.Blob) }
+	}
+	t.s.insertTweet(tweet.ID, tweet.CreatedAtTime().Unix(), userID, tweet.Language, tweet.Text, tweet.Blob, export)
+	if t.annotator != nil {
+		if annotations := t.annotator(tweet.Blob); len(annotations) > 0 {
+			t.s.StoreAnnotations(tweet.ID, annotations)
 		}
 	}
 }
 
+//CollectTweets gets all the tweets of userID from Twitter, since the latestTweetID,
+//storing them a page at a time, and updates the `last_looked_at` timestamp and the `latest_tweet_id` for the user.
+//The user's pinned tweet is collected too, see CollectPinnedTweet.
+//Protected users, and users Twitter no longer knows, are skipped and the reason is recorded in the `skip_reason` column.
+//CollectTweets returns the number of new tweets collected.
+func (t *TwitterCollector) CollectTweets(userID, latestTweetID int64) int {
+	if t.skipProtected(userID, PhaseTweets) {
+		return 0
+	}
+	var newestTweetID int64
+	count, err := t.StreamTweets(userID, latestTweetID, func(tweets Tweets) {
+		if newestTweetID == 0 { //the first tweet of the first page is the latest tweet from the user
+			newestTweetID = tweets[0].ID
+		}
+		for _, tweet := range tweets {
+			t.storeTweet(userID, tweet)
+		}
+	})
+	t.s.AddUserCollected(userID, count, 0)
+	if t.skipUnavailable(userID, PhaseTweets, err) {
+		return count
+	}
+	//the checkpoint only moves once all the pages are stored, so that an
+	//interrupted collection picks up the older tweets next time.
+	if newestTweetID != 0 {
+		t.s.MarkUserLatestTweetsCollected(userID, time.Now().UTC().Unix(), newestTweetID)
+	}
+	t.CollectPinnedTweet(userID)
+	t.logger.Printf("collected %d tweets of user %d", count, userID)
+	return count
+}
+
 //SeedScreenNames inserts the given Twitter screenNames into `screennames` table
 //which is picked up later for processing.
 func (t *TwitterCollector) SeedScreenNames(screenNames []string) {
@@ -174,11 +473,27 @@ func (t *TwitterCollector) SeedScreenNames(screenNames []string) {
 //the `processed` column not set and gets those users from Twitter, stores
 //them in the `users` table and sets the `processed` column.
 func (t *TwitterCollector) ProcessScreenNames() {
-	screenNames := t.s.GetUnprocessedScreenNames()
+	var after string
+	for {
+		screenNames := t.s.GetUnprocessedScreenNamesAfter(after, userIDsBatchSize)
+		if len(screenNames) == 0 {
+			return
+		}
+		after = screenNames[len(screenNames)-1]
+		t.processScreenNames(screenNames)
+	}
+}
+
+func (t *TwitterCollector) processScreenNames(screenNames []string) {
 	for _, screenName := range screenNames {
 		u := t.s.GetUserByScreenNameOrID(screenName)
 		if u == nil {
-			t.CollectUser(screenName)
+			err := t.CollectUser(screenName)
+			if errors.Is(err, ErrNotFound) {
+				t.logger.Printf("seed %s not found", screenName)
+			} else if err != nil {
+				log.Fatal(err)
+			}
 		}
 		t.s.MarkScreenNameProcessed(screenName, true)
 	}
@@ -187,10 +502,48 @@ func (t *TwitterCollector) ProcessScreenNames() {
 //CollectAllUsers gets all the userIDs queued up for processing
 //in the `userids` table, gets the users in batches and stores them
 //in the users table and sets the `processed` column for those user IDs.
+//The queue is read userIDsBatchSize IDs at a time, so that memory use stays
+//flat however long it grows.
 func (t *TwitterCollector) CollectAllUsers() {
+	t.runPhase(PhaseUsers, t.collectAllUsers)
+}
+
+//userIDsBatchSize is the number of user IDs, or screen names, read from the
+//database at a time when going over the queues and the accepted users
+const userIDsBatchSize = 10000
+
+func (t *TwitterCollector) collectAllUsers() int {
+	var processed int
+	var afterID int64
+	for {
+		userIDs := t.s.GetUnprocessedUserIDsAfter(afterID, userIDsBatchSize)
+		if len(userIDs) == 0 {
+			return processed
+		}
+		afterID = userIDs[len(userIDs)-1]
+		count, capped := t.collectUsers(userIDs)
+		processed += count
+		if capped {
+			return processed
+		}
+	}
+}
+
+//lookupChunk returns the number of user IDs handed to GetUsers at a time,
+//the lookup chunk size capped at the API's limit.
+func (t *TwitterCollector) lookupChunk() int {
+	if l, ok := t.n.(lookupLimiter); ok && l.MaxLookupIDs() < t.lookupChunkSize {
+		return l.MaxLookupIDs()
+	}
+	return t.lookupChunkSize
+}
 
-	userIDs := t.s.GetUnprocessedUserIDs()
+//collectUsers gets and stores the users with the given IDs, that are not
+//stored yet, and marks the IDs processed. It returns the number of IDs
+//processed and whether the MaxUsers cap was reached.
+func (t *TwitterCollector) collectUsers(userIDs []int64) (int, bool) {
 	filteredIDs := userIDs[:0]
+	processed := len(userIDs)
 
 	for _, ID := range userIDs {
 		u := t.s.GetUserByScreenNameOrID(ID)
@@ -202,7 +555,7 @@ func (t *TwitterCollector) CollectAllUsers() {
 	}
 
 	var chunk []int64
-	chunkSize := 100
+	chunkSize := t.lookupChunk()
 
 OuterLoop:
 
@@ -218,48 +571,73 @@ OuterLoop:
 			filteredIDs = filteredIDs[len(filteredIDs):]
 		}
 
-		users := t.n.GetUsers(chunk)
+		if t.caps.MaxUsers > 0 && t.s.CountUsers() >= t.caps.MaxUsers {
+			t.logger.Printf("reached the cap of %d users", t.caps.MaxUsers)
+			processed -= len(chunk) + len(filteredIDs)
+			return processed, true
+		}
+
+		t.waitForWriter()
+		t.countRequest(PhaseUsers, "users/lookup")
+		users, err := t.n.GetUsers(chunk)
+		if err != nil {
+			log.Fatal(err)
+		}
 		for _, u := range users {
-			t.s.StoreUser(u.ID, u.Name, u.Description, u.Protected, u.Blob)
-			if !u.Protected {
-				t.s.MarkUserProcessed(u.ID, true, t.filterUser(u.Blob))
-			}
+			t.storeUser(u)
 		}
 
 		for _, ID := range chunk {
 			t.s.MarkUserIDProcessed(ID, true)
 		}
 	}
+	return processed, false
 }
 
 //CollectAllFriends gets the user IDs marked as `accepted` in the
 //users table by the filter function and collects all their Twitter
-//friends (people they are following) and stores them in the database
+//friends (people they are following) and stores them in the database.
+//Only TierFull users are collected, see WithTiers.
 func (t *TwitterCollector) CollectAllFriends() {
-	for _, userID := range t.s.GetAcceptedUserIDs() {
-		u := t.s.GetUserByScreenNameOrID(userID)
-		t.CollectFriends(u.ID, u.LatestFriendID)
-	}
+	t.runPhase(PhaseFriends, func() int {
+		return t.eachLeastRecentUser(TierFull, PhaseFriends, func(userID int64) int {
+			u := t.s.GetUserByScreenNameOrID(userID)
+			return t.CollectFriends(u.ID, u.LatestFriendID)
+		})
+	})
 }
 
 //CollectAllFollowers gets the user IDs marked as `accepted` in the
 //users table by the filter function and collects all their Twitter
-//followers and stores them in the database
+//followers and stores them in the database. Only TierFull users are
+//collected, see WithTiers.
 func (t *TwitterCollector) CollectAllFollowers() {
-	for _, userID := range t.s.GetAcceptedUserIDs() {
-		u := t.s.GetUserByScreenNameOrID(userID)
-		t.CollectFollowers(u.ID, u.LatestFollowerID)
-	}
+	t.runPhase(PhaseFollowers, func() int {
+		return t.eachLeastRecentUser(TierFull, PhaseFollowers, func(userID int64) int {
+			u := t.s.GetUserByScreenNameOrID(userID)
+			return t.CollectFollowers(u.ID, u.LatestFollowerID)
+		})
+	})
 }
 
 //CollectAllTweets gets the user IDs marked as `accepted` in the
 //users table by the filter function and collects all their tweets
-//and stores them in the database
+//and stores them in the database. Replies collected before the tweets they
+//reply to are then linked to their conversations, see ResolveConversations,
+//and the earlier versions of edited tweets are collected, see CollectEditHistory.
+//Only the users of TierTweets and above are collected, see WithTiers.
 func (t *TwitterCollector) CollectAllTweets() {
-	for _, userID := range t.s.GetAcceptedUserIDs() {
-		u := t.s.GetUserByScreenNameOrID(userID)
-		t.CollectTweets(u.ID, u.LatestTweetID)
-	}
+	t.runPhase(PhaseTweets, func() int {
+		count := t.eachLeastRecentUser(TierTweets, PhaseTweets, func(userID int64) int {
+			u := t.s.GetUserByScreenNameOrID(userID)
+			return t.CollectTweets(u.ID, u.LatestTweetID)
+		})
+		if count > 0 {
+			t.s.ResolveConversations()
+			t.CollectEditHistory()
+		}
+		return count
+	})
 }
 
 //StartCollection first processes any seeded screenames in the
@@ -267,16 +645,97 @@ func (t *TwitterCollector) CollectAllTweets() {
 //repeatedly gets all the friends, followers and their tweets.
 //By repeating, it picks up any new friends, followers from the
 //`userids` table and futhers collection of their friends, followers,
-//tweets. Stop collection any time by exiting the program.
+//tweets. Only the phases set with WithPhases are run and each phase
+//is repeated at the intervals set with WithIntervals.
+//
+//StartCollection returns once the collection is complete, see IsComplete,
+//after calling the hook set with WithCompletionHook, unless it goes on to
+//poll the timelines of the accepted users, see WithTailing. Stop collection any
+//time by exiting the program, call SaveRateLimits first so that the rate
+//limit windows spent are respected when the collection is restarted, and
+//CloseExporter so that the exported files are complete.
 func (t *TwitterCollector) StartCollection() {
+	t.restoreRateLimits()
 	t.ProcessScreenNames()
 
-	go Repeat(t.CollectAllFriends, 2*time.Second)
-	go Repeat(t.CollectAllFollowers, 2*time.Second)
-	go Repeat(t.CollectAllUsers, 2*time.Second)
-	go Repeat(t.CollectAllTweets, 2*time.Second)
-	c := make(chan struct{})
-	<-c
+	t.logger.Printf("starting collection with concurrency %d", t.concurrency)
+
+	if t.healthAddr != "" {
+		go t.serveHealth()
+	}
+
+	if t.phases[PhaseFriends] {
+		t.startRepeat(t.CollectAllFriends, t.intervals.Friends)
+	}
+	if t.phases[PhaseFollowers] {
+		t.startRepeat(t.CollectAllFollowers, t.intervals.Followers)
+	}
+	if t.phases[PhaseUsers] {
+		t.startRepeat(t.CollectAllUsers, t.intervals.Users)
+	}
+	if t.phases[PhaseTweets] {
+		t.startRepeat(t.CollectAllTweets, t.intervals.Tweets)
+	}
+	if t.phases[PhaseRefresh] {
+		t.startRepeat(t.RefreshAllUsers, t.intervals.Refresh)
+	}
+	if t.phases[PhaseMemberships] {
+		t.startRepeat(t.CollectAllListMemberships, t.intervals.Memberships)
+	}
+	if t.phases[PhaseLists] {
+		t.startRepeat(t.CollectAllLists, t.intervals.Lists)
+	}
+	if t.phases[PhaseAccount] {
+		t.startRepeat(t.CollectAccount, t.intervals.Account)
+	}
+	if t.backupStore != nil {
+		t.startRepeat(t.backup, t.backupInterval)
+	}
+	if t.maintenanceInterval > 0 {
+		t.startRepeat(t.maintain, t.maintenanceInterval)
+	}
+	if t.protectedRecheckInterval > 0 {
+		t.startRepeat(func() {
+			t.RecheckProtectedUsers(t.protectedRecheckInterval)
+		}, t.protectedRecheckInterval)
+	}
+
+	t.waitForCompletion()
+	if t.tailInterval > 0 {
+		t.tail()
+	}
+}
+
+func (t *TwitterCollector) backup() {
+	err := t.s.UploadBackup(t.backupStore)
+	if err != nil {
+		t.logger.Printf("uploading backup: %s", err)
+	}
+}
+
+//startRepeat runs repeat in a goroutine, which waitForCompletion waits for
+//once the collection is complete.
+func (t *TwitterCollector) startRepeat(processor func(), duration time.Duration) {
+	t.rounds.Add(1)
+	go func() {
+		defer t.rounds.Done()
+		t.repeat(processor, duration)
+	}()
+}
+
+//repeat is Repeat until the collection is complete.
+func (t *TwitterCollector) repeat(processor func(), duration time.Duration) {
+	for {
+		start := time.Now()
+
+		processor()
+
+		select {
+		case <-t.done:
+			return
+		case <-time.After(start.Add(duration).Sub(time.Now())):
+		}
+	}
 }
 
 //Repeat is a utility function to make sure a given function
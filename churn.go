@@ -0,0 +1,126 @@
+package callosum
+
+import (
+	"log"
+	"time"
+)
+
+//SnapshotFollowers gets the complete list of the user's followers from
+//Twitter, unlike CollectFollowers which stops at the latest known follower,
+//and stores it in the `follower_snapshots` table, dated with the current
+//time. New followers are also stored as CollectFollowers would. Comparing
+//snapshots taken in different collection rounds with ComputeFollowerChurn
+//shows the followers gained and lost in between. It returns the number of
+//followers in the snapshot.
+func (t *TwitterCollector) SnapshotFollowers(userID int64) int {
+	if t.skipProtected(userID, PhaseFollowers) {
+		return 0
+	}
+	followers, err := t.getRelatedUsers(userID, t.countingGetter(PhaseFollowers, "followers/ids", t.n.GetFollowerIDs), 0)
+	if t.skipUnavailable(userID, PhaseFollowers, err) {
+		return 0
+	}
+	t.s.StoreFollowerSnapshot(userID, time.Now().UTC().Unix(), followers)
+	t.s.StoreFollowers(userID, followers)
+	t.s.StoreUserIDs(followers)
+	t.logger.Printf("took a snapshot of %d followers of user %d", len(followers), userID)
+	return len(followers)
+}
+
+//SnapshotAllFollowers takes a follower snapshot of every TierFull user
+//accepted by the filter function, as one collection round, and returns the number of
+//followers in all the snapshots.
+func (t *TwitterCollector) SnapshotAllFollowers() int {
+	return t.eachUserInTier(TierFull, t.SnapshotFollowers)
+}
+
+//StoreFollowerSnapshot stores the followers of the user at takenAt, in unix
+//seconds, in the `follower_snapshots` table.
+func (s *Storage) StoreFollowerSnapshot(userID, takenAt int64, followerIDs []int64) {
+	//an empty snapshot is recorded with a follower_id of 0 so that losing
+	//every follower shows up in the churn
+	if len(followerIDs) == 0 {
+		followerIDs = []int64{0}
+	}
+	for _, followerID := range followerIDs {
+		chQueryArgs <- &queryArgs{"INSERT OR IGNORE INTO follower_snapshots (user_id, follower_id, taken_at) VALUES (?, ?, ?)",
+			[]interface{}{userID, followerID, takenAt}}
+	}
+}
+
+//FollowerChurn is the change in a user's followers between two snapshots
+type FollowerChurn struct {
+	UserID          int64
+	PreviousTakenAt time.Time
+	TakenAt         time.Time
+	Gained          int
+	Lost            int
+}
+
+//ComputeFollowerChurn compares every follower snapshot with the previous
+//snapshot of the same user and stores the followers gained and lost in
+//between in the `follower_churn` table. Pairs of snapshots already compared
+//are skipped. It returns the number of pairs compared.
+func (s *Storage) ComputeFollowerChurn() int {
+	rows, err := s.db.Query(`
+		SELECT user_id, taken_at FROM (SELECT DISTINCT user_id, taken_at FROM follower_snapshots)
+		ORDER BY user_id, taken_at`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	type snapshot struct{ userID, takenAt int64 }
+	var snapshots []snapshot
+	for rows.Next() {
+		var sn snapshot
+		err = rows.Scan(&sn.userID, &sn.takenAt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		snapshots = append(snapshots, sn)
+	}
+	rows.Close()
+
+	var compared int
+	for i := 1; i < len(snapshots); i++ {
+		previous, current := snapshots[i-1], snapshots[i]
+		if previous.userID != current.userID {
+			continue
+		}
+		if s.count(`SELECT COUNT(*) FROM follower_churn WHERE user_id=? AND taken_at=?`, current.userID, current.takenAt) > 0 {
+			continue
+		}
+		difference := `SELECT COUNT(*) FROM follower_snapshots a
+			WHERE a.user_id=? AND a.taken_at=? AND a.follower_id != 0 AND NOT EXISTS (
+				SELECT 1 FROM follower_snapshots b WHERE b.user_id=a.user_id AND b.taken_at=? AND b.follower_id=a.follower_id)`
+		gained := s.count(difference, current.userID, current.takenAt, previous.takenAt)
+		lost := s.count(difference, current.userID, previous.takenAt, current.takenAt)
+		chQueryArgs <- &queryArgs{`INSERT OR REPLACE INTO follower_churn (user_id, previous_taken_at, taken_at, gained, lost)
+			VALUES (?, ?, ?, ?, ?)`, []interface{}{current.userID, previous.takenAt, current.takenAt, gained, lost}}
+		compared++
+	}
+	return compared
+}
+
+//GetFollowerChurn gets the follower churn of the user from the `follower_churn`
+//table, oldest first.
+func (s *Storage) GetFollowerChurn(userID int64) []FollowerChurn {
+	rows, err := s.db.Query(`SELECT user_id, previous_taken_at, taken_at, gained, lost FROM follower_churn
+		WHERE user_id=? ORDER BY taken_at`, userID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var churn []FollowerChurn
+	for rows.Next() {
+		var c FollowerChurn
+		var previousTakenAt, takenAt int64
+		err = rows.Scan(&c.UserID, &previousTakenAt, &takenAt, &c.Gained, &c.Lost)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.PreviousTakenAt, c.TakenAt = time.Unix(previousTakenAt, 0).UTC(), time.Unix(takenAt, 0).UTC()
+		churn = append(churn, c)
+	}
+	return churn
+}
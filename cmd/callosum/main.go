@@ -0,0 +1,108 @@
+//Command callosum runs a Twitter collection described by a config file.
+//
+//	callosum -config crawl.yaml
+//
+//Without -config, the crawl is described entirely by the CALLOSUM_*
+//environment variables, see callosum.ApplyEnv. When both are used, the
+//environment variables override the config file.
+//
+//The seeds listed in the config, and any given with -seed, are added to the
+//`screennames` table before the collection starts. Filters are referred to by
+//name, so programs that need custom filters should register them with
+//callosum.RegisterFilter and call callosum.LoadConfig themselves.
+//
+//With -serve, callosum serves read-only JSON queries over the configured
+//database on the given address instead of collecting, see
+//callosum.Storage.QueryHandler. With -duckdb, callosum exports the database to
+//the given directory for loading into DuckDB and exits, see
+//callosum.Storage.ExportDuckDB. With -sqldump, callosum writes the database
+//to the given file as plain SQL and exits, see callosum.Storage.ExportSQLDump.
+//
+//On SIGINT or SIGTERM, callosum saves the state of the rate limit windows
+//before exiting, and restores it when started again.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/venkat/callosum"
+)
+
+func main() {
+	configFileName := flag.String("config", os.Getenv("CALLOSUM_CONFIG"), "YAML file describing the crawl")
+	seeds := flag.String("seed", "", "comma separated screen names to seed the collection with")
+	serve := flag.String("serve", "", "address to serve read-only corpus queries on, instead of collecting")
+	duckDB := flag.String("duckdb", "", "directory to export the database to for DuckDB, instead of collecting")
+	sqlDump := flag.String("sqldump", "", "file to dump the database to as plain SQL, instead of collecting")
+	acceptedOnly := flag.Bool("accepted-only", false, "with -sqldump, dump only the rows of the accepted users")
+	analyze := flag.Bool("analyze", false, "refresh the query planner's statistics of the database, for example after a bulk load, instead of collecting")
+	flag.Parse()
+
+	log.SetFlags(log.Lshortfile)
+
+	var c *callosum.Config
+	if *configFileName != "" {
+		c = callosum.LoadConfig(*configFileName)
+		c.ApplyEnv()
+	} else {
+		c = callosum.ConfigFromEnv()
+	}
+	if *seeds != "" {
+		c.Seeds = append(c.Seeds, strings.Split(*seeds, ",")...)
+	}
+
+	if *serve != "" {
+		log.Fatal(http.ListenAndServe(*serve, c.Storage().QueryHandler()))
+	}
+	if *duckDB != "" {
+		err := c.Storage().ExportDuckDB(*duckDB)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *sqlDump != "" {
+		f, err := os.Create(*sqlDump)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = c.Storage().ExportSQLDump(f, callosum.SQLDumpOptions{AcceptedOnly: *acceptedOnly})
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = f.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *analyze {
+		err := c.Storage().Analyze()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	t := callosum.NewTwitterCollector(c.Options()...)
+	t.SeedScreenNames(c.Seeds)
+
+	//the rate limit windows spent are saved on shutdown, so that a restart
+	//within the windows doesn't go over them, and the exported files are closed
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		t.SaveRateLimits()
+		t.CloseExporter()
+		os.Exit(1)
+	}()
+
+	t.StartCollection()
+}
@@ -0,0 +1,67 @@
+package callosum
+
+import "math/rand"
+
+//Communities assigns every node to a community with label propagation,
+//treating follow edges as undirected. Every node starts in its own community
+//and repeatedly joins the community most of its neighbours are in, until no
+//node changes community or maxIterations is reached. Nodes are visited in
+//a random order drawn from seed, so results are reproducible. Communities
+//are labelled with the ID of one of their members.
+func (g *Graph) Communities(seed int64, maxIterations int) map[int64]int64 {
+	neighbours := make(map[int64][]int64, len(g.Nodes))
+	for from, out := range g.Out {
+		for _, to := range out {
+			neighbours[from] = append(neighbours[from], to)
+			neighbours[to] = append(neighbours[to], from)
+		}
+	}
+
+	labels := make(map[int64]int64, len(g.Nodes))
+	IDs := g.NodeIDs()
+	for _, ID := range IDs {
+		labels[ID] = ID
+	}
+
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; i < maxIterations; i++ {
+		r.Shuffle(len(IDs), func(i, j int) { IDs[i], IDs[j] = IDs[j], IDs[i] })
+
+		changed := false
+		for _, ID := range IDs {
+			if len(neighbours[ID]) == 0 {
+				continue
+			}
+			counts := make(map[int64]int)
+			for _, neighbour := range neighbours[ID] {
+				counts[labels[neighbour]]++
+			}
+			best, bestCount := labels[ID], counts[labels[ID]]
+			for label, count := range counts {
+				//ties go to the smallest label to keep the result deterministic
+				if count > bestCount || (count == bestCount && label < best) {
+					best, bestCount = label, count
+				}
+			}
+			if best != labels[ID] {
+				labels[ID] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return labels
+}
+
+//DetectCommunities runs label propagation community detection over the follow
+//graph between accepted users and stores the community of every accepted user
+//in the `community` column of the `users` table.
+func (s *Storage) DetectCommunities(seed int64) map[int64]int64 {
+	communities := s.Graph().Communities(seed, 100)
+	for ID, community := range communities {
+		chQueryArgs <- &queryArgs{"UPDATE users SET community=? where user_id=?", []interface{}{community, ID}}
+	}
+	return communities
+}
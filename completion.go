@@ -0,0 +1,97 @@
+package callosum
+
+import (
+	"sync"
+	"time"
+)
+
+//completionCheckInterval is how often StartCollection checks whether the collection is complete
+const completionCheckInterval = 5 * time.Second
+
+//progress keeps track of which phases found nothing new to collect.
+//
+//Every run of a phase that collects something bumps the work generation.
+//A phase is idle in a generation when one of its runs started in that
+//generation, with nothing waiting to be written, and found nothing new.
+//When every phase is idle in the current generation, no phase found work
+//since the last time any of them did, and so none of them will find more.
+type progress struct {
+	mutex   sync.Mutex
+	workGen int
+	idleGen map[string]int
+}
+
+//runPhase runs one round of collect, which returns the number of items it
+//collected, and records whether the phase is idle.
+func (t *TwitterCollector) runPhase(phase string, collect func() int) {
+	t.progress.mutex.Lock()
+	startGen := t.progress.workGen
+	t.progress.mutex.Unlock()
+
+	//writes still queued up may add work this run can not see yet
+	queued, _ := t.s.WriterStatus()
+
+	items := collect()
+	t.recordAPIUsage()
+
+	t.progress.mutex.Lock()
+	defer t.progress.mutex.Unlock()
+	if t.progress.idleGen == nil {
+		t.progress.idleGen = make(map[string]int)
+	}
+	switch {
+	case items > 0:
+		t.progress.workGen++
+		delete(t.progress.idleGen, phase)
+	case queued == 0:
+		t.progress.idleGen[phase] = startGen
+	}
+}
+
+//IsComplete reports whether the collection is done: the `screennames` and
+//`userids` queues are drained, or the MaxUsers cap is reached, nothing is waiting to be written, and every
+//phase run by StartCollection came up empty since the last time any phase
+//collected something, so no user needs a refresh.
+func (t *TwitterCollector) IsComplete() bool {
+	if queued, _ := t.s.WriterStatus(); queued > 0 {
+		return false
+	}
+	if t.s.CountUnprocessedScreenNames() > 0 {
+		return false
+	}
+	capped := t.caps.MaxUsers > 0 && t.s.CountUsers() >= t.caps.MaxUsers
+	if !capped && t.s.CountUnprocessedUserIDs() > 0 {
+		return false
+	}
+
+	t.progress.mutex.Lock()
+	defer t.progress.mutex.Unlock()
+	for phase, enabled := range t.phases {
+		if !enabled {
+			continue
+		}
+		if gen, ok := t.progress.idleGen[phase]; !ok || gen != t.progress.workGen {
+			return false
+		}
+	}
+	return true
+}
+
+//waitForCompletion blocks until the collection is complete, then stops
+//the repeating phases, closes the exporter and calls the completion hook.
+func (t *TwitterCollector) waitForCompletion() {
+	for !t.IsComplete() {
+		time.Sleep(completionCheckInterval)
+	}
+	close(t.done)
+	//the rounds in progress finish before StartCollection returns, so that
+	//the storage can be closed after it
+	t.rounds.Wait()
+	t.recordAPIUsage()
+	t.SaveRateLimits()
+	t.CloseExporter()
+	t.logger.Printf("collection is complete")
+	if t.completionHook != nil {
+		t.completionHook()
+	}
+}
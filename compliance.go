@@ -0,0 +1,136 @@
+package callosum
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+//The values of the `status` column of the `tweets` table. Tweets that were
+//never checked by CheckCompliance are presumed available.
+const (
+	TweetAvailable = "available"
+	//TweetDeleted also covers tweets of suspended or protected accounts, the
+	//API doesn't tell them apart.
+	TweetDeleted  = "deleted"
+	TweetWithheld = "withheld"
+)
+
+//tweetLookup is implemented by APIs that can look up tweets by ID
+type tweetLookup interface {
+	LookupTweets(IDs []int64) (map[int64]*Tweet, error)
+}
+
+//lookupTweets looks up the tweets with l for the phase. When the rate limit
+//of the lookups is used up, the next request waits for the window and the
+//lookup is tried again. Other errors are fatal.
+func (t *TwitterCollector) lookupTweets(l tweetLookup, phase string, IDs []int64) map[int64]*Tweet {
+	for {
+		t.countRequest(phase, "statuses/lookup")
+		found, err := l.LookupTweets(IDs)
+		if errors.Is(err, ErrRateLimited) {
+			t.logger.Printf("rate limited looking up %d tweets, trying again", len(IDs))
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return found
+	}
+}
+
+//ComplianceStats counts the tweets checked by CheckCompliance by status
+type ComplianceStats struct {
+	Checked  int
+	Deleted  int
+	Withheld int
+}
+
+//complianceBatchSize is the number of tweets looked up per request
+const complianceBatchSize = 100
+
+//CheckCompliance looks up the stored tweets that were not checked in the
+//last recheckAfter and records in the `status` column whether they are still
+//available, were deleted or are withheld in some countries, so that
+//corpora can honor deletion obligations. Tweets found deleted are not checked
+//again. Use PurgeDeletedTweets to drop their content.
+func (t *TwitterCollector) CheckCompliance(recheckAfter time.Duration) ComplianceStats {
+	l, ok := t.n.(tweetLookup)
+	if !ok {
+		log.Fatal("the network can't look up tweets")
+	}
+
+	var stats ComplianceStats
+	checkedBefore := time.Now().Add(-recheckAfter).Unix()
+	var afterID int64
+	for {
+		var IDs []int64
+		rows, err := t.s.db.Query(`SELECT tweet_id FROM tweets
+			WHERE status != ? AND status_checked_at <= ? AND tweet_id > ?
+			ORDER BY tweet_id LIMIT ?`, TweetDeleted, checkedBefore, afterID, complianceBatchSize)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for rows.Next() {
+			var ID int64
+			err = rows.Scan(&ID)
+			if err != nil {
+				log.Fatal(err)
+			}
+			IDs = append(IDs, ID)
+		}
+		rows.Close()
+		if len(IDs) == 0 {
+			return stats
+		}
+		afterID = IDs[len(IDs)-1]
+
+		t.waitForBudget()
+		found := t.lookupTweets(l, UsageCompliance, IDs)
+		t.consume(len(found))
+		checkedAt := time.Now().Unix()
+		for _, ID := range IDs {
+			status := TweetAvailable
+			if tweet, ok := found[ID]; !ok {
+				status = TweetDeleted
+				stats.Deleted++
+			} else if withheld(tweet.Blob) {
+				status = TweetWithheld
+				stats.Withheld++
+			}
+			t.s.MarkTweetStatus(ID, status, checkedAt)
+			stats.Checked++
+		}
+	}
+}
+
+//withheld reports whether the tweet is withheld in any country
+func withheld(blob []byte) bool {
+	var t struct {
+		WithheldInCountries []string `json:"withheld_in_countries"`
+		WithheldCopyright   bool     `json:"withheld_copyright"`
+	}
+	json.Unmarshal(blob, &t)
+	return len(t.WithheldInCountries) > 0 || t.WithheldCopyright
+}
+
+//MarkTweetStatus sets the `status` of the tweet and the time it was checked.
+//Tweets marked deleted get a `deleted_at` tombstone, the time they were first
+//found deleted, which is cleared if they turn up again.
+func (s *Storage) MarkTweetStatus(tweetID int64, status string, checkedAt int64) {
+	chQueryArgs <- &queryArgs{`UPDATE tweets SET status=?, status_checked_at=?,
+		deleted_at=CASE WHEN ?=? THEN COALESCE(deleted_at, ?) END WHERE tweet_id=?`,
+		[]interface{}{status, checkedAt, status, TweetDeleted, checkedAt, tweetID}}
+}
+
+//PurgeDeletedTweets drops the text and blob of the tweets marked deleted, and
+//their annotations, keeping only their IDs, and returns the number of tweets purged.
+func (s *Storage) PurgeDeletedTweets() int {
+	purged := s.count(`SELECT COUNT(*) FROM tweets WHERE status='` + TweetDeleted + `' AND blob IS NOT NULL`)
+	chQueryArgs <- &queryArgs{`UPDATE tweets SET "desc"='', blob=NULL, simhash=NULL WHERE status=?`,
+		[]interface{}{TweetDeleted}}
+	chQueryArgs <- &queryArgs{`DELETE FROM annotations WHERE tweet_id IN (SELECT tweet_id FROM tweets WHERE status=?)`,
+		[]interface{}{TweetDeleted}}
+	return purged
+}
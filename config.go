@@ -0,0 +1,258 @@
+package callosum
+
+import (
+	"io/ioutil"
+	"log"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+//Config describes a crawl so that it can be checked in and reproduced.
+//A Config is usually loaded from a YAML file with LoadConfig, for example:
+//
+//	db: etsy
+//	auth_file: auth.json
+//	window: 16m
+//	concurrency: 4
+//	phases: [users, tweets, friends, followers]
+//	intervals:
+//	  users: 2s
+//	  tweets: 1m
+//	caps:
+//	  max_users: 100000
+//	  max_tweets_per_user: 3200
+//	monthly_cap:
+//	  tweets: 2000000
+//	  warn_at: 0.8
+//	filter: etsy
+//	seeds: [annacoder]
+//	run: etsy-2024-03
+//	pool:
+//	  max_open_conns: 16
+//	maintenance: 6h
+//	protected_recheck: 168h
+//	unavailable_after: 3
+//	views: true
+//
+//filter refers to a FilterUser registered with RegisterFilter.
+type Config struct {
+	DB          string        `yaml:"db"`
+	AuthFile    string        `yaml:"auth_file"`
+	Window      time.Duration `yaml:"window"`
+	Concurrency int           `yaml:"concurrency"`
+	Phases      []string      `yaml:"phases"`
+	Intervals   struct {
+		Users       time.Duration `yaml:"users"`
+		Tweets      time.Duration `yaml:"tweets"`
+		Friends     time.Duration `yaml:"friends"`
+		Followers   time.Duration `yaml:"followers"`
+		Refresh     time.Duration `yaml:"refresh"`
+		Memberships time.Duration `yaml:"memberships"`
+		Lists       time.Duration `yaml:"lists"`
+		Account     time.Duration `yaml:"account"`
+	} `yaml:"intervals"`
+	Caps struct {
+		MaxUsers         int `yaml:"max_users"`
+		MaxTweetsPerUser int `yaml:"max_tweets_per_user"`
+	} `yaml:"caps"`
+	//MonthlyCap limits the tweets read each month, see WithMonthlyCap.
+	MonthlyCap struct {
+		Tweets  int     `yaml:"tweets"`
+		WarnAt  float64 `yaml:"warn_at"`
+		PauseAt float64 `yaml:"pause_at"`
+	} `yaml:"monthly_cap"`
+	Filter string   `yaml:"filter"`
+	Seeds  []string `yaml:"seeds"`
+	//Tiers refers to a TierUser registered with RegisterTiers, used in place
+	//of the filter, see WithTiers.
+	Tiers string `yaml:"tiers"`
+	//ProfilesOnly collects only user objects, see WithProfilesOnly. Phases
+	//is ignored when it is set.
+	ProfilesOnly bool `yaml:"profiles_only"`
+	//AccountOnly collects only the timelines of the authenticating account,
+	//see WithAccountOnly. Phases is ignored when it is set.
+	AccountOnly bool `yaml:"account_only"`
+	//Pool sets up the connections the database is read through, see PoolConfig.
+	Pool struct {
+		MaxOpenConns    int           `yaml:"max_open_conns"`
+		MaxIdleConns    int           `yaml:"max_idle_conns"`
+		ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	} `yaml:"pool"`
+	//Maintenance is the interval between database maintenance runs, see
+	//WithMaintenance. No maintenance is run when it is not set.
+	Maintenance time.Duration `yaml:"maintenance"`
+	//ProtectedRecheck is the interval between looking up protected users
+	//again, see WithProtectedRecheck. They are not looked up again when it
+	//is not set.
+	ProtectedRecheck time.Duration `yaml:"protected_recheck"`
+	//Run names the run the API usage is recorded under, see WithRun.
+	Run string `yaml:"run"`
+	//UnavailableAfter is the number of times in a row the collection for a
+	//user can be refused before the user is no longer collected, see
+	//WithUnavailableAfter.
+	UnavailableAfter int `yaml:"unavailable_after"`
+	//WriteWatermark is the number of writes queued up past which API
+	//requests are held off, see WithWriteWatermark.
+	WriteWatermark int `yaml:"write_watermark"`
+	//LookupChunkSize is the number of users looked up at a time, see
+	//WithLookupChunkSize.
+	LookupChunkSize int `yaml:"lookup_chunk_size"`
+	//Tail is the interval the timelines of the accepted users are polled at
+	//once the collection is complete, see WithTailing. The collection ends
+	//when it is not set.
+	Tail time.Duration `yaml:"tail"`
+	//Views creates SQL views for analysis in the database, see WithViews.
+	Views bool `yaml:"views"`
+	//FullText indexes the text of tweets for SearchTweets with the tokenizer
+	//it names, see WithFullTextSearch. There is no index when it is not set.
+	FullText FullTextConfig `yaml:"full_text"`
+	//Credentials are used instead of AuthFile when ConsumerKey or
+	//BearerToken is set.
+	Credentials Credentials `yaml:"credentials"`
+}
+
+//Credentials holds the authentication tokens you get from Twitter's
+//Application Management portal - apps.twitter.com. They are the same
+//fields found in template_auth.json. BearerToken is the application-only
+//token, left out it is requested with the consumer key and secret.
+type Credentials struct {
+	ConsumerKey       string `yaml:"consumer_key" json:"consumerKey"`
+	ConsumerSecret    string `yaml:"consumer_secret" json:"consumerSecret"`
+	AccessTokenKey    string `yaml:"access_token_key" json:"accessTokenKey"`
+	AccessTokenSecret string `yaml:"access_token_secret" json:"accessTokenSecret"`
+	BearerToken       string `yaml:"bearer_token" json:"bearerToken,omitempty"`
+}
+
+//LoadConfig reads the YAML config in fileName. Fields missing from the
+//file are set to the same defaults NewTwitterCollector uses.
+func LoadConfig(fileName string) *Config {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c := &Config{}
+	err = yaml.UnmarshalStrict(data, c)
+	if err != nil {
+		log.Fatalf("%s: %s", fileName, err)
+	}
+	c.setDefaults()
+	return c
+}
+
+func (c *Config) setDefaults() {
+	if c.DB == "" {
+		c.DB = DefaultDBName
+	}
+	if c.AuthFile == "" {
+		c.AuthFile = DefaultAuthFileName
+	}
+	if c.Window == 0 {
+		c.Window = DefaultWindow
+	}
+	if c.Filter == "" {
+		c.Filter = "accept_all"
+	}
+}
+
+//Storage opens the database described by the config with its views,
+//full-text index and reader pool, the same way for querying or exporting a
+//corpus as for collecting it.
+func (c *Config) Storage() *Storage {
+	var opts []StorageOption
+	if c.Views {
+		opts = append(opts, WithViews())
+	}
+	if c.FullText.Tokenizer != "" {
+		opts = append(opts, WithFullTextSearch(c.FullText))
+	}
+	s := NewStorage(c.DB, opts...)
+	s.ConfigurePool(PoolConfig{
+		MaxOpenConns:    c.Pool.MaxOpenConns,
+		MaxIdleConns:    c.Pool.MaxIdleConns,
+		ConnMaxLifetime: c.Pool.ConnMaxLifetime,
+	})
+	return s
+}
+
+//Options returns the collector options described by the config. Pass them
+//to NewTwitterCollector to set up the crawl.
+func (c *Config) Options() []Option {
+	fu, ok := LookupFilter(c.Filter)
+	if !ok {
+		log.Fatalf("unknown filter %q, registered filters are %v", c.Filter, FilterNames())
+	}
+
+	var n *Network
+	if c.Credentials.ConsumerKey != "" || c.Credentials.BearerToken != "" {
+		n = NewNetworkWithCredentials(c.Credentials, c.Window)
+	} else {
+		n = NewNetwork(c.AuthFile, c.Window)
+	}
+
+	opts := []Option{
+		WithStorage(c.Storage()),
+		WithNetwork(n),
+		WithFilter(fu),
+		WithIntervals(Intervals{
+			Users:       c.Intervals.Users,
+			Tweets:      c.Intervals.Tweets,
+			Friends:     c.Intervals.Friends,
+			Followers:   c.Intervals.Followers,
+			Refresh:     c.Intervals.Refresh,
+			Memberships: c.Intervals.Memberships,
+			Lists:       c.Intervals.Lists,
+			Account:     c.Intervals.Account,
+		}),
+		WithCaps(Caps{
+			MaxUsers:         c.Caps.MaxUsers,
+			MaxTweetsPerUser: c.Caps.MaxTweetsPerUser,
+		}),
+	}
+	if c.Concurrency != 0 {
+		opts = append(opts, WithConcurrency(c.Concurrency))
+	}
+	if c.ProfilesOnly {
+		opts = append(opts, WithProfilesOnly())
+	} else if c.AccountOnly {
+		opts = append(opts, WithAccountOnly())
+	} else if len(c.Phases) != 0 {
+		opts = append(opts, WithPhases(c.Phases...))
+	}
+	if c.Maintenance != 0 {
+		opts = append(opts, WithMaintenance(c.Maintenance))
+	}
+	if c.Tiers != "" {
+		tu, ok := LookupTiers(c.Tiers)
+		if !ok {
+			log.Fatalf("unknown tiers %q, registered tiers are %v", c.Tiers, TiersNames())
+		}
+		opts = append(opts, WithTiers(tu))
+	}
+	if c.MonthlyCap.Tweets != 0 {
+		opts = append(opts, WithMonthlyCap(MonthlyCap{
+			Tweets:  c.MonthlyCap.Tweets,
+			WarnAt:  c.MonthlyCap.WarnAt,
+			PauseAt: c.MonthlyCap.PauseAt,
+		}))
+	}
+	if c.Run != "" {
+		opts = append(opts, WithRun(c.Run))
+	}
+	if c.ProtectedRecheck != 0 {
+		opts = append(opts, WithProtectedRecheck(c.ProtectedRecheck))
+	}
+	if c.UnavailableAfter != 0 {
+		opts = append(opts, WithUnavailableAfter(c.UnavailableAfter))
+	}
+	if c.WriteWatermark != 0 {
+		opts = append(opts, WithWriteWatermark(c.WriteWatermark))
+	}
+	if c.LookupChunkSize != 0 {
+		opts = append(opts, WithLookupChunkSize(c.LookupChunkSize))
+	}
+	if c.Tail != 0 {
+		opts = append(opts, WithTailing(c.Tail))
+	}
+	return opts
+}
@@ -0,0 +1,98 @@
+package callosum
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+//storeConversation sets the `conversation_id` of the tweet: the one given
+//in API v2 tweets, or for v1.1 tweets the tweet itself when it isn't a reply
+//and otherwise the conversation of the tweet it replies to, as far as it is
+//stored. Replies stored before the tweets they reply to are linked up by
+//ResolveConversations.
+func (s *Storage) storeConversation(tweetID, userID int64, blob []byte) {
+	var t struct {
+		ConversationID    json.Number `json:"conversation_id"`
+		InReplyToStatusID int64       `json:"in_reply_to_status_id"`
+	}
+	if json.Unmarshal(blob, &t) != nil {
+		return
+	}
+	if conversationID, err := strconv.ParseInt(string(t.ConversationID), 10, 64); err == nil && conversationID != 0 {
+		chQueryArgs <- &queryArgs{"UPDATE tweets SET conversation_id=? WHERE tweet_id=?",
+			[]interface{}{conversationID, tweetID}}
+		return
+	}
+	if t.InReplyToStatusID == 0 {
+		chQueryArgs <- &queryArgs{"UPDATE tweets SET conversation_id=tweet_id WHERE tweet_id=?",
+			[]interface{}{tweetID}}
+		return
+	}
+	chQueryArgs <- &queryArgs{`UPDATE tweets SET conversation_id=COALESCE((SELECT conversation_id FROM tweets WHERE tweet_id=?), ?)
+		WHERE tweet_id=?`,
+		[]interface{}{t.InReplyToStatusID, t.InReplyToStatusID, tweetID}}
+}
+
+//ResolveConversations follows the reply chains in the `replies` table up to
+//the first tweet of each conversation and sets it as the `conversation_id`
+//of the replies, which the tweets they reply to were not stored for yet when
+//they were. Chains are followed as far as the tweets are stored, so the
+//conversation of a reply to a tweet that was never collected is that tweet.
+//The conversations given in API v2 tweets are kept.
+//
+//Only the chains of the replies stored since the last call, which are not
+//marked `resolved` in the `replies` table yet, are followed, from their first
+//tweet down, so that each round doesn't go over every reply. The replies are
+//read in batches, and only those read are marked, so that the replies stored
+//meanwhile are left for the next call.
+func (s *Storage) ResolveConversations() {
+	s.flush()
+	var afterID int64
+	for {
+		IDs := s.getUnresolvedReplies(afterID, userIDsBatchSize)
+		if len(IDs) == 0 {
+			return
+		}
+		afterID = IDs[len(IDs)-1]
+		batch := string(mustMarshal(IDs))
+		chQueryArgs <- &queryArgs{`WITH RECURSIVE unresolved(tweet_id, in_reply_to_tweet_id) AS (
+				SELECT tweet_id, in_reply_to_tweet_id FROM replies WHERE tweet_id IN (SELECT value FROM json_each(?))
+				UNION
+				SELECT replies.tweet_id, replies.in_reply_to_tweet_id FROM replies
+				JOIN unresolved ON replies.tweet_id = unresolved.in_reply_to_tweet_id
+			), chains(tweet_id, root_id) AS (
+				SELECT tweet_id, in_reply_to_tweet_id FROM unresolved
+				WHERE in_reply_to_tweet_id NOT IN (SELECT tweet_id FROM replies)
+				UNION ALL
+				SELECT replies.tweet_id, chains.root_id FROM replies JOIN chains ON replies.in_reply_to_tweet_id = chains.tweet_id
+			)
+			UPDATE tweets SET conversation_id=(SELECT root_id FROM chains WHERE chains.tweet_id = tweets.tweet_id)
+			WHERE tweet_id IN (SELECT tweet_id FROM chains) AND json_extract(blob, '$.conversation_id') IS NULL`,
+			[]interface{}{batch}}
+		chQueryArgs <- &queryArgs{"UPDATE replies SET resolved=1 WHERE tweet_id IN (SELECT value FROM json_each(?))",
+			[]interface{}{batch}}
+	}
+}
+
+//getUnresolvedReplies gets up to limit IDs of the replies not marked
+//`resolved` yet, after afterID
+func (s *Storage) getUnresolvedReplies(afterID int64, limit int) []int64 {
+	var IDs []int64
+	s.queryScreenNamesOrIDs("SELECT tweet_id FROM replies WHERE resolved=0 AND tweet_id > ? ORDER BY tweet_id LIMIT ?",
+		&IDs, afterID, limit)
+	return IDs
+}
+
+//BackfillConversations sets the `conversation_id` of the tweets stored before
+//the column was added, and returns the number of tweets read.
+func (s *Storage) BackfillConversations() int {
+	count := s.eachTweetBlob(s.storeConversation)
+	s.ResolveConversations()
+	return count
+}
+
+//GetConversation gets the stored tweets of the conversation, oldest first
+func (s *Storage) GetConversation(conversationID int64) Tweets {
+	return s.queryTweets(`SELECT tweet_id, created_at, langugage, "desc", blob FROM tweets
+		WHERE conversation_id=? ORDER BY tweet_id`, conversationID)
+}
@@ -0,0 +1,106 @@
+package callosum
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//CollectionStats holds what the collection did for a user, kept in the
+//`users` and `user_attempts` tables, see GetCollectionStats.
+type CollectionStats struct {
+	UserID int64 `json:"user_id"`
+	//Attempts is the number of times the user's tweets, friends, followers or
+	//lists were requested from Twitter, whether it answered or refused, or
+	//skipped because the user is stored as protected or unavailable.
+	Attempts int `json:"attempts"`
+	//LastAttemptAt is the zero time when the user was never attempted
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+	//LastError is the error of the last attempt of any phase, prefixed with
+	//its phase, so it is overwritten by the next attempt of another phase.
+	//It is empty when the last attempt succeeded. Phases keeps the last
+	//error of each phase.
+	LastError string `json:"last_error"`
+	//Phases holds the attempts of each phase that attempted the user
+	Phases map[string]PhaseAttempts `json:"phases"`
+	//TweetsCollected is the number of the user's tweets collected
+	TweetsCollected int `json:"tweets_collected"`
+	//EdgesCollected is the number of the user's friends and followers collected
+	EdgesCollected int `json:"edges_collected"`
+}
+
+//PhaseAttempts holds the attempts of a phase at collecting a user, kept in
+//the `user_attempts` table.
+type PhaseAttempts struct {
+	Attempts      int       `json:"attempts"`
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+	//LastError is empty when the phase's last attempt succeeded
+	LastError string `json:"last_error"`
+}
+
+//MarkUserAttempt counts an attempt of the phase at collecting the user, and
+//records when it was made and its error, if any, for the user and for the
+//phase.
+func (s *Storage) MarkUserAttempt(userID int64, phase string, attemptedAt int64, err error) {
+	var lastError string
+	if err != nil {
+		lastError = err.Error()
+	}
+	chPriorityArgs <- &queryArgs{`INSERT INTO user_attempts (user_id, phase, attempts, last_attempt_at, last_error)
+		VALUES (?, ?, 1, ?, ?) ON CONFLICT (user_id, phase) DO UPDATE
+		SET attempts=attempts+1, last_attempt_at=excluded.last_attempt_at, last_error=excluded.last_error`,
+		[]interface{}{userID, phase, attemptedAt, lastError}}
+	if err != nil {
+		lastError = fmt.Sprintf("%s: %s", phase, err)
+	}
+	chPriorityArgs <- &queryArgs{"UPDATE users SET attempts=attempts+1, last_attempt_at=?, last_error=? WHERE user_id=?",
+		[]interface{}{attemptedAt, lastError, userID}}
+}
+
+//AddUserCollected adds to the number of tweets and edges collected for the user
+func (s *Storage) AddUserCollected(userID int64, tweets, edges int) {
+	if tweets == 0 && edges == 0 {
+		return
+	}
+	chPriorityArgs <- &queryArgs{"UPDATE users SET collected_tweets=collected_tweets+?, collected_edges=collected_edges+? WHERE user_id=?",
+		[]interface{}{tweets, edges, userID}}
+}
+
+//GetCollectionStats gets what the collection did for the user, to decide
+//whether to retry it and to report how well the account is covered by the
+//corpus. The error matches ErrNotFound when the user is not stored.
+func (s *Storage) GetCollectionStats(userID int64) (*CollectionStats, error) {
+	c := CollectionStats{UserID: userID}
+	var lastAttemptAt int64
+	err := s.db.QueryRow(`SELECT attempts, last_attempt_at, last_error, collected_tweets, collected_edges
+		FROM users WHERE user_id=?`, userID).Scan(&c.Attempts, &lastAttemptAt, &c.LastError,
+		&c.TweetsCollected, &c.EdgesCollected)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user %v: %w", userID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lastAttemptAt != 0 {
+		c.LastAttemptAt = time.Unix(lastAttemptAt, 0).UTC()
+	}
+
+	rows, err := s.db.Query("SELECT phase, attempts, last_attempt_at, last_error FROM user_attempts WHERE user_id=?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	c.Phases = make(map[string]PhaseAttempts)
+	for rows.Next() {
+		var phase string
+		var a PhaseAttempts
+		err = rows.Scan(&phase, &a.Attempts, &lastAttemptAt, &a.LastError)
+		if err != nil {
+			return nil, err
+		}
+		a.LastAttemptAt = time.Unix(lastAttemptAt, 0).UTC()
+		c.Phases[phase] = a
+	}
+	return &c, rows.Err()
+}
@@ -0,0 +1,83 @@
+package callosum
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+//collectRelatedUsers gets the IDs of the friends or followers of userID
+//with getter a page at a time, stopping at lastUserID, and hands each page
+//to store as it arrives. The cursor of the next page, and the most recent ID
+//of the first page, are kept in the `paging_cursors` table until the last
+//page is stored, so that paging through an account with millions of
+//followers resumes where it stopped when the collector is restarted, instead
+//of getting the pages again. Once done, markLatest is called with the most
+//recent ID. collectRelatedUsers returns the number of IDs stored, also when
+//it fails part way.
+func (t *TwitterCollector) collectRelatedUsers(userID int64, phase string, getter listGetter, lastUserID int64,
+	store func(IDs []int64), markLatest func(userID, latestID int64)) (int, error) {
+	cursorID, headID := t.s.GetPagingCursor(userID, phase)
+	if cursorID == 0 {
+		cursorID, headID = -1, 0
+	} else {
+		t.logger.Printf("resuming %s of user %d at cursor %d", phase, userID, cursorID)
+	}
+	var count int
+	for {
+		t.waitForWriter()
+		IDs, nextCursorID, err := getter(userID, cursorID)
+		if err != nil {
+			return count, err
+		}
+		if len(IDs) == 0 {
+			break
+		}
+		IDs, trimmed := trimTillID(IDs, lastUserID)
+		if headID == 0 && len(IDs) > 0 { //the IDs are sorted from the most recent to the least recent
+			headID = IDs[0]
+		}
+		store(IDs)
+		count += len(IDs)
+		if trimmed || nextCursorID == 0 {
+			break
+		}
+		cursorID = nextCursorID
+		t.s.StorePagingCursor(userID, phase, cursorID, headID)
+	}
+	if headID != 0 {
+		markLatest(userID, headID)
+	}
+	t.s.ClearPagingCursor(userID, phase)
+	return count, nil
+}
+
+//GetPagingCursor gets the cursor of the next page of the phase, PhaseFriends
+//or PhaseFollowers, for the user and the most recent ID of the pages stored
+//before it, from the `paging_cursors` table. The cursor is 0 when the user
+//is not being paged through.
+func (s *Storage) GetPagingCursor(userID int64, phase string) (cursorID, headID int64) {
+	err := s.db.QueryRow("SELECT next_cursor, head_id FROM paging_cursors WHERE user_id=? AND phase=?",
+		userID, phase).Scan(&cursorID, &headID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Fatal(err)
+	}
+	return cursorID, headID
+}
+
+//StorePagingCursor keeps the cursor of the next page of the phase for the
+//user, and the most recent ID of the pages stored before it. Updates are
+//coalesced, see queueCheckpoint, and are only written after the pages.
+func (s *Storage) StorePagingCursor(userID int64, phase string, cursorID, headID int64) {
+	queueCheckpoint(fmt.Sprintf("paging_cursor %s %d", phase, userID),
+		&queryArgs{"INSERT OR REPLACE INTO paging_cursors (user_id, phase, next_cursor, head_id) VALUES (?, ?, ?, ?)",
+			[]interface{}{userID, phase, cursorID, headID}})
+}
+
+//ClearPagingCursor removes the cursor of the phase for the user once the
+//last page is stored.
+func (s *Storage) ClearPagingCursor(userID int64, phase string) {
+	queueCheckpoint(fmt.Sprintf("paging_cursor %s %d", phase, userID),
+		&queryArgs{"DELETE FROM paging_cursors WHERE user_id=? AND phase=?", []interface{}{userID, phase}})
+}
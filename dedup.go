@@ -0,0 +1,132 @@
+package callosum
+
+import (
+	"hash/fnv"
+	"log"
+	"math/bits"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+//DefaultNearDuplicateDistance is the largest number of differing simhash bits
+//for two tweets to be considered near-duplicates
+const DefaultNearDuplicateDistance = 3
+
+var urlPattern = regexp.MustCompile(`https?://\S+`)
+var mentionPattern = regexp.MustCompile(`@\w+`)
+
+//simhashTokens lowercases the text and splits it into words, dropping URLs,
+//mentions and retweet markers, which vary between copies of the same text.
+func simhashTokens(text string) []string {
+	text = urlPattern.ReplaceAllString(text, " ")
+	text = mentionPattern.ReplaceAllString(text, " ")
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '#'
+	})
+	tokens := words[:0]
+	for _, word := range words {
+		if word != "rt" {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+//Simhash computes the 64 bit simhash of the text's words and word pairs.
+//Texts that differ in a few words have simhashes that differ in a few bits.
+func Simhash(text string) uint64 {
+	tokens := simhashTokens(text)
+	var features []string
+	for i, token := range tokens {
+		features = append(features, token)
+		if i > 0 {
+			features = append(features, tokens[i-1]+" "+token)
+		}
+	}
+
+	var weights [64]int
+	for _, feature := range features {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for bit := uint(0); bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var hash uint64
+	for bit := uint(0); bit < 64; bit++ {
+		if weights[bit] > 0 {
+			hash |= 1 << bit
+		}
+	}
+	return hash
+}
+
+//MarkNearDuplicates computes the simhash of every stored tweet and marks
+//tweets whose text is a near-duplicate of an earlier tweet, such as spam and
+//copypasta, by setting the `near_duplicate_of` column to the ID of the
+//earliest such tweet. maxDistance is the largest number of differing simhash
+//bits allowed, up to 3. It returns the number of near-duplicates found.
+func (s *Storage) MarkNearDuplicates(maxDistance int) int {
+	if maxDistance > 3 || maxDistance < 0 {
+		log.Fatal("maxDistance must be between 0 and 3")
+	}
+
+	//with 4 bands of 16 bits, two hashes at most 3 bits apart share at least one band
+	const bands = 4
+	buckets := make(map[[2]uint64][]int64)
+	hashes := make(map[int64]uint64)
+
+	rows, err := s.db.Query(`SELECT tweet_id, "desc" FROM tweets ORDER BY tweet_id`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var duplicates int
+	for rows.Next() {
+		var tweetID int64
+		var text string
+		err = rows.Scan(&tweetID, &text)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(simhashTokens(text)) == 0 {
+			continue
+		}
+		hash := Simhash(text)
+
+		var original int64
+		for band := uint64(0); band < bands; band++ {
+			key := [2]uint64{band, (hash >> (band * 16)) & 0xffff}
+			for _, candidate := range buckets[key] {
+				if bits.OnesCount64(hash^hashes[candidate]) <= maxDistance &&
+					(original == 0 || candidate < original) {
+					original = candidate
+				}
+			}
+		}
+
+		if original != 0 {
+			duplicates++
+			chQueryArgs <- &queryArgs{"UPDATE tweets SET simhash=?, near_duplicate_of=? where tweet_id=?",
+				[]interface{}{int64(hash), original, tweetID}}
+			continue
+		}
+		//only originals are indexed, so near-duplicates point at the earliest tweet
+		hashes[tweetID] = hash
+		for band := uint64(0); band < bands; band++ {
+			key := [2]uint64{band, (hash >> (band * 16)) & 0xffff}
+			buckets[key] = append(buckets[key], tweetID)
+		}
+		chQueryArgs <- &queryArgs{"UPDATE tweets SET simhash=?, near_duplicate_of=0 where tweet_id=?",
+			[]interface{}{int64(hash), tweetID}}
+	}
+	return duplicates
+}
@@ -0,0 +1,171 @@
+package callosum
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//DuckDBLoadScript is the name of the SQL script ExportDuckDB writes to load
+//the exported tables into DuckDB.
+const DuckDBLoadScript = "load.sql"
+
+//ExportDuckDB writes every table of the database to dir as CSV, one file per
+//table, along with a DuckDBLoadScript that creates the tables with matching
+//column types in DuckDB and loads the files, for columnar analysis of large
+//corpora. Load them from within dir with:
+//
+//	duckdb corpus.duckdb < load.sql
+//
+//Blobs are exported as JSON text, use DuckDB's json functions to query them.
+func (s *Storage) ExportDuckDB(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	script, err := os.Create(filepath.Join(dir, DuckDBLoadScript))
+	if err != nil {
+		return err
+	}
+	defer script.Close()
+
+	for _, table := range s.tableNames() {
+		columns := s.duckDBColumns(table)
+		definitions := make([]string, len(columns))
+		for i, c := range columns {
+			definitions[i] = fmt.Sprintf("%q %s", c.name, c.duckDBType)
+		}
+		fmt.Fprintf(script, "CREATE TABLE %q (%s);\n", table, strings.Join(definitions, ", "))
+		fmt.Fprintf(script, "COPY %q FROM '%s.csv' (HEADER);\n", table, table)
+
+		err = s.exportTableCSV(filepath.Join(dir, table+".csv"), table, columns)
+		if err != nil {
+			return err
+		}
+	}
+	return script.Close()
+}
+
+//WriteDuckDBAttach writes the DuckDB statements that attach the SQLite
+//database in fileName, read-only, and expose its tables and views without
+//exporting them. Queries then read the SQLite file directly, through DuckDB's
+//sqlite extension, so they see a live crawl but are slower than over an export.
+func WriteDuckDBAttach(w io.Writer, fileName string) error {
+	_, err := fmt.Fprintf(w, `INSTALL sqlite;
+LOAD sqlite;
+ATTACH '%s' AS callosum (TYPE SQLITE, READ_ONLY);
+USE callosum;
+`, strings.Replace(fileName, "'", "''", -1))
+	return err
+}
+
+type duckDBColumn struct {
+	name, duckDBType string
+}
+
+//tableNames gets the names of the tables in the database
+func (s *Storage) tableNames() []string {
+	var names []string
+	s.queryScreenNamesOrIDs("SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name", &names)
+	return names
+}
+
+//duckDBColumns gets the columns of the table with the DuckDB types matching
+//their SQLite types.
+func (s *Storage) duckDBColumns(table string) []duckDBColumn {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%q)", table))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var columns []duckDBColumn
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue interface{}
+		err = rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk)
+		if err != nil {
+			log.Fatal(err)
+		}
+		duckDBType := "VARCHAR"
+		switch columnType = strings.ToUpper(columnType); {
+		case strings.Contains(columnType, "INT"):
+			duckDBType = "BIGINT"
+		case strings.Contains(columnType, "REAL"), strings.Contains(columnType, "FLOA"), strings.Contains(columnType, "DOUB"):
+			duckDBType = "DOUBLE"
+		}
+		columns = append(columns, duckDBColumn{name, duckDBType})
+	}
+	return columns
+}
+
+//exportTableCSV writes the columns of the table to fileName as CSV with a
+//header. NULLs are written as empty fields, which DuckDB reads as NULL.
+func (s *Storage) exportTableCSV(fileName, table string, columns []duckDBColumn) error {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = fmt.Sprintf("%q", c.name)
+	}
+	rows, err := s.db.Query(fmt.Sprintf("SELECT %s FROM %q", strings.Join(names, ", "), table))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	cw := csv.NewWriter(bw)
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.name
+	}
+	cw.Write(header)
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		err = rows.Scan(pointers...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, v := range values {
+			switch x := v.(type) {
+			case nil:
+				record[i] = ""
+			case int64:
+				record[i] = strconv.FormatInt(x, 10)
+			case float64:
+				record[i] = strconv.FormatFloat(x, 'g', -1, 64)
+			case []byte:
+				record[i] = string(x)
+			default:
+				record[i] = fmt.Sprint(x)
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	if err = cw.Error(); err != nil {
+		return err
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
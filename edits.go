@@ -0,0 +1,121 @@
+package callosum
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+//editHistory gets the IDs of the versions of an edited tweet, oldest first,
+//from its blob: the `edit_history_tweet_ids` of API v2 tweets or the
+//`edit_history` of v1.1 tweets. Tweets that were never edited have only
+//their own ID, for which nil is returned.
+func editHistory(blob []byte) []int64 {
+	var t struct {
+		EditHistoryTweetIDs []string `json:"edit_history_tweet_ids"`
+		EditHistory         struct {
+			EditTweetIDs []string `json:"edit_tweet_ids"`
+		} `json:"edit_history"`
+	}
+	if json.Unmarshal(blob, &t) != nil {
+		return nil
+	}
+	IDStrings := t.EditHistoryTweetIDs
+	if len(IDStrings) == 0 {
+		IDStrings = t.EditHistory.EditTweetIDs
+	}
+	if len(IDStrings) < 2 {
+		return nil
+	}
+	var IDs []int64
+	for _, IDString := range IDStrings {
+		if ID, err := strconv.ParseInt(IDString, 10, 64); err == nil {
+			IDs = append(IDs, ID)
+		}
+	}
+	return IDs
+}
+
+//storeEdits links the versions of an edited tweet in the `edits` table, by
+//the ID of the original tweet and the position of each version in the edit
+//history, starting from 0 for the original.
+func (s *Storage) storeEdits(tweetID, userID int64, blob []byte) {
+	IDs := editHistory(blob)
+	for version, ID := range IDs {
+		chQueryArgs <- &queryArgs{`INSERT OR IGNORE INTO edits (tweet_id, initial_tweet_id, version, user_id) VALUES (?, ?, ?, ?)`,
+			[]interface{}{ID, IDs[0], version, userID}}
+	}
+}
+
+//BackfillEdits links the versions of the edited tweets stored before the
+//`edits` table was added, and returns the number of tweets read.
+func (s *Storage) BackfillEdits() int {
+	return s.eachTweetBlob(s.storeEdits)
+}
+
+//CollectEditHistory looks up the earlier versions of the edited tweets,
+//linked in the `edits` table, that are not stored yet, and stores them in the
+//`edit_versions` table so that the content of a tweet before it was edited is
+//kept. The versions are not stored as tweets, so they are not counted again,
+//their interactions are not extracted again and they are not exported.
+//Versions that are no longer available are marked missing in the `edits`
+//table and not looked up again. It returns the number of versions stored.
+func (t *TwitterCollector) CollectEditHistory() int {
+	l, ok := t.n.(tweetLookup)
+	if !ok {
+		return 0
+	}
+	var stored int
+	var afterID int64
+	for {
+		var IDs []int64
+		t.s.queryScreenNamesOrIDs(`SELECT tweet_id FROM edits
+			WHERE tweet_id > ? AND missing=0 AND tweet_id NOT IN (SELECT tweet_id FROM tweets)
+			AND tweet_id NOT IN (SELECT tweet_id FROM edit_versions)
+			ORDER BY tweet_id LIMIT ?`, &IDs, afterID, complianceBatchSize)
+		if len(IDs) == 0 {
+			break
+		}
+		afterID = IDs[len(IDs)-1]
+
+		t.waitForBudget()
+		found := t.lookupTweets(l, PhaseTweets, IDs)
+		t.consume(len(found))
+		for _, ID := range IDs {
+			tweet, ok := found[ID]
+			if !ok {
+				t.s.markEditMissing(ID)
+				continue
+			}
+			t.s.storeEditVersion(tweet)
+			stored++
+		}
+	}
+	if stored > 0 {
+		t.logger.Printf("collected %d earlier versions of edited tweets", stored)
+	}
+	return stored
+}
+
+//storeEditVersion stores an earlier version of an edited tweet in the
+//`edit_versions` table
+func (s *Storage) storeEditVersion(tweet *Tweet) {
+	chQueryArgs <- &queryArgs{`INSERT OR IGNORE INTO edit_versions (tweet_id, created_at, langugage, "desc", blob) VALUES (?, ?, ?, ?, ?)`,
+		[]interface{}{tweet.ID, tweet.CreatedAtTime().Unix(), tweet.Language, tweet.Text, tweet.Blob}}
+}
+
+//markEditMissing marks a version of an edited tweet as no longer available
+func (s *Storage) markEditMissing(tweetID int64) {
+	chQueryArgs <- &queryArgs{"UPDATE edits SET missing=1 WHERE tweet_id=?", []interface{}{tweetID}}
+}
+
+//GetEditHistory gets the stored versions of the tweet linked in the `edits`
+//table, from the `tweets` and `edit_versions` tables, the original tweet
+//first. Tweets that were never edited have none.
+func (s *Storage) GetEditHistory(tweetID int64) Tweets {
+	return s.queryTweets(`SELECT versions.tweet_id, created_at, langugage, "desc", blob FROM edits
+		JOIN (SELECT tweet_id, created_at, langugage, "desc", blob FROM tweets
+			UNION ALL SELECT tweet_id, created_at, langugage, "desc", blob FROM edit_versions) versions
+		ON versions.tweet_id = edits.tweet_id
+		WHERE initial_tweet_id=(SELECT initial_tweet_id FROM edits WHERE tweet_id=?)
+		ORDER BY version`, tweetID)
+}
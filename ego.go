@@ -0,0 +1,86 @@
+package callosum
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+)
+
+//Ego returns the ego network of userID: the nodes within hops follow edges
+//of the user, in either direction, and all the edges between them. The user
+//is the only node of the returned graph when they are not in g.
+func (g *Graph) Ego(userID int64, hops int) *Graph {
+	ego := NewGraph()
+	if g.Nodes[userID] == nil {
+		return ego
+	}
+
+	ego.Nodes[userID] = g.Nodes[userID]
+	frontier := []int64{userID}
+	for hop := 0; hop < hops; hop++ {
+		var next []int64
+		for _, ID := range frontier {
+			for _, neighbours := range [][]int64{g.Out[ID], g.In[ID]} {
+				for _, neighbour := range neighbours {
+					if ego.Nodes[neighbour] == nil {
+						ego.Nodes[neighbour] = g.Nodes[neighbour]
+						next = append(next, neighbour)
+					}
+				}
+			}
+		}
+		frontier = next
+	}
+
+	for from, out := range g.Out {
+		if ego.Nodes[from] == nil {
+			continue
+		}
+		for _, to := range out {
+			if ego.Nodes[to] != nil {
+				ego.AddEdge(from, to)
+			}
+		}
+	}
+	return ego
+}
+
+type graphJSON struct {
+	Nodes []*Node    `json:"nodes"`
+	Edges [][2]int64 `json:"edges"`
+}
+
+//WriteJSON writes the graph as a JSON object with a list of nodes, with
+//their attributes, and a list of [follower, followed] edges.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	out := graphJSON{Nodes: []*Node{}, Edges: [][2]int64{}}
+	for _, ID := range g.NodeIDs() {
+		out.Nodes = append(out.Nodes, g.Nodes[ID])
+		for _, to := range g.Out[ID] {
+			out.Edges = append(out.Edges, [2]int64{ID, to})
+		}
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+//EgoNetwork extracts the 1 or 2 hop ego network of userID from all the
+//stored follow edges.
+func (s *Storage) EgoNetwork(userID int64, hops int) *Graph {
+	return s.FullGraph().Ego(userID, hops)
+}
+
+//ExportEgoNetwork writes the ego network of userID as JSON to fileName, see Graph.WriteJSON.
+func (s *Storage) ExportEgoNetwork(userID int64, hops int, fileName string) *Graph {
+	ego := s.EgoNetwork(userID, hops)
+	f, err := os.Create(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	err = ego.WriteJSON(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return ego
+}
@@ -0,0 +1,94 @@
+package callosum
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//The environment variables read by ApplyEnv
+const (
+	EnvDB                = "CALLOSUM_DB"
+	EnvAuthFile          = "CALLOSUM_AUTH_FILE"
+	EnvConsumerKey       = "CALLOSUM_CONSUMER_KEY"
+	EnvConsumerSecret    = "CALLOSUM_CONSUMER_SECRET"
+	EnvAccessTokenKey    = "CALLOSUM_ACCESS_TOKEN_KEY"
+	EnvAccessTokenSecret = "CALLOSUM_ACCESS_TOKEN_SECRET"
+	EnvBearerToken       = "CALLOSUM_BEARER_TOKEN"
+	EnvWindow            = "CALLOSUM_WINDOW"
+	EnvConcurrency       = "CALLOSUM_CONCURRENCY"
+	EnvPhases            = "CALLOSUM_PHASES"
+	EnvFilter            = "CALLOSUM_FILTER"
+	EnvSeeds             = "CALLOSUM_SEEDS"
+	EnvMaxUsers          = "CALLOSUM_MAX_USERS"
+	EnvMaxTweetsPerUser  = "CALLOSUM_MAX_TWEETS_PER_USER"
+)
+
+//ConfigFromEnv returns a Config built only from the CALLOSUM_* environment
+//variables, for running the collector without any mounted files.
+func ConfigFromEnv() *Config {
+	c := &Config{}
+	c.ApplyEnv()
+	c.setDefaults()
+	return c
+}
+
+//ApplyEnv overrides the fields of the config with the CALLOSUM_* environment
+//variables that are set. Lists such as CALLOSUM_PHASES and CALLOSUM_SEEDS
+//are comma separated and durations such as CALLOSUM_WINDOW use
+//time.ParseDuration's format.
+func (c *Config) ApplyEnv() {
+	envString(EnvDB, &c.DB)
+	envString(EnvAuthFile, &c.AuthFile)
+	envString(EnvConsumerKey, &c.Credentials.ConsumerKey)
+	envString(EnvConsumerSecret, &c.Credentials.ConsumerSecret)
+	envString(EnvAccessTokenKey, &c.Credentials.AccessTokenKey)
+	envString(EnvAccessTokenSecret, &c.Credentials.AccessTokenSecret)
+	envString(EnvBearerToken, &c.Credentials.BearerToken)
+	envDuration(EnvWindow, &c.Window)
+	envInt(EnvConcurrency, &c.Concurrency)
+	envList(EnvPhases, &c.Phases)
+	envString(EnvFilter, &c.Filter)
+	envList(EnvSeeds, &c.Seeds)
+	envInt(EnvMaxUsers, &c.Caps.MaxUsers)
+	envInt(EnvMaxTweetsPerUser, &c.Caps.MaxTweetsPerUser)
+}
+
+func envString(name string, value *string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*value = v
+	}
+}
+
+func envList(name string, value *[]string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*value = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*value = append(*value, item)
+			}
+		}
+	}
+}
+
+func envInt(name string, value *int) {
+	if v, ok := os.LookupEnv(name); ok {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("%s: %s", name, err)
+		}
+		*value = i
+	}
+}
+
+func envDuration(name string, value *time.Duration) {
+	if v, ok := os.LookupEnv(name); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("%s: %s", name, err)
+		}
+		*value = d
+	}
+}
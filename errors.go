@@ -0,0 +1,76 @@
+package callosum
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//The errors returned by the package. Errors from Twitter's API are returned
+//as *APIError, which matches the sentinel for its error code with errors.Is.
+var (
+	//ErrNotFound is returned for users, tweets and places that don't exist, either
+	//on Twitter, including suspended accounts, or in the database.
+	ErrNotFound = errors.New("callosum: not found")
+	//ErrRateLimited is returned when Twitter refuses a request because the
+	//rate limit of the endpoint is used up.
+	ErrRateLimited = errors.New("callosum: rate limited")
+	//ErrProtected is returned when the tweets, friends or followers of a
+	//protected account are requested.
+	ErrProtected = errors.New("callosum: protected account")
+	//ErrStorageClosed is returned by Storage after Close.
+	ErrStorageClosed = errors.New("callosum: storage closed")
+	//ErrBadCredentials is returned when Twitter doesn't accept the
+	//authentication tokens.
+	ErrBadCredentials = errors.New("callosum: bad credentials")
+)
+
+//APIError is an error response from Twitter's API. Code is the first error
+//code in the response, see Twitter's documentation on response codes, or 0
+//when the response has none.
+type APIError struct {
+	Endpoint string
+	Code     int
+	Err      error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Endpoint, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+//Is matches the error codes to the sentinel errors
+func (e *APIError) Is(target error) bool {
+	switch e.Code {
+	case 17, 34, 50, 63, 144: //no user matches, not found, user not found, suspended, no status
+		return target == ErrNotFound
+	case 88: //rate limit exceeded
+		return target == ErrRateLimited
+	case 179: //not authorized to see the status
+		return target == ErrProtected
+	case 32, 89, 99, 215: //could not authenticate, invalid or expired token, bad authentication data
+		return target == ErrBadCredentials
+	}
+	return false
+}
+
+//errorCodePattern finds the error code in the error response body that
+//kuruvi includes in its errors.
+var errorCodePattern = regexp.MustCompile(`"code"\s*:\s*(\d+)`)
+
+//newAPIError wraps an error from kuruvi for a request to the endpoint
+func newAPIError(endpoint string, err error) *APIError {
+	e := &APIError{Endpoint: endpoint, Err: err}
+	if match := errorCodePattern.FindStringSubmatch(err.Error()); match != nil {
+		e.Code, _ = strconv.Atoi(match[1])
+	} else if strings.Contains(err.Error(), "Not authorized") {
+		//protected timelines and lists of IDs get an error without a code
+		e.Code = 179
+	}
+	return e
+}
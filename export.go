@@ -0,0 +1,144 @@
+package callosum
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//Exporter receives the JSON blobs of users and tweets as they are stored
+//during collection. Set one with WithExporter.
+type Exporter interface {
+	ExportUser(blob []byte)
+	ExportTweet(blob []byte)
+	Close()
+}
+
+//CloseExporter closes the exporter set with WithExporter, if any, so that its
+//current files are complete. Tweets are exported once written, so it waits
+//for the queued writes first. StartCollection closes it once the collection
+//is complete, call it before shutting down otherwise. An exporter closed
+//before the tailing, see WithTailing, goes on with new files.
+func (t *TwitterCollector) CloseExporter() {
+	if t.exporter != nil {
+		t.s.flush()
+		t.exporter.Close()
+	}
+}
+
+//DefaultMaxExportFileSize is the size at which a JSONLExporter rolls over to a new file
+const DefaultMaxExportFileSize = 100 << 20
+
+//JSONLExporter appends users and tweets to rolling JSON lines files in a
+//directory, one object per line. Users go to files named users-<timestamp>.jsonl
+//and tweets to tweets-<timestamp>.jsonl. A file is rolled over once it grows
+//past MaxFileSize or is older than MaxFileAge, and the rolled over file is
+//handed to OnRoll, if set. OnRoll runs in its own goroutine, so that slow
+//uploads don't hold up the writes.
+type JSONLExporter struct {
+	Dir         string
+	MaxFileSize int64
+	MaxFileAge  time.Duration
+	OnRoll      func(fileName string)
+
+	mutex  sync.Mutex
+	rolls  sync.WaitGroup //the OnRoll calls in progress
+	users  *rollingFile
+	tweets *rollingFile
+}
+
+type rollingFile struct {
+	prefix    string
+	f         *os.File
+	size      int64
+	createdAt time.Time
+}
+
+//NewJSONLExporter returns a JSONLExporter writing into dir, creating the
+//directory if it is not already present.
+func NewJSONLExporter(dir string) *JSONLExporter {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &JSONLExporter{
+		Dir:         dir,
+		MaxFileSize: DefaultMaxExportFileSize,
+		users:       &rollingFile{prefix: "users"},
+		tweets:      &rollingFile{prefix: "tweets"},
+	}
+}
+
+//ExportUser appends the user blob to the current users file.
+func (e *JSONLExporter) ExportUser(blob []byte) {
+	e.write(e.users, blob)
+}
+
+//ExportTweet appends the tweet blob to the current tweets file.
+func (e *JSONLExporter) ExportTweet(blob []byte) {
+	e.write(e.tweets, blob)
+}
+
+//Close closes the current files, hands them to OnRoll and waits for the
+//OnRoll calls in progress to return.
+func (e *JSONLExporter) Close() {
+	e.mutex.Lock()
+	e.roll(e.users)
+	e.roll(e.tweets)
+	e.mutex.Unlock()
+	e.rolls.Wait()
+}
+
+func (e *JSONLExporter) write(rf *rollingFile, blob []byte) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if rf.f != nil && (rf.size >= e.MaxFileSize ||
+		(e.MaxFileAge > 0 && time.Since(rf.createdAt) >= e.MaxFileAge)) {
+		e.roll(rf)
+	}
+	if rf.f == nil {
+		e.open(rf)
+	}
+
+	line := make([]byte, 0, len(blob)+1)
+	line = append(append(line, blob...), '\n')
+	n, err := rf.f.Write(line)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rf.size += int64(n)
+}
+
+func (e *JSONLExporter) open(rf *rollingFile) {
+	rf.createdAt = time.Now().UTC()
+	fileName := filepath.Join(e.Dir, fmt.Sprintf("%s-%s.jsonl", rf.prefix, rf.createdAt.Format("20060102T150405.000000000")))
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rf.f = f
+	rf.size = 0
+}
+
+func (e *JSONLExporter) roll(rf *rollingFile) {
+	if rf.f == nil {
+		return
+	}
+	fileName := rf.f.Name()
+	err := rf.f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	rf.f = nil
+	if e.OnRoll != nil {
+		e.rolls.Add(1)
+		go func() {
+			defer e.rolls.Done()
+			e.OnRoll(fileName)
+		}()
+	}
+}
@@ -0,0 +1,47 @@
+package callosum
+
+import (
+	"log"
+	"sort"
+	"sync"
+)
+
+var filtersMutex = &sync.Mutex{}
+
+var filters = map[string]FilterUser{
+	"accept_all": AcceptAll,
+	"verified":   Verified,
+	"unverified": Unverified,
+	"not_spam":   NotSpam,
+}
+
+//RegisterFilter makes a FilterUser available under the given name so that
+//it can be referred to from a Config file.
+func RegisterFilter(name string, fu FilterUser) {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	if _, ok := filters[name]; ok {
+		log.Fatalf("filter %q is already registered", name)
+	}
+	filters[name] = fu
+}
+
+//LookupFilter returns the FilterUser registered under name and whether it was found.
+func LookupFilter(name string) (FilterUser, bool) {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	fu, ok := filters[name]
+	return fu, ok
+}
+
+//FilterNames returns the names of all the registered filters in sorted order.
+func FilterNames() []string {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	var names []string
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,151 @@
+package callosum
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+//Tokenizers of the full-text index, see FullTextConfig
+const (
+	//TokenizerUnicode61 splits text into words at spaces and punctuation,
+	//which suits languages that separate words with spaces.
+	TokenizerUnicode61 = "unicode61"
+	//TokenizerTrigram indexes every sequence of three characters, so that any
+	//substring of three characters or more is found, which suits Chinese,
+	//Japanese and Korean, where words are not separated by spaces.
+	TokenizerTrigram = "trigram"
+	//TokenizerICU splits text into words with the word boundaries of the
+	//locale given in Options, e.g. th_TH or ja_JP.
+	TokenizerICU = "icu"
+)
+
+//FullTextConfig sets up the full-text index of the text of tweets, see
+//WithFullTextSearch. The index has a single tokenizer, used for the tweets
+//of every language, so it is picked for the languages most of the corpus is
+//in.
+type FullTextConfig struct {
+	//Tokenizer is TokenizerUnicode61, TokenizerTrigram or TokenizerICU
+	Tokenizer string `yaml:"tokenizer"`
+	//Options are passed on to the tokenizer. For unicode61, e.g.
+	//`remove_diacritics 2` to match "café" with "cafe" and
+	//`tokenchars '#@'` to keep hashtags and mentions whole. For trigram,
+	//`case_sensitive 1`. For icu, the locale.
+	Options string `yaml:"options"`
+}
+
+//icuLocale is the form of the locales the ICU tokenizer takes
+var icuLocale = regexp.MustCompile(`^[A-Za-z_]*$`)
+
+//WithFullTextSearch makes NewStorage index the text of tweets in the
+//`tweets_fts` table, with the tokenizer in c, so that SearchTweets matches
+//words instead of scanning the text of every tweet. The index is filled from
+//the stored tweets when it is created, and rebuilt when the tokenizer is
+//changed, then kept up to date by triggers on the `tweets` table.
+//
+//go-sqlite3 only includes full-text search with the sqlite_fts5 build tag,
+//and the ICU tokenizer with the sqlite_icu build tag. Once the index is
+//created, the database can only be written to by builds with the tag.
+func WithFullTextSearch(c FullTextConfig) StorageOption {
+	return func(s *Storage) {
+		s.fullText = &c
+	}
+}
+
+//fullTextTable returns the statements creating the full-text index and the
+//triggers keeping it up to date. The unicode61 and trigram tokenizers come
+//with FTS5, while the ICU tokenizer is only available to FTS4.
+func (c *FullTextConfig) fullTextTable() (string, []string) {
+	switch c.Tokenizer {
+	case TokenizerUnicode61, TokenizerTrigram:
+		tokenize := strings.TrimSpace(c.Tokenizer + " " + c.Options)
+		return fmt.Sprintf(`CREATE VIRTUAL TABLE tweets_fts USING fts5("desc", content='tweets', content_rowid='tweet_id', tokenize="%s")`,
+				strings.Replace(tokenize, `"`, `""`, -1)),
+			[]string{
+				`CREATE TRIGGER tweets_fts_insert AFTER INSERT ON tweets BEGIN
+					INSERT INTO tweets_fts(rowid, "desc") VALUES (new.tweet_id, new."desc");
+				END`,
+				`CREATE TRIGGER tweets_fts_delete AFTER DELETE ON tweets BEGIN
+					INSERT INTO tweets_fts(tweets_fts, rowid, "desc") VALUES ('delete', old.tweet_id, old."desc");
+				END`,
+				`CREATE TRIGGER tweets_fts_update AFTER UPDATE OF "desc" ON tweets BEGIN
+					INSERT INTO tweets_fts(tweets_fts, rowid, "desc") VALUES ('delete', old.tweet_id, old."desc");
+					INSERT INTO tweets_fts(rowid, "desc") VALUES (new.tweet_id, new."desc");
+				END`,
+			}
+	case TokenizerICU:
+		if !icuLocale.MatchString(c.Options) {
+			log.Fatalf("full-text search: %q is not an ICU locale", c.Options)
+		}
+		return fmt.Sprintf(`CREATE VIRTUAL TABLE tweets_fts USING fts4(content="tweets", "desc", tokenize=%s)`,
+				strings.TrimSpace(c.Tokenizer+" "+c.Options)),
+			[]string{
+				`CREATE TRIGGER tweets_fts_insert AFTER INSERT ON tweets BEGIN
+					INSERT INTO tweets_fts(docid, "desc") VALUES (new.tweet_id, new."desc");
+				END`,
+				`CREATE TRIGGER tweets_fts_delete BEFORE DELETE ON tweets BEGIN
+					DELETE FROM tweets_fts WHERE docid=old.tweet_id;
+				END`,
+				`CREATE TRIGGER tweets_fts_update_before BEFORE UPDATE OF "desc" ON tweets BEGIN
+					DELETE FROM tweets_fts WHERE docid=old.tweet_id;
+				END`,
+				`CREATE TRIGGER tweets_fts_update AFTER UPDATE OF "desc" ON tweets BEGIN
+					INSERT INTO tweets_fts(docid, "desc") VALUES (new.tweet_id, new."desc");
+				END`,
+			}
+	}
+	log.Fatalf("full-text search: unknown tokenizer %q, the tokenizers are %s, %s and %s",
+		c.Tokenizer, TokenizerUnicode61, TokenizerTrigram, TokenizerICU)
+	return "", nil
+}
+
+//setupFullText creates the full-text index, or recreates it when it was
+//created with another tokenizer, and fills it from the stored tweets.
+func (s *Storage) setupFullText() {
+	table, triggers := s.fullText.fullTextTable()
+	existing := s.fullTextTable()
+	if existing == table {
+		return
+	}
+	if existing != "" {
+		s.makeTable("tweets_fts", "DROP TRIGGER IF EXISTS tweets_fts_insert")
+		s.makeTable("tweets_fts", "DROP TRIGGER IF EXISTS tweets_fts_delete")
+		s.makeTable("tweets_fts", "DROP TRIGGER IF EXISTS tweets_fts_update_before")
+		s.makeTable("tweets_fts", "DROP TRIGGER IF EXISTS tweets_fts_update")
+		s.makeTable("tweets_fts", "DROP TABLE tweets_fts")
+	}
+	s.makeTable("tweets_fts", table)
+	for _, trigger := range triggers {
+		s.makeTable("tweets_fts", trigger)
+	}
+	log.Printf("indexing the text of the stored tweets with the %s tokenizer", s.fullText.Tokenizer)
+	s.makeTable("tweets_fts", "INSERT INTO tweets_fts(tweets_fts) VALUES ('rebuild')")
+}
+
+//fullTextTable returns the statement the full-text index was created with,
+//or an empty string when there is no index.
+func (s *Storage) fullTextTable() string {
+	var table string
+	err := s.db.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name='tweets_fts'").Scan(&table)
+	if err != nil && err != sql.ErrNoRows {
+		log.Fatal(err)
+	}
+	return table
+}
+
+//searchFullText gets up to limit tweets containing text as a phrase from the
+//full-text index, most recent first. It returns false when the database had
+//no index when it was opened, or the text is too short for the trigram
+//tokenizer to match.
+func (s *Storage) searchFullText(text string, limit int) (Tweets, bool) {
+	if !s.fullTextIndexed || utf8.RuneCountInString(strings.TrimSpace(text)) < 3 {
+		return nil, false
+	}
+	phrase := `"` + strings.Replace(text, `"`, `""`, -1) + `"`
+	return s.queryTweets(`SELECT tweet_id, created_at, langugage, "desc", blob FROM tweets
+		WHERE tweet_id IN (SELECT rowid FROM tweets_fts WHERE tweets_fts MATCH ?)
+		ORDER BY tweet_id DESC LIMIT ?`, phrase, limit), true
+}
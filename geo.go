@@ -0,0 +1,185 @@
+package callosum
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+)
+
+//Place is a Twitter place a tweet was tagged with. Latitude and Longitude
+//are the center of the place's bounding box. ParentID is the place it is
+//contained within and Blob the place object, once the place was looked up,
+//see LookupPlaces.
+type Place struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	FullName    string  `json:"full_name"`
+	PlaceType   string  `json:"place_type"`
+	CountryCode string  `json:"country_code"`
+	Country     string  `json:"country"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	ParentID    string  `json:"parent_id,omitempty"`
+	Blob        []byte  `json:"-"`
+}
+
+//placeObject holds the fields of a place object of Twitter's API stored in
+//the `places` table. GeoJSON bounding boxes are [longitude, latitude].
+type placeObject struct {
+	Place
+	BoundingBox *struct {
+		Coordinates [][][]float64 `json:"coordinates"`
+	} `json:"bounding_box"`
+}
+
+//storePlace stores the place in the `places` table, with the center of its
+//bounding box. The details of places that were looked up are kept.
+func (s *Storage) storePlace(p *placeObject) {
+	var boundingBox []byte
+	if p.BoundingBox != nil && len(p.BoundingBox.Coordinates) > 0 {
+		ring := p.BoundingBox.Coordinates[0]
+		for _, point := range ring {
+			if len(point) == 2 {
+				p.Longitude += point[0] / float64(len(ring))
+				p.Latitude += point[1] / float64(len(ring))
+			}
+		}
+		boundingBox = mustMarshal(p.BoundingBox.Coordinates)
+	}
+	chQueryArgs <- &queryArgs{`INSERT INTO places (place_id, name, full_name, place_type, country_code, country, latitude, longitude, bounding_box)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (place_id) DO UPDATE SET name=excluded.name, full_name=excluded.full_name, place_type=excluded.place_type,
+			country_code=excluded.country_code, country=excluded.country, latitude=excluded.latitude,
+			longitude=excluded.longitude, bounding_box=excluded.bounding_box`,
+		[]interface{}{p.ID, p.Name, p.FullName, p.PlaceType, p.CountryCode, p.Country, p.Latitude, p.Longitude, string(boundingBox)}}
+}
+
+//tweetLocation holds the location fields of a tweet blob. GeoJSON points
+//and bounding boxes are [longitude, latitude].
+type tweetLocation struct {
+	Coordinates *struct {
+		Coordinates []float64 `json:"coordinates"`
+	} `json:"coordinates"`
+	Place *placeObject `json:"place"`
+}
+
+//storeLocation extracts the exact coordinates and the place of the tweet
+//from its blob, if it was geotagged.
+func (s *Storage) storeLocation(tweetID, userID int64, blob []byte) {
+	var t tweetLocation
+	if json.Unmarshal(blob, &t) != nil {
+		return
+	}
+	var latitude, longitude interface{}
+	if t.Coordinates != nil && len(t.Coordinates.Coordinates) == 2 {
+		longitude, latitude = t.Coordinates.Coordinates[0], t.Coordinates.Coordinates[1]
+	}
+	var placeID interface{}
+	if p := t.Place; p != nil && p.ID != "" {
+		placeID = p.ID
+		s.storePlace(p)
+	}
+	if latitude != nil || placeID != nil {
+		chQueryArgs <- &queryArgs{"UPDATE tweets SET latitude=?, longitude=?, place_id=? WHERE tweet_id=?",
+			[]interface{}{latitude, longitude, placeID, tweetID}}
+	}
+}
+
+//BackfillPlaces extracts the locations of all the stored tweets, for tweets
+//stored before locations were extracted. It returns the number of tweets read.
+func (s *Storage) BackfillPlaces() int {
+	return s.eachTweetBlob(s.storeLocation)
+}
+
+//GeoQuery selects geotagged tweets for GetGeotaggedTweets. Zero fields don't
+//restrict the results. The bounding box applies to the exact coordinates of
+//tweets that have them and to the center of the place for the others.
+type GeoQuery struct {
+	MinLatitude, MaxLatitude   float64
+	MinLongitude, MaxLongitude float64
+	CountryCode                string
+	//PlaceID also matches the places contained within the place at any
+	//depth, such as the cities of a country, once they are looked up, see
+	//LookupPlaces.
+	PlaceID string
+	//ExactOnly leaves out tweets tagged with a place but no exact coordinates
+	ExactOnly bool
+	Limit     int
+}
+
+//GeoTweet is a geotagged tweet. Latitude and Longitude are the tweet's exact
+//coordinates, or the center of its place when Exact is not set.
+type GeoTweet struct {
+	TweetID   int64
+	UserID    int64
+	CreatedAt time.Time
+	Latitude  float64
+	Longitude float64
+	Exact     bool
+	Place     *Place
+}
+
+//GetGeotaggedTweets gets the geotagged tweets matching q, most recent first.
+func (s *Storage) GetGeotaggedTweets(q GeoQuery) []GeoTweet {
+	query := `SELECT tweets.tweet_id, tweets.user_id, tweets.created_at,
+			COALESCE(tweets.latitude, places.latitude), COALESCE(tweets.longitude, places.longitude),
+			tweets.latitude IS NOT NULL,
+			places.place_id, places.name, places.full_name, places.place_type, places.country_code, places.country,
+			places.latitude, places.longitude
+		FROM tweets LEFT JOIN places ON places.place_id = tweets.place_id`
+	conditions := []string{"(tweets.latitude IS NOT NULL OR places.place_id IS NOT NULL)"}
+	var args []interface{}
+	if q.ExactOnly {
+		conditions = append(conditions, "tweets.latitude IS NOT NULL")
+	}
+	if q.MinLatitude != 0 || q.MaxLatitude != 0 {
+		conditions = append(conditions, "COALESCE(tweets.latitude, places.latitude) BETWEEN ? AND ?")
+		args = append(args, q.MinLatitude, q.MaxLatitude)
+	}
+	if q.MinLongitude != 0 || q.MaxLongitude != 0 {
+		conditions = append(conditions, "COALESCE(tweets.longitude, places.longitude) BETWEEN ? AND ?")
+		args = append(args, q.MinLongitude, q.MaxLongitude)
+	}
+	if q.CountryCode != "" {
+		conditions = append(conditions, "places.country_code=?")
+		args = append(args, q.CountryCode)
+	}
+	if q.PlaceID != "" {
+		conditions = append(conditions, `tweets.place_id IN (WITH RECURSIVE within(place_id) AS (
+				SELECT ? UNION SELECT p.place_id FROM places p JOIN within ON p.parent_id = within.place_id)
+			SELECT place_id FROM within)`)
+		args = append(args, q.PlaceID)
+	}
+	query += " WHERE " + strings.Join(conditions, " AND ") + " ORDER BY tweets.tweet_id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var tweets []GeoTweet
+	for rows.Next() {
+		var t GeoTweet
+		var createdAt int64
+		var placeID, name, fullName, placeType, countryCode, country *string
+		var placeLatitude, placeLongitude *float64
+		err = rows.Scan(&t.TweetID, &t.UserID, &createdAt, &t.Latitude, &t.Longitude, &t.Exact,
+			&placeID, &name, &fullName, &placeType, &countryCode, &country, &placeLatitude, &placeLongitude)
+		if err != nil {
+			log.Fatal(err)
+		}
+		t.CreatedAt = time.Unix(createdAt, 0).UTC()
+		if placeID != nil {
+			t.Place = &Place{ID: *placeID, Name: *name, FullName: *fullName, PlaceType: *placeType,
+				CountryCode: *countryCode, Country: *country, Latitude: *placeLatitude, Longitude: *placeLongitude}
+		}
+		tweets = append(tweets, t)
+	}
+	return tweets
+}
@@ -0,0 +1,128 @@
+package callosum
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+)
+
+//Node is a user in a Graph along with some of the attributes from their
+//Twitter user object.
+type Node struct {
+	ID             int64  `json:"id"`
+	ScreenName     string `json:"screen_name"`
+	Description    string `json:"description"`
+	FollowersCount int    `json:"followers_count"`
+	FriendsCount   int    `json:"friends_count"`
+	StatusesCount  int    `json:"statuses_count"`
+}
+
+//Graph is an in-memory follow graph. An edge from A to B means A follows B.
+type Graph struct {
+	Nodes map[int64]*Node
+	//Out holds the IDs each user follows
+	Out map[int64][]int64
+	//In holds the IDs following each user
+	In map[int64][]int64
+}
+
+//NewGraph returns an empty Graph.
+func NewGraph() *Graph {
+	return &Graph{
+		Nodes: make(map[int64]*Node),
+		Out:   make(map[int64][]int64),
+		In:    make(map[int64][]int64),
+	}
+}
+
+//AddEdge adds an edge from the follower to the followed user. Both
+//users need to be in Nodes.
+func (g *Graph) AddEdge(from, to int64) {
+	g.Out[from] = append(g.Out[from], to)
+	g.In[to] = append(g.In[to], from)
+}
+
+//NodeIDs returns the IDs of all the nodes in ascending order.
+func (g *Graph) NodeIDs() []int64 {
+	IDs := make([]int64, 0, len(g.Nodes))
+	for ID := range g.Nodes {
+		IDs = append(IDs, ID)
+	}
+	sort.Slice(IDs, func(i, j int) bool { return IDs[i] < IDs[j] })
+	return IDs
+}
+
+//EdgeCount returns the number of edges in the graph.
+func (g *Graph) EdgeCount() int {
+	var count int
+	for _, out := range g.Out {
+		count += len(out)
+	}
+	return count
+}
+
+//Graph loads the follow edges from the `following` and `followers` tables
+//between users accepted by the filter function into an in-memory Graph,
+//along with the attributes of those users. It is the starting point of the
+//analysis utilities.
+func (s *Storage) Graph() *Graph {
+	return s.loadGraph(true)
+}
+
+//FullGraph is like Graph but keeps every stored edge, adding the users at
+//either end of them that are not accepted, or not even collected, as nodes.
+func (s *Storage) FullGraph() *Graph {
+	return s.loadGraph(false)
+}
+
+func (s *Storage) loadGraph(acceptedOnly bool) *Graph {
+	g := NewGraph()
+
+	query := "SELECT user_id, COALESCE(screen_name, ''), description, blob FROM users"
+	if acceptedOnly {
+		query += " WHERE accepted=1"
+	}
+	rows, err := s.db.Query(query)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		n := &Node{}
+		var blob []byte
+		err = rows.Scan(&n.ID, &n.ScreenName, &n.Description, &blob)
+		if err != nil {
+			log.Fatal(err)
+		}
+		json.Unmarshal(blob, n)
+		g.Nodes[n.ID] = n
+	}
+	rows.Close()
+
+	rows, err = s.db.Query(`
+		SELECT user_id, following_id FROM following
+		UNION
+		SELECT follower_id, user_id FROM followers`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var from, to int64
+		err = rows.Scan(&from, &to)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !acceptedOnly {
+			for _, ID := range []int64{from, to} {
+				if g.Nodes[ID] == nil {
+					g.Nodes[ID] = &Node{ID: ID}
+				}
+			}
+		}
+		if g.Nodes[from] != nil && g.Nodes[to] != nil {
+			g.AddEdge(from, to)
+		}
+	}
+	return g
+}
@@ -0,0 +1,79 @@
+package callosum
+
+//GraphStats summarizes the shape of a Graph.
+type GraphStats struct {
+	Nodes int
+	Edges int
+	//Density is the fraction of all possible edges present in the graph
+	Density float64
+	//InDegrees maps an in-degree to the number of nodes with that in-degree
+	InDegrees map[int]int
+	//OutDegrees maps an out-degree to the number of nodes with that out-degree
+	OutDegrees   map[int]int
+	MaxInDegree  int
+	MaxOutDegree int
+	//LargestComponent is the number of nodes in the largest weakly connected component
+	LargestComponent int
+	Components       int
+}
+
+//Stats computes the summary statistics of the graph.
+func (g *Graph) Stats() *GraphStats {
+	stats := &GraphStats{
+		Nodes:      len(g.Nodes),
+		Edges:      g.EdgeCount(),
+		InDegrees:  make(map[int]int),
+		OutDegrees: make(map[int]int),
+	}
+	if stats.Nodes > 1 {
+		stats.Density = float64(stats.Edges) / float64(stats.Nodes*(stats.Nodes-1))
+	}
+
+	for ID := range g.Nodes {
+		in, out := len(g.In[ID]), len(g.Out[ID])
+		stats.InDegrees[in]++
+		stats.OutDegrees[out]++
+		if in > stats.MaxInDegree {
+			stats.MaxInDegree = in
+		}
+		if out > stats.MaxOutDegree {
+			stats.MaxOutDegree = out
+		}
+	}
+
+	seen := make(map[int64]bool, len(g.Nodes))
+	for ID := range g.Nodes {
+		if seen[ID] {
+			continue
+		}
+		stats.Components++
+		size := 0
+		stack := []int64{ID}
+		seen[ID] = true
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			size++
+			for _, neighbours := range [][]int64{g.Out[current], g.In[current]} {
+				for _, neighbour := range neighbours {
+					if !seen[neighbour] {
+						seen[neighbour] = true
+						stack = append(stack, neighbour)
+					}
+				}
+			}
+		}
+		if size > stats.LargestComponent {
+			stats.LargestComponent = size
+		}
+	}
+	return stats
+}
+
+//GraphStats computes the summary statistics over all the stored follow
+//edges, including the ones to users who were not accepted, as a quick
+//sanity check of what the crawl captured. Use Graph().Stats() for the
+//graph between accepted users only.
+func (s *Storage) GraphStats() *GraphStats {
+	return s.FullGraph().Stats()
+}
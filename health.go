@@ -0,0 +1,155 @@
+package callosum
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//How stale the writer and the API calls may get before the collector is
+//reported as unhealthy, and how long a credentials check is trusted for.
+//HealthMaxAPISilence is counted past the longest time the configured
+//intervals leave between two calls, see Health.
+const (
+	HealthMaxWriterSilence = 1 * time.Minute
+	HealthMaxAPISilence    = 1 * time.Hour
+	credentialsCheckTTL    = 5 * time.Minute
+)
+
+//Health is the report served by HealthHandler.
+type Health struct {
+	Healthy          bool      `json:"healthy"`
+	WriterAlive      bool      `json:"writer_alive"`
+	WriterQueued     int       `json:"writer_queued"`
+	LastWriteAt      time.Time `json:"last_write_at"`
+	DBWritable       bool      `json:"db_writable"`
+	DBError          string    `json:"db_error,omitempty"`
+	CredentialsValid bool      `json:"credentials_valid"`
+	CredentialsError string    `json:"credentials_error,omitempty"`
+	LastAPICallAt    time.Time `json:"last_api_call_at"`
+	//Paused is set while reading tweets is paused until the next month, as
+	//they got close to the monthly cap, see WithMonthlyCap.
+	Paused bool `json:"paused"`
+}
+
+//credentialsVerifier is implemented by APIs that can check their credentials
+type credentialsVerifier interface {
+	VerifyCredentials() error
+}
+
+//lastCaller is implemented by APIs that keep track of their last successful call
+type lastCaller interface {
+	LastSuccessfulCall() time.Time
+}
+
+type credentialsCheck struct {
+	mutex     sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+//Health checks that the writer goroutine is alive, the database is writable,
+//the credentials are valid and that Twitter's API was called successfully recently.
+//
+//The writer is considered wedged when statements are queued up but none was
+//executed for HealthMaxWriterSilence. The API is considered unreachable when
+//no call succeeded for HealthMaxAPISilence past the longest interval of the
+//phases run, or of the tailing backoff once the collection is complete, and
+//never while paused for the monthly cap. Credentials checks use up API calls,
+//so their result is reused for a few minutes.
+func (t *TwitterCollector) Health() *Health {
+	h := &Health{}
+
+	h.WriterQueued, h.LastWriteAt = t.s.WriterStatus()
+	h.WriterAlive = h.WriterQueued == 0 || time.Since(h.LastWriteAt) < HealthMaxWriterSilence
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := t.s.CheckWritable(ctx); err != nil {
+		h.DBError = err.Error()
+	} else {
+		h.DBWritable = true
+	}
+
+	h.CredentialsValid = true
+	if v, ok := t.n.(credentialsVerifier); ok {
+		t.credentials.mutex.Lock()
+		if time.Since(t.credentials.checkedAt) > credentialsCheckTTL {
+			t.countRequest(UsageHealth, "account/verify_credentials")
+			t.credentials.err = v.VerifyCredentials()
+			t.credentials.checkedAt = time.Now()
+		}
+		if t.credentials.err != nil {
+			h.CredentialsValid = false
+			h.CredentialsError = t.credentials.err.Error()
+		}
+		t.credentials.mutex.Unlock()
+	}
+
+	_, h.Paused = t.budgetPaused()
+	apiAlive := true
+	if c, ok := t.n.(lastCaller); ok {
+		h.LastAPICallAt = c.LastSuccessfulCall()
+		apiAlive = h.Paused || h.LastAPICallAt.IsZero() || time.Since(h.LastAPICallAt) < t.maxAPISilence()
+	}
+
+	h.Healthy = h.WriterAlive && h.DBWritable && h.CredentialsValid && apiAlive
+	return h
+}
+
+//maxAPISilence returns how long the API may go without a successful call:
+//HealthMaxAPISilence past the longest interval of the phases run, as the
+//phases left with work may be those repeated the least, such as
+//PhaseRefresh, or once the collection is complete, past the longest time
+//the tailing backs off a user.
+func (t *TwitterCollector) maxAPISilence() time.Duration {
+	var longest time.Duration
+	select {
+	case <-t.done:
+		longest = tailMaxBackoff * t.tailInterval
+	default:
+		intervals := map[string]time.Duration{
+			PhaseUsers:       t.intervals.Users,
+			PhaseTweets:      t.intervals.Tweets,
+			PhaseFriends:     t.intervals.Friends,
+			PhaseFollowers:   t.intervals.Followers,
+			PhaseRefresh:     t.intervals.Refresh,
+			PhaseMemberships: t.intervals.Memberships,
+			PhaseLists:       t.intervals.Lists,
+			PhaseAccount:     t.intervals.Account,
+		}
+		for phase, interval := range intervals {
+			if t.phases[phase] && interval > longest {
+				longest = interval
+			}
+		}
+		if t.protectedRecheckInterval > longest {
+			longest = t.protectedRecheckInterval
+		}
+	}
+	return longest + HealthMaxAPISilence
+}
+
+//HealthHandler serves the Health report as JSON, with a 503 status code
+//when the collector is unhealthy, for orchestrators to restart wedged crawlers.
+func (t *TwitterCollector) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := t.Health()
+		w.Header().Set("Content-Type", "application/json")
+		if !h.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+}
+
+func (t *TwitterCollector) serveHealth() {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", t.HealthHandler())
+	err := http.ListenAndServe(t.healthAddr, mux)
+	if err != nil {
+		t.logger.Printf("health check server: %s", err)
+	}
+}
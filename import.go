@@ -0,0 +1,94 @@
+package callosum
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+//importRecord holds the fields used to tell tweets from users in a JSON dump
+type importRecord struct {
+	ID         int64           `json:"id"`
+	ScreenName string          `json:"screen_name"`
+	CreatedAt  string          `json:"created_at"`
+	Text       *string         `json:"text"`
+	FullText   *string         `json:"full_text"`
+	User       json.RawMessage `json:"user"`
+}
+
+//ImportJSONL stores the line delimited tweet and user objects of Twitter's
+//v1.1 API read from r, such as dumps of twarc or older collections, so prior
+//datasets can be merged into the corpus. Tweets and users already in the
+//database are left as they are. The user objects embedded in tweets are
+//stored too. Imported users are filtered like collected users and imported
+//tweets are annotated and exported. Lines that are neither tweets nor users
+//are counted as skipped. Large imports are followed by Analyze.
+func (t *TwitterCollector) ImportJSONL(r io.Reader) (*ImportStats, error) {
+	stats := &ImportStats{}
+	defer t.analyzeImport(stats)
+	seenUsers := make(map[int64]bool)
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			t.importLine(line, stats, seenUsers)
+		}
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+	}
+}
+
+func (t *TwitterCollector) importLine(line []byte, stats *ImportStats, seenUsers map[int64]bool) {
+	var record importRecord
+	if len(bytes.TrimSpace(line)) == 0 {
+		return
+	}
+	if json.Unmarshal(line, &record) != nil || record.ID == 0 {
+		stats.Skipped++
+		return
+	}
+
+	switch {
+	case record.User != nil && (record.Text != nil || record.FullText != nil):
+		var u User
+		if json.Unmarshal(record.User, &u) != nil || u.ID == 0 {
+			stats.Skipped++
+			return
+		}
+		tweet := &Tweet{Blob: append([]byte(nil), bytes.TrimSpace(line)...)}
+		json.Unmarshal(line, tweet)
+		if tweet.Text == "" && record.FullText != nil {
+			tweet.Text = *record.FullText
+		}
+		if _, err := time.Parse(time.RubyDate, tweet.CreatedAt); err != nil {
+			stats.Skipped++
+			return
+		}
+		//tweets of the v1.1 API carry the full user object
+		if u.ScreenName != "" && !seenUsers[u.ID] {
+			seenUsers[u.ID] = true
+			u.Blob = append([]byte(nil), record.User...)
+			t.importUser(&u)
+			stats.Users++
+		}
+		t.storeTweet(u.ID, tweet)
+		stats.Tweets++
+	case record.ScreenName != "":
+		if seenUsers[record.ID] {
+			return
+		}
+		seenUsers[record.ID] = true
+		u := &User{Blob: append([]byte(nil), bytes.TrimSpace(line)...)}
+		json.Unmarshal(line, u)
+		t.importUser(u)
+		stats.Users++
+	default:
+		stats.Skipped++
+	}
+}
@@ -0,0 +1,199 @@
+package callosum
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+//Attachment types of the `attachments` table
+const (
+	AttachmentMedia = "media"
+	AttachmentPoll  = "poll"
+)
+
+//v2Includes holds the expansions of a twarc2 response page as they were
+//returned, so that storeIncludes keeps the fields lost in the conversion of
+//the page's tweets to v1.1 objects.
+type v2Includes struct {
+	Data     []json.RawMessage `json:"data"`
+	Includes struct {
+		Users  []json.RawMessage `json:"users"`
+		Tweets []json.RawMessage `json:"tweets"`
+		Media  []json.RawMessage `json:"media"`
+		Polls  []json.RawMessage `json:"polls"`
+	} `json:"includes"`
+}
+
+//v2Attached holds the fields of a v2 tweet used to link it to its media and
+//polls.
+type v2Attached struct {
+	ID          string `json:"id"`
+	AuthorID    string `json:"author_id"`
+	CreatedAt   string `json:"created_at"`
+	Text        string `json:"text"`
+	Attachments *struct {
+		MediaKeys []string `json:"media_keys"`
+		PollIDs   []string `json:"poll_ids"`
+	} `json:"attachments"`
+}
+
+//Media is a photo, video or animated GIF attached to a tweet, as expanded
+//in the includes of the v2 API. URL is the preview image of videos.
+type Media struct {
+	Key     string
+	Type    string
+	URL     string
+	AltText string
+	Blob    []byte
+}
+
+//PollOption is one of the choices of a Poll.
+type PollOption struct {
+	Position int    `json:"position"`
+	Label    string `json:"label"`
+	Votes    int    `json:"votes"`
+}
+
+//Poll is a poll attached to a tweet, as expanded in the includes of the v2
+//API.
+type Poll struct {
+	ID              int64        `json:"-"`
+	Options         []PollOption `json:"options"`
+	VotingStatus    string       `json:"voting_status"`
+	DurationMinutes int          `json:"duration_minutes"`
+	EndsAt          time.Time    `json:"-"`
+	Blob            []byte       `json:"-"`
+}
+
+//storeIncludes stores the expansions of a twarc2 response page in their own
+//tables: referenced tweets in `included_tweets`, users in `included_users`,
+//media in `media` and polls in `polls`, with the page's tweets, and the
+//referenced ones, linked to their media and polls in `attachments`. The
+//objects are stored as v2 blobs. It returns the number of objects stored,
+//0 for lines that are not v2 response pages.
+func (s *Storage) storeIncludes(line []byte) int {
+	var page v2Includes
+	if json.Unmarshal(line, &page) != nil {
+		return 0
+	}
+	count := 0
+	for _, blob := range page.Includes.Tweets {
+		var tweet v2Attached
+		if json.Unmarshal(blob, &tweet) != nil || v2ID(tweet.ID) == nil {
+			continue
+		}
+		var createdAt int64
+		if t, err := time.Parse(time.RFC3339Nano, tweet.CreatedAt); err == nil {
+			createdAt = t.Unix()
+		}
+		chQueryArgs <- &queryArgs{`INSERT OR REPLACE INTO included_tweets (tweet_id, user_id, created_at, text, blob)
+			VALUES (?, ?, ?, ?, ?)`,
+			[]interface{}{v2ID(tweet.ID), v2ID(tweet.AuthorID), createdAt, tweet.Text, []byte(blob)}}
+		count++
+	}
+	for _, blob := range append(page.Data, page.Includes.Tweets...) {
+		var tweet v2Attached
+		if json.Unmarshal(blob, &tweet) != nil || v2ID(tweet.ID) == nil || tweet.Attachments == nil {
+			continue
+		}
+		for _, key := range tweet.Attachments.MediaKeys {
+			chQueryArgs <- &queryArgs{"INSERT OR IGNORE INTO attachments (tweet_id, attachment_type, attachment_key) VALUES (?, ?, ?)",
+				[]interface{}{v2ID(tweet.ID), AttachmentMedia, key}}
+		}
+		for _, ID := range tweet.Attachments.PollIDs {
+			chQueryArgs <- &queryArgs{"INSERT OR IGNORE INTO attachments (tweet_id, attachment_type, attachment_key) VALUES (?, ?, ?)",
+				[]interface{}{v2ID(tweet.ID), AttachmentPoll, ID}}
+		}
+	}
+	for _, blob := range page.Includes.Users {
+		var u v2User
+		if json.Unmarshal(blob, &u) != nil || v2ID(u.ID) == nil {
+			continue
+		}
+		chQueryArgs <- &queryArgs{"INSERT OR REPLACE INTO included_users (user_id, screen_name, blob) VALUES (?, ?, ?)",
+			[]interface{}{v2ID(u.ID), u.Username, []byte(blob)}}
+		count++
+	}
+	for _, blob := range page.Includes.Media {
+		var m struct {
+			Key             string `json:"media_key"`
+			Type            string `json:"type"`
+			URL             string `json:"url"`
+			PreviewImageURL string `json:"preview_image_url"`
+			AltText         string `json:"alt_text"`
+		}
+		if json.Unmarshal(blob, &m) != nil || m.Key == "" {
+			continue
+		}
+		if m.URL == "" {
+			m.URL = m.PreviewImageURL
+		}
+		chQueryArgs <- &queryArgs{"INSERT OR REPLACE INTO media (media_key, type, url, alt_text, blob) VALUES (?, ?, ?, ?, ?)",
+			[]interface{}{m.Key, m.Type, m.URL, m.AltText, []byte(blob)}}
+		count++
+	}
+	for _, blob := range page.Includes.Polls {
+		var p struct {
+			ID           string `json:"id"`
+			VotingStatus string `json:"voting_status"`
+			EndDatetime  string `json:"end_datetime"`
+		}
+		if json.Unmarshal(blob, &p) != nil || v2ID(p.ID) == nil {
+			continue
+		}
+		var endsAt int64
+		if t, err := time.Parse(time.RFC3339Nano, p.EndDatetime); err == nil {
+			endsAt = t.Unix()
+		}
+		chQueryArgs <- &queryArgs{"INSERT OR REPLACE INTO polls (poll_id, voting_status, ends_at, blob) VALUES (?, ?, ?, ?)",
+			[]interface{}{v2ID(p.ID), p.VotingStatus, endsAt, []byte(blob)}}
+		count++
+	}
+	return count
+}
+
+//GetTweetMedia gets the media attached to a tweet, for tweets imported
+//with their v2 includes.
+func (s *Storage) GetTweetMedia(tweetID int64) []Media {
+	rows, err := s.db.Query(`SELECT media.media_key, media.type, media.url, media.alt_text, media.blob
+		FROM attachments JOIN media ON media.media_key = attachments.attachment_key
+		WHERE attachments.tweet_id=? AND attachments.attachment_type=?`, tweetID, AttachmentMedia)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var media []Media
+	for rows.Next() {
+		var m Media
+		err = rows.Scan(&m.Key, &m.Type, &m.URL, &m.AltText, &m.Blob)
+		if err != nil {
+			log.Fatal(err)
+		}
+		media = append(media, m)
+	}
+	return media
+}
+
+//GetTweetPoll gets the poll attached to a tweet, or nil if it has none or
+//it was not imported with its v2 includes.
+func (s *Storage) GetTweetPoll(tweetID int64) *Poll {
+	p := &Poll{}
+	var endsAt int64
+	err := s.db.QueryRow(`SELECT polls.poll_id, polls.voting_status, polls.ends_at, polls.blob
+		FROM attachments JOIN polls ON polls.poll_id = attachments.attachment_key
+		WHERE attachments.tweet_id=? AND attachments.attachment_type=?`, tweetID, AttachmentPoll).Scan(&p.ID, &p.VotingStatus, &endsAt, &p.Blob)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	json.Unmarshal(p.Blob, p)
+	if endsAt != 0 {
+		p.EndsAt = time.Unix(endsAt, 0)
+	}
+	return p
+}
@@ -0,0 +1,161 @@
+package callosum
+
+import (
+	"encoding/json"
+	"log"
+)
+
+//tweetInteractions holds the fields of a tweet blob that link it to other
+//users' tweets.
+type tweetInteractions struct {
+	RetweetedStatus *struct {
+		ID   int64 `json:"id"`
+		User struct {
+			ID int64 `json:"id"`
+		} `json:"user"`
+	} `json:"retweeted_status"`
+	QuotedStatusID int64 `json:"quoted_status_id"`
+	QuotedStatus   *struct {
+		User struct {
+			ID int64 `json:"id"`
+		} `json:"user"`
+	} `json:"quoted_status"`
+	InReplyToStatusID int64         `json:"in_reply_to_status_id"`
+	InReplyToUserID   int64         `json:"in_reply_to_user_id"`
+	Entities          tweetEntities `json:"entities"`
+	ExtendedTweet     *struct {
+		Entities tweetEntities `json:"entities"`
+	} `json:"extended_tweet"`
+}
+
+type tweetEntities struct {
+	UserMentions []struct {
+		ID int64 `json:"id"`
+	} `json:"user_mentions"`
+}
+
+//storeInteractions extracts the interactions of the tweet with other users
+//from its blob into the interaction tables. Blobs that can not be parsed are
+//skipped, the tweet itself is still stored.
+func (s *Storage) storeInteractions(tweetID, userID int64, blob []byte) {
+	var t tweetInteractions
+	if json.Unmarshal(blob, &t) != nil {
+		return
+	}
+	if rt := t.RetweetedStatus; rt != nil && rt.User.ID != 0 {
+		chQueryArgs <- &queryArgs{"INSERT OR IGNORE INTO retweets (tweet_id, retweeter_id, original_author_id, original_tweet_id) VALUES (?, ?, ?, ?)",
+			[]interface{}{tweetID, userID, rt.User.ID, rt.ID}}
+	}
+	if t.QuotedStatusID != 0 {
+		//the quoted tweet is left out of the blob when it has been deleted
+		var quotedUserID int64
+		if t.QuotedStatus != nil {
+			quotedUserID = t.QuotedStatus.User.ID
+		}
+		chQueryArgs <- &queryArgs{"INSERT OR IGNORE INTO quote_edges (tweet_id, quoting_user_id, quoted_user_id, quoted_tweet_id) VALUES (?, ?, ?, ?)",
+			[]interface{}{tweetID, userID, quotedUserID, t.QuotedStatusID}}
+	}
+	if t.InReplyToUserID != 0 {
+		chQueryArgs <- &queryArgs{"INSERT OR IGNORE INTO replies (tweet_id, from_user_id, to_user_id, in_reply_to_tweet_id) VALUES (?, ?, ?, ?)",
+			[]interface{}{tweetID, userID, t.InReplyToUserID, t.InReplyToStatusID}}
+	}
+	mentions := t.Entities.UserMentions
+	if t.ExtendedTweet != nil {
+		mentions = append(mentions, t.ExtendedTweet.Entities.UserMentions...)
+	}
+	for _, mention := range mentions {
+		if mention.ID != 0 {
+			chQueryArgs <- &queryArgs{"INSERT OR IGNORE INTO mentions (tweet_id, from_user_id, to_user_id) VALUES (?, ?, ?)",
+				[]interface{}{tweetID, userID, mention.ID}}
+		}
+	}
+}
+
+//BackfillInteractions extracts the interaction tables from the blobs of all
+//the stored tweets, for tweets stored before the tables were added. New
+//tweets are handled as they are stored. It returns the number of tweets read.
+func (s *Storage) BackfillInteractions() int {
+	return s.eachTweetBlob(s.storeInteractions)
+}
+
+//eachTweetBlob calls handle with the blob of every stored tweet and returns
+//the number of tweets.
+func (s *Storage) eachTweetBlob(handle func(tweetID, userID int64, blob []byte)) int {
+	rows, err := s.db.Query("SELECT tweet_id, user_id, blob FROM tweets ORDER BY tweet_id")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var count int
+	for rows.Next() {
+		var tweetID, userID int64
+		var blob []byte
+		err = rows.Scan(&tweetID, &userID, &blob)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handle(tweetID, userID, blob)
+		count++
+	}
+	return count
+}
+
+//Interaction is an edge of an interaction network, weighted by the number
+//of tweets From made interacting with To.
+type Interaction struct {
+	From   int64
+	To     int64
+	Tweets int
+}
+
+func (s *Storage) interactions(query string) []Interaction {
+	rows, err := s.db.Query(query)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var edges []Interaction
+	for rows.Next() {
+		var e Interaction
+		err = rows.Scan(&e.From, &e.To, &e.Tweets)
+		if err != nil {
+			log.Fatal(err)
+		}
+		edges = append(edges, e)
+	}
+	return edges
+}
+
+//RetweetNetwork gets the retweet network from the `retweets` table, an edge
+//from each retweeter to each author they retweeted.
+func (s *Storage) RetweetNetwork() []Interaction {
+	return s.interactions(`SELECT retweeter_id, original_author_id, COUNT(*) FROM retweets
+		GROUP BY retweeter_id, original_author_id ORDER BY retweeter_id, original_author_id`)
+}
+
+//ReplyNetwork gets the reply network from the `replies` table, an edge from
+//each user to each user they replied to. Replies to themselves, as in
+//threads, are included.
+func (s *Storage) ReplyNetwork() []Interaction {
+	return s.interactions(`SELECT from_user_id, to_user_id, COUNT(*) FROM replies
+		GROUP BY from_user_id, to_user_id ORDER BY from_user_id, to_user_id`)
+}
+
+//MentionNetwork gets the mention network from the `mention_edges` table, an
+//edge from each user to each user they mentioned, weighted by the number of
+//tweets with the mention. Retweets mention the retweeted author.
+func (s *Storage) MentionNetwork() []Interaction {
+	return s.interactions(`SELECT from_user_id, to_user_id, count FROM mention_edges
+		ORDER BY from_user_id, to_user_id`)
+}
+
+//QuoteNetwork gets the quote network from the `quote_edges` table, an edge
+//from each user to each user whose tweets they quoted. Quotes of deleted
+//tweets, whose authors are unknown, are left out.
+func (s *Storage) QuoteNetwork() []Interaction {
+	return s.interactions(`SELECT quoting_user_id, quoted_user_id, COUNT(*) FROM quote_edges
+		WHERE quoted_user_id != 0
+		GROUP BY quoting_user_id, quoted_user_id ORDER BY quoting_user_id, quoted_user_id`)
+}
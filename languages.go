@@ -0,0 +1,50 @@
+package callosum
+
+import "log"
+
+//LanguageCount holds the number of tweets in a language, and of users who
+//tweeted in it, from the `tweets` table.
+type LanguageCount struct {
+	Language      string  `json:"language"`
+	Tweets        int     `json:"tweets"`
+	TweetsPercent float64 `json:"tweets_percent"`
+	Users         int     `json:"users"`
+	//UsersPercent is out of all the users with tweets. Users tweeting in
+	//several languages are counted once for each, so these add up to 100
+	//or more.
+	UsersPercent float64 `json:"users_percent"`
+}
+
+//LanguageReport summarizes the stored tweets and their users per language,
+//most used language first, to document the composition of the corpus.
+//Tweets without a language are reported under "und", Twitter's code for
+//an undetermined language.
+func (s *Storage) LanguageReport() []LanguageCount {
+	totalTweets := s.count("SELECT COUNT(*) FROM tweets")
+	totalUsers := s.count("SELECT COUNT(DISTINCT user_id) FROM tweets")
+
+	rows, err := s.db.Query(`
+		SELECT COALESCE(NULLIF(langugage, ''), 'und') AS lang,
+			COUNT(*),
+			COUNT(DISTINCT user_id)
+		FROM tweets
+		GROUP BY lang
+		ORDER BY 2 DESC, lang`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var results []LanguageCount
+	for rows.Next() {
+		var l LanguageCount
+		err = rows.Scan(&l.Language, &l.Tweets, &l.Users)
+		if err != nil {
+			log.Fatal(err)
+		}
+		l.TweetsPercent = 100 * float64(l.Tweets) / float64(totalTweets)
+		l.UsersPercent = 100 * float64(l.Users) / float64(totalUsers)
+		results = append(results, l)
+	}
+	return results
+}
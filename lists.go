@@ -0,0 +1,335 @@
+package callosum
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+//listPagesCap is the most pages of lists requested for a user. Popular
+//accounts are on hundreds of thousands of lists, the first pages are enough
+//to categorize them.
+const listPagesCap = 10
+
+//List holds a Twitter list and exposes some fields.
+//Blob contains the entire list object as JSON.
+type List struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MemberCount int    `json:"member_count"`
+	Mode        string `json:"mode"`
+	Owner       struct {
+		ID int64 `json:"id"`
+	} `json:"user"`
+	Blob []byte
+}
+
+//listsAPI is implemented by APIs that can get the lists users are on and
+//the lists they own
+type listsAPI interface {
+	GetListMemberships(screenNameOrID interface{}, cursorID int64) ([]*List, int64, error)
+	GetListOwnerships(screenNameOrID interface{}, cursorID int64) ([]*List, int64, error)
+	GetListMemberIDs(listID int64, cursorID int64) ([]int64, int64, error)
+}
+
+func (n *Network) getLists(screenNameOrID interface{}, endpoint string, cursorID int64) ([]*List, int64, error) {
+	if cursorID == 0 {
+		return []*List{}, 0, nil
+	}
+
+	v := url.Values{}
+	n.addscreenNameOrID(&v, screenNameOrID)
+	v.Add("cursor", strconv.FormatInt(cursorID, 10))
+	v.Add("count", "1000")
+	data, err := n.get(endpoint, v)
+	if err != nil {
+		return nil, 0, err
+	}
+	var result struct {
+		Lists      []*List `json:"lists"`
+		NextCursor int64   `json:"next_cursor"`
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		log.Fatal(err, data)
+	}
+	var blobs struct {
+		Lists []json.RawMessage `json:"lists"`
+	}
+	err = json.Unmarshal(data, &blobs)
+	if err != nil {
+		log.Fatal(err, data)
+	}
+	for index, blob := range blobs.Lists {
+		result.Lists[index].Blob = blob
+	}
+	return result.Lists, result.NextCursor, nil
+}
+
+//GetListMemberships gets the public lists screenNameOrID is a member of.
+//cursorID specifies the cursor position for multiple request. Please refer
+//to Twitter's API documentation on cursoring for more details.
+func (n *Network) GetListMemberships(screenNameOrID interface{}, cursorID int64) ([]*List, int64, error) {
+	return n.getLists(screenNameOrID, "lists/memberships", cursorID)
+}
+
+//GetListOwnerships gets the lists screenNameOrID created. cursorID specifies
+//the cursor position for multiple request. Please refer to Twitter's API
+//documentation on cursoring for more details.
+func (n *Network) GetListOwnerships(screenNameOrID interface{}, cursorID int64) ([]*List, int64, error) {
+	return n.getLists(screenNameOrID, "lists/ownerships", cursorID)
+}
+
+//GetListMemberIDs gets the IDs of the members of the list. cursorID
+//specifies the cursor position for multiple request. Please refer to
+//Twitter's API documentation on cursoring for more details.
+func (n *Network) GetListMemberIDs(listID int64, cursorID int64) ([]int64, int64, error) {
+	if cursorID == 0 {
+		return []int64{}, 0, nil
+	}
+
+	v := url.Values{}
+	v.Add("list_id", strconv.FormatInt(listID, 10))
+	v.Add("cursor", strconv.FormatInt(cursorID, 10))
+	v.Add("count", "5000")
+	v.Add("skip_status", "true")
+	data, err := n.get("lists/members", v)
+	if err != nil {
+		return nil, 0, err
+	}
+	var result struct {
+		Users []struct {
+			ID int64 `json:"id"`
+		} `json:"users"`
+		NextCursor int64 `json:"next_cursor"`
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		log.Fatal(err, data)
+	}
+	IDs := make([]int64, len(result.Users))
+	for index, u := range result.Users {
+		IDs[index] = u.ID
+	}
+	return IDs, result.NextCursor, nil
+}
+
+//CollectListMemberships gets the public lists userID is a member of, up to
+//listPagesCap pages, and stores them in the `list_memberships` table. The
+//lists others put an account on are a signal of what the account is about.
+//Protected users, and users Twitter no longer knows, are skipped like in
+//CollectFriends. CollectListMemberships returns the number of lists stored.
+func (t *TwitterCollector) CollectListMemberships(userID int64) int {
+	l, ok := t.n.(listsAPI)
+	if !ok {
+		return 0
+	}
+	if t.skipProtected(userID, PhaseMemberships) {
+		return 0
+	}
+	var lists []*List
+	var err error
+	cursorID := int64(-1)
+	for page := 0; page < listPagesCap && cursorID != 0 && err == nil; page++ {
+		var pageLists []*List
+		t.countRequest(PhaseMemberships, "lists/memberships")
+		pageLists, cursorID, err = l.GetListMemberships(userID, cursorID)
+		lists = append(lists, pageLists...)
+	}
+	if t.skipUnavailable(userID, PhaseMemberships, err) {
+		return 0
+	}
+	t.s.StoreListMemberships(userID, lists)
+	t.s.MarkListMembershipsCollected(userID, time.Now().Unix())
+	t.logger.Printf("collected %d list memberships of user %d", len(lists), userID)
+	return len(lists)
+}
+
+//CollectAllListMemberships collects the list memberships of the accepted
+//users whose memberships were not collected yet, see CollectListMemberships.
+//Only TierFull users are collected, see WithTiers.
+func (t *TwitterCollector) CollectAllListMemberships() {
+	t.runPhase(PhaseMemberships, func() int {
+		var total int
+		var afterID int64
+		for {
+			var userIDs []int64
+			t.s.queryScreenNamesOrIDs(`SELECT user_id FROM users
+				WHERE accepted=1 AND tier >= ? AND memberships_collected_at=0 AND user_id > ?
+				ORDER BY user_id LIMIT ?`, &userIDs, TierFull, afterID, userIDsBatchSize)
+			if len(userIDs) == 0 {
+				return total
+			}
+			afterID = userIDs[len(userIDs)-1]
+			total += t.eachUser(userIDs, t.CollectListMemberships)
+		}
+	})
+}
+
+//StoreListMemberships stores the lists in the `list_memberships` table as
+//lists userID is a member of.
+func (s *Storage) StoreListMemberships(userID int64, lists []*List) {
+	for _, l := range lists {
+		chQueryArgs <- &queryArgs{`INSERT OR REPLACE INTO list_memberships (user_id, list_id, name, owner_id, member_count, blob)
+			VALUES (?, ?, ?, ?, ?, ?)`, []interface{}{userID, l.ID, l.Name, l.Owner.ID, l.MemberCount, l.Blob}}
+	}
+}
+
+//MarkListMembershipsCollected sets the time the user's list memberships were collected
+func (s *Storage) MarkListMembershipsCollected(userID, collectedAt int64) {
+	chPriorityArgs <- &queryArgs{"UPDATE users SET memberships_collected_at=? WHERE user_id=?", []interface{}{collectedAt, userID}}
+}
+
+//GetListMemberships gets the lists the user is a member of from the
+//`list_memberships` table. Only the ID, Name, MemberCount, Owner and Blob
+//of the lists are set.
+func (s *Storage) GetListMemberships(userID int64) []*List {
+	rows, err := s.db.Query(`SELECT list_id, name, owner_id, member_count, blob FROM list_memberships
+		WHERE user_id=? ORDER BY list_id`, userID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var lists []*List
+	for rows.Next() {
+		var l List
+		err = rows.Scan(&l.ID, &l.Name, &l.Owner.ID, &l.MemberCount, &l.Blob)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lists = append(lists, &l)
+	}
+	return lists
+}
+
+//CollectLists gets the lists userID created, up to listPagesCap pages, and
+//their members, and stores them in the `lists` and `list_members` tables.
+//The members are not queued for collection. Protected users, and users
+//Twitter no longer knows, are skipped like in CollectFriends, and users
+//whose lists hit the rate limit are collected again by the next round, see
+//skipUnavailable. CollectLists returns the number of lists stored.
+func (t *TwitterCollector) CollectLists(userID int64) int {
+	l, ok := t.n.(listsAPI)
+	if !ok {
+		return 0
+	}
+	if t.skipProtected(userID, PhaseLists) {
+		return 0
+	}
+	var lists []*List
+	var err error
+	cursorID := int64(-1)
+	for page := 0; page < listPagesCap && cursorID != 0 && err == nil; page++ {
+		var pageLists []*List
+		t.countRequest(PhaseLists, "lists/ownerships")
+		pageLists, cursorID, err = l.GetListOwnerships(userID, cursorID)
+		lists = append(lists, pageLists...)
+	}
+	if t.skipUnavailable(userID, PhaseLists, err) {
+		return 0
+	}
+	var members int
+	for _, list := range lists {
+		var memberIDs []int64
+		cursorID := int64(-1)
+		for cursorID != 0 {
+			var IDs []int64
+			var err error
+			t.waitForWriter()
+			t.countRequest(PhaseLists, "lists/members")
+			IDs, cursorID, err = l.GetListMemberIDs(list.ID, cursorID)
+			if errors.Is(err, ErrNotFound) || errors.Is(err, ErrProtected) {
+				//the list was deleted or made private since it was listed
+				break
+			}
+			if err != nil && t.skipUnavailable(userID, PhaseLists, err) {
+				//the rate limit is used up, the lists of the user are
+				//collected again by the next round
+				return 0
+			}
+			memberIDs = append(memberIDs, IDs...)
+		}
+		t.s.StoreListMembers(list.ID, memberIDs)
+		members += len(memberIDs)
+	}
+	t.s.StoreLists(lists)
+	t.s.MarkListsCollected(userID, time.Now().Unix())
+	t.logger.Printf("collected %d lists of user %d with %d members", len(lists), userID, members)
+	return len(lists)
+}
+
+//CollectAllLists collects the lists created by the accepted users whose
+//lists were not collected yet, see CollectLists. Only TierFull users are
+//collected, see WithTiers.
+func (t *TwitterCollector) CollectAllLists() {
+	t.runPhase(PhaseLists, func() int {
+		var total int
+		var afterID int64
+		for {
+			var userIDs []int64
+			t.s.queryScreenNamesOrIDs(`SELECT user_id FROM users
+				WHERE accepted=1 AND tier >= ? AND lists_collected_at=0 AND user_id > ?
+				ORDER BY user_id LIMIT ?`, &userIDs, TierFull, afterID, userIDsBatchSize)
+			if len(userIDs) == 0 {
+				return total
+			}
+			afterID = userIDs[len(userIDs)-1]
+			total += t.eachUser(userIDs, t.CollectLists)
+		}
+	})
+}
+
+//StoreLists stores the lists in the `lists` table
+func (s *Storage) StoreLists(lists []*List) {
+	for _, l := range lists {
+		chQueryArgs <- &queryArgs{`INSERT OR REPLACE INTO lists (list_id, owner_id, name, description, member_count, mode, blob)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`, []interface{}{l.ID, l.Owner.ID, l.Name, l.Description, l.MemberCount, l.Mode, l.Blob}}
+	}
+}
+
+//StoreListMembers stores the mapping between the list and its members in
+//the `list_members` table
+func (s *Storage) StoreListMembers(listID int64, memberIDs []int64) {
+	for _, ID := range memberIDs {
+		chQueryArgs <- &queryArgs{"INSERT OR IGNORE INTO list_members (list_id, user_id) VALUES (?, ?)", []interface{}{listID, ID}}
+	}
+}
+
+//MarkListsCollected sets the time the lists the user created were collected
+func (s *Storage) MarkListsCollected(userID, collectedAt int64) {
+	chPriorityArgs <- &queryArgs{"UPDATE users SET lists_collected_at=? WHERE user_id=?", []interface{}{collectedAt, userID}}
+}
+
+//GetLists gets the lists the user created from the `lists` table
+func (s *Storage) GetLists(ownerID int64) []*List {
+	rows, err := s.db.Query(`SELECT list_id, owner_id, name, description, member_count, mode, blob FROM lists
+		WHERE owner_id=? ORDER BY list_id`, ownerID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var lists []*List
+	for rows.Next() {
+		var l List
+		err = rows.Scan(&l.ID, &l.Owner.ID, &l.Name, &l.Description, &l.MemberCount, &l.Mode, &l.Blob)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lists = append(lists, &l)
+	}
+	return lists
+}
+
+//GetListMemberIDs gets the IDs of the members of the list from the
+//`list_members` table
+func (s *Storage) GetListMemberIDs(listID int64) []int64 {
+	var IDs []int64
+	s.queryScreenNamesOrIDs("SELECT user_id FROM list_members WHERE list_id=? ORDER BY user_id", &IDs, listID)
+	return IDs
+}
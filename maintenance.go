@@ -0,0 +1,81 @@
+package callosum
+
+import (
+	"time"
+)
+
+//Maintain keeps a long running database in shape: it checkpoints the
+//write-ahead log into the database file and truncates it, lets SQLite
+//refresh the statistics its query planner uses with PRAGMA optimize, and
+//returns free pages to the file system. Free pages are only returned for
+//databases created with incremental auto vacuum, as NewStorage creates them.
+//Maintain waits for the writer's current statement and holds up the writer
+//while it runs.
+func (s *Storage) Maintain() error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	for _, pragma := range []string{
+		"PRAGMA wal_checkpoint(TRUNCATE)",
+		"PRAGMA optimize",
+		"PRAGMA incremental_vacuum",
+	} {
+		if _, err := writeDB.Exec(pragma); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//maintain runs Maintain when the writer is idle, a busy collection is
+//maintained at the next interval.
+func (t *TwitterCollector) maintain() {
+	if queued, _ := t.s.WriterStatus(); queued > 0 {
+		t.logger.Printf("postponing database maintenance, %d writes are queued", queued)
+		return
+	}
+	start := time.Now()
+	if err := t.s.Maintain(); err != nil {
+		t.logger.Printf("maintaining the database: %s", err)
+		return
+	}
+	t.logger.Printf("maintained the database in %s", time.Since(start).Round(time.Millisecond))
+}
+
+//analyzeAfterRows is the number of rows a bulk load stores before the
+//statistics of the query planner are refreshed, see Analyze
+const analyzeAfterRows = 10000
+
+//Analyze waits for the queued writes and gathers the statistics SQLite's
+//query planner uses, from every table and index. The statistics of tables
+//grown by a bulk load are out of date, and the planner then picks bad
+//plans for the edge tables that slow the analysis queries down by orders
+//of magnitude. Imports call it when they store more than analyzeAfterRows
+//rows, call it after other bulk loads.
+func (s *Storage) Analyze() error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	s.flush()
+	_, err := writeDB.Exec("ANALYZE")
+	return err
+}
+
+//rows is the number of rows the import stored
+func (stats *ImportStats) rows() int {
+	return stats.Users + stats.Tweets + stats.Following + stats.Followers + stats.Includes
+}
+
+//analyzeImport runs Analyze after an import that stored more than
+//analyzeAfterRows rows.
+func (t *TwitterCollector) analyzeImport(stats *ImportStats) {
+	if stats.rows() < analyzeAfterRows {
+		return
+	}
+	start := time.Now()
+	if err := t.s.Analyze(); err != nil {
+		t.logger.Printf("analyzing the database: %s", err)
+		return
+	}
+	t.logger.Printf("analyzed the database after importing %d rows in %s", stats.rows(), time.Since(start).Round(time.Millisecond))
+}
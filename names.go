@@ -0,0 +1,114 @@
+package callosum
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+//uniqueScreenName is the constraint older versions of callosum created the
+//`screen_name` column of the `users` table with
+const uniqueScreenName = "CONSTRAINT uniquescreenname UNIQUE"
+
+//dropUniqueScreenName rebuilds a `users` table created by an older version
+//of callosum without the UNIQUE constraint on `screen_name`. Screen names can
+//be given up and registered by another account, users are told apart by
+//their ID and keep the last screen name they were stored with.
+func (s *Storage) dropUniqueScreenName() {
+	var createSQL string
+	err := s.db.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name='users'").Scan(&createSQL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !strings.Contains(createSQL, uniqueScreenName) {
+		return
+	}
+
+	//legacy_alter_table keeps the rename from checking the views over
+	//users, which are left without their table in between. It applies to the
+	//connection it is set on.
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, "PRAGMA legacy_alter_table=ON"); err != nil {
+		log.Fatal(err)
+	}
+	defer conn.ExecContext(ctx, "PRAGMA legacy_alter_table=OFF")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	createSQL = strings.Replace(createSQL, uniqueScreenName, "", 1)
+	createSQL = strings.Replace(createSQL, "users", "users_new", 1)
+	for _, stmt := range []string{
+		createSQL,
+		"INSERT INTO users_new SELECT * FROM users",
+		"DROP TABLE users",
+		"ALTER TABLE users_new RENAME TO users",
+	} {
+		if _, err = tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			log.Fatalf("%q: %s\n", err, stmt)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+//storeNameReuse records in the `name_reuses` table the other users that
+//held the screen name before userID, in the `users` table or in their
+//`name_changes`.
+func (s *Storage) storeNameReuse(userID int64, screenName string, detectedAt int64) {
+	chPriorityArgs <- &queryArgs{`INSERT OR IGNORE INTO name_reuses (screen_name, previous_user_id, user_id, detected_at)
+		SELECT ?, user_id, ?, ? FROM users WHERE screen_name=? AND user_id != ?
+		UNION SELECT ?, user_id, ?, ? FROM name_changes WHERE old_screen_name=? AND user_id != ?`,
+		[]interface{}{screenName, userID, detectedAt, screenName, userID,
+			screenName, userID, detectedAt, screenName, userID}}
+}
+
+//NameReuse is a screen name held by a different user before UserID, noticed
+//when UserID was stored with it.
+type NameReuse struct {
+	ScreenName     string
+	PreviousUserID int64
+	UserID         int64
+	DetectedAt     time.Time
+}
+
+//GetNameReuses gets the screen names that were held by more than one user
+//from the `name_reuses` table, in the order they were noticed. Pass a
+//screen name to get only its reuses, or "" for all of them.
+func (s *Storage) GetNameReuses(screenName string) []NameReuse {
+	rows, err := s.db.Query(`SELECT screen_name, previous_user_id, user_id, detected_at FROM name_reuses
+		WHERE ?='' OR screen_name=? ORDER BY detected_at, rowid`, screenName, screenName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var reuses []NameReuse
+	for rows.Next() {
+		var r NameReuse
+		var detectedAt int64
+		err = rows.Scan(&r.ScreenName, &r.PreviousUserID, &r.UserID, &detectedAt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.DetectedAt = time.Unix(detectedAt, 0).UTC()
+		reuses = append(reuses, r)
+	}
+	return reuses
+}
+
+//GetUserIDsByScreenName gets the IDs of every stored user who holds the
+//screen name, or held it according to the `name_changes` table.
+func (s *Storage) GetUserIDsByScreenName(screenName string) []int64 {
+	return s.queryIDs(`SELECT user_id FROM users WHERE screen_name=?
+		UNION SELECT user_id FROM name_changes WHERE old_screen_name=? ORDER BY 1`, screenName, screenName)
+}
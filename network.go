@@ -2,11 +2,16 @@ package callosum
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/venkat/kuruvi"
@@ -45,18 +50,128 @@ type User struct {
 	Blob        []byte
 }
 
+//API is the part of Twitter's API used by the TwitterCollector. Network
+//implements it with Twitter's REST API and Simulator with a generated
+//social graph. Users that don't exist are reported with ErrNotFound and
+//protected accounts with ErrProtected, so that the collector can skip them.
+type API interface {
+	GetUserTimeline(screenNameOrID interface{}, maxID int64) (Tweets, error)
+	GetUser(screenNameOrID interface{}) (*User, error)
+	GetUsers(IDs []int64) ([]*User, error)
+	GetFriendIDs(screenNameOrID interface{}, cursorID int64) ([]int64, int64, error)
+	GetFollowerIDs(screenNameOrID interface{}, cursorID int64) ([]int64, int64, error)
+}
+
 //Network holds a reference to the Twitter API client, Kuruvi
 type Network struct {
 	k *kuruvi.Kuruvi
+
+	lastSuccess int64 //unix nanoseconds of the last successful request
+
+	callsMutex sync.Mutex
+	calls      map[string]int
+
+	window      time.Duration
+	limitsMutex sync.Mutex
+	limits      map[string]*RateLimit
+}
+
+//get makes a request to the endpoint through kuruvi and keeps track of
+//the number of requests, the rate limit window of the endpoint and the last
+//successful request. Parameters in the path of the endpoint, like :place_id
+//in geo/id/:place_id, are taken from v. Errors are returned as *APIError.
+func (n *Network) get(endpoint string, v url.Values) ([]byte, error) {
+	n.waitForWindow(endpoint)
+	n.callsMutex.Lock()
+	if n.calls == nil {
+		n.calls = make(map[string]int)
+	}
+	n.calls[endpoint]++
+	n.callsMutex.Unlock()
+
+	path := endpoint
+	for _, part := range strings.Split(endpoint, "/") {
+		if strings.HasPrefix(part, ":") {
+			path = strings.Replace(path, part, url.PathEscape(v.Get(part[1:])), 1)
+			v.Del(part[1:])
+		}
+	}
+	data, err := n.k.Get(path, v)
+	if err != nil {
+		apiErr := newAPIError(endpoint, err)
+		if errors.Is(apiErr, ErrRateLimited) {
+			n.spendWindow(endpoint)
+		}
+		return nil, apiErr
+	}
+	atomic.StoreInt64(&n.lastSuccess, time.Now().UnixNano())
+	return data, nil
+}
+
+//Calls returns the number of requests made to each endpoint since the
+//Network was created.
+func (n *Network) Calls() map[string]int {
+	n.callsMutex.Lock()
+	defer n.callsMutex.Unlock()
+	calls := make(map[string]int, len(n.calls))
+	for endpoint, count := range n.calls {
+		calls[endpoint] = count
+	}
+	return calls
+}
+
+//LastSuccessfulCall returns the time of the last successful request to
+//Twitter's API, or the zero time if there has not been one yet.
+func (n *Network) LastSuccessfulCall() time.Time {
+	if nanos := atomic.LoadInt64(&n.lastSuccess); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return time.Time{}
+}
+
+//VerifyCredentials checks that Twitter accepts the authentication tokens,
+//the error matches ErrBadCredentials when it doesn't.
+func (n *Network) VerifyCredentials() error {
+	v := url.Values{}
+	v.Add("skip_status", "true")
+	_, err := n.get("account/verify_credentials", v)
+	return err
 }
 
 //NewNetwork creates a new Network object. authFileName has the authentication
 //information for Twitter's client. see template_auth.json for a sample.
 //window is the rate limit window used by twitter (currently 15 mins)
 func NewNetwork(authFileName string, window time.Duration) *Network {
-	n := &Network{}
+	authFile := getFile(authFileName)
+	defer authFile.Close()
+
+	return newNetwork(authFile, window)
+}
 
-	authFile := getFile("auth.json")
+//NewNetworkWithCredentials creates a new Network object from credentials
+//held in memory, for example read from the environment, instead of from an
+//authentication file.
+func NewNetworkWithCredentials(c Credentials, window time.Duration) *Network {
+	authFile, err := ioutil.TempFile("", "callosum-auth")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer authFile.Close()
+	defer os.Remove(authFile.Name())
+
+	err = json.NewEncoder(authFile).Encode(c)
+	if err == nil {
+		_, err = authFile.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return newNetwork(authFile, window)
+}
+
+func newNetwork(authFile *os.File, window time.Duration) *Network {
+	n := &Network{window: window}
 
 	n.k = kuruvi.SetupKuruvi(
 		window,
@@ -91,22 +206,30 @@ func (tweets Tweets) trimTillID(latestTweetID int64) Tweets {
 	return trimmedTweets
 }
 
+//timelinePageSize is the number of tweets requested per page of a timeline
+const timelinePageSize = 200
+
 //GetUserTimeline makes one API request to the user's timeline and sets max_id if
 //maxID is not 0, which specifies the cursor position on the timeline. Consult
 //Twiter's API documentation on user timeline for more details.
-func (n *Network) GetUserTimeline(screenNameOrID interface{}, maxID int64) Tweets {
+func (n *Network) GetUserTimeline(screenNameOrID interface{}, maxID int64) (Tweets, error) {
 	v := url.Values{}
 
 	n.addscreenNameOrID(&v, screenNameOrID)
 	v.Add("trim_user", "true")
-	v.Add("count", "200")
+	v.Add("count", strconv.Itoa(timelinePageSize))
 	if maxID != 0 {
 		v.Add("max_id", strconv.FormatInt(maxID-1, 10))
 	}
+	return n.getTweets("statuses/user_timeline", v)
+}
+
+//getTweets makes one API request to an endpoint returning an array of tweets
+func (n *Network) getTweets(endpoint string, v url.Values) (Tweets, error) {
 	var tweets []*Tweet
-	data, err := n.k.Get("statuses/user_timeline", v)
+	data, err := n.get(endpoint, v)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	err = json.Unmarshal(data, &tweets)
 	if err != nil {
@@ -121,7 +244,7 @@ func (n *Network) GetUserTimeline(screenNameOrID interface{}, maxID int64) Tweet
 	for index, blob := range blobs {
 		tweets[index].Blob = blob
 	}
-	return tweets
+	return tweets, nil
 }
 
 func (n *Network) addscreenNameOrID(v *url.Values, screenNameOrID interface{}) {
@@ -136,15 +259,15 @@ func (n *Network) addscreenNameOrID(v *url.Values, screenNameOrID interface{}) {
 }
 
 //GetUser makes one API request to get a User from Twitter.
-func (n *Network) GetUser(screenNameOrID interface{}) *User {
+func (n *Network) GetUser(screenNameOrID interface{}) (*User, error) {
 	var u *User
 
 	v := url.Values{}
 	n.addscreenNameOrID(&v, screenNameOrID)
 
-	data, err := n.k.Get("users/show", v)
+	data, err := n.get("users/show", v)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	json.Unmarshal(data, &u)
 	var blob json.RawMessage
@@ -153,13 +276,46 @@ func (n *Network) GetUser(screenNameOrID interface{}) *User {
 		log.Fatal(err, data)
 	}
 	u.Blob = blob
-	return u
+	return u, nil
+}
+
+//usersLookupLimit is the most IDs users/lookup takes in a request
+const usersLookupLimit = 100
+
+//lookupLimiter is implemented by APIs that limit the number of IDs GetUsers
+//takes at once
+type lookupLimiter interface {
+	MaxLookupIDs() int
+}
+
+//MaxLookupIDs returns the most IDs the Network looks up in a request
+func (n *Network) MaxLookupIDs() int {
+	return usersLookupLimit
 }
 
-//GetUsers makes an API request to get the User objects for
+//GetUsers makes API requests to get the User objects for
 //given IDs. The API limits the number of IDs in a batch
-//to 200
-func (n *Network) GetUsers(IDs []int64) []*User {
+//to 100, larger batches are split into several requests.
+//IDs of users that don't exist are left out, and when none of
+//them exists the result is empty.
+func (n *Network) GetUsers(IDs []int64) ([]*User, error) {
+	var users []*User
+	for len(IDs) > usersLookupLimit {
+		batch, err := n.getUsers(IDs[:usersLookupLimit])
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, batch...)
+		IDs = IDs[usersLookupLimit:]
+	}
+	batch, err := n.getUsers(IDs)
+	if err != nil {
+		return nil, err
+	}
+	return append(users, batch...), nil
+}
+
+func (n *Network) getUsers(IDs []int64) ([]*User, error) {
 	var users []*User
 
 	v := url.Values{}
@@ -168,9 +324,12 @@ func (n *Network) GetUsers(IDs []int64) []*User {
 		IDStrings[index] = strconv.FormatInt(IDs[index], 10)
 	}
 	v.Add("user_id", strings.Join(IDStrings, ","))
-	data, err := n.k.Get("users/lookup", v)
+	data, err := n.get("users/lookup", v)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	err = json.Unmarshal(data, &users)
 	if err != nil {
@@ -188,20 +347,20 @@ func (n *Network) GetUsers(IDs []int64) []*User {
 			log.Fatal("empty user blog", users[index])
 		}
 	}
-	return users
+	return users, nil
 }
 
-func (n *Network) getUserIDs(screenNameOrID interface{}, endpoint string, cursorID int64) ([]int64, int64) {
+func (n *Network) getUserIDs(screenNameOrID interface{}, endpoint string, cursorID int64) ([]int64, int64, error) {
 	if cursorID == 0 {
-		return []int64{}, 0
+		return []int64{}, 0, nil
 	}
 
 	v := url.Values{}
 	n.addscreenNameOrID(&v, screenNameOrID)
 	v.Add("cursor", strconv.FormatInt(cursorID, 10))
-	data, err := n.k.Get(endpoint, v)
+	data, err := n.get(endpoint, v)
 	if err != nil {
-		log.Fatal(err)
+		return nil, 0, err
 	}
 	var result struct {
 		IDs        []int64 `json:"ids"`
@@ -211,19 +370,59 @@ func (n *Network) getUserIDs(screenNameOrID interface{}, endpoint string, cursor
 	if err != nil {
 		log.Fatal(err, data)
 	}
-	return result.IDs, result.NextCursor
+	return result.IDs, result.NextCursor, nil
 }
 
 //GetFriendIDs gets the IDs of people that screenNameOrID is following. cursorID specifies
 //the cursor position for multiple request. Please refer to Twitter's API documentation on
 //cursoring for more details.
-func (n *Network) GetFriendIDs(screenNameOrID interface{}, cursorID int64) ([]int64, int64) {
+func (n *Network) GetFriendIDs(screenNameOrID interface{}, cursorID int64) ([]int64, int64, error) {
 	return n.getUserIDs(screenNameOrID, "friends/ids", cursorID)
 }
 
 //GetFollowerIDs gets the follower IDs of screenNameOrID. cursorID specifies
 //the cursor position for multiple request. Please refer to Twitter's API documentation on
 //cursoring for more details.
-func (n *Network) GetFollowerIDs(screenNameOrID interface{}, cursorID int64) ([]int64, int64) {
+func (n *Network) GetFollowerIDs(screenNameOrID interface{}, cursorID int64) ([]int64, int64, error) {
 	return n.getUserIDs(screenNameOrID, "followers/ids", cursorID)
 }
+
+//LookupTweets makes an API request to get the tweets with the given IDs.
+//The API limits the number of IDs in a batch to 100. Tweets that are no
+//longer available, because they were deleted or their author was suspended
+//or protected their tweets, are left out of the result.
+func (n *Network) LookupTweets(IDs []int64) (map[int64]*Tweet, error) {
+	v := url.Values{}
+	IDStrings := make([]string, len(IDs))
+	for index := range IDs {
+		IDStrings[index] = strconv.FormatInt(IDs[index], 10)
+	}
+	v.Add("id", strings.Join(IDStrings, ","))
+	v.Add("map", "true")
+	v.Add("trim_user", "true")
+	data, err := n.get("statuses/lookup", v)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		ID map[string]json.RawMessage `json:"id"`
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		log.Fatal(err, data)
+	}
+
+	tweets := make(map[int64]*Tweet)
+	for _, blob := range result.ID {
+		if len(blob) == 0 || string(blob) == "null" {
+			continue
+		}
+		tweet := &Tweet{Blob: blob}
+		err = json.Unmarshal(blob, tweet)
+		if err != nil {
+			log.Fatal(err, blob)
+		}
+		tweets[tweet.ID] = tweet
+	}
+	return tweets, nil
+}
@@ -0,0 +1,326 @@
+package callosum
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+//DefaultDBName is the sqlite database file name used when no storage is given
+const DefaultDBName = "callosum"
+
+//DefaultAuthFileName is the authentication file used when no network is given
+const DefaultAuthFileName = "auth.json"
+
+//DefaultWindow is Twitter's 15 minute rate limit rollover window with an extra
+//minute to avoid edge cases with twitter rolling over its time window
+const DefaultWindow = 15*time.Minute + 1*time.Minute
+
+//Intervals holds the minimum time between two consecutive runs of each
+//collection phase started by StartCollection. Refresh is also how old the
+//profiles looked up again by the refresh phase are.
+type Intervals struct {
+	Users       time.Duration
+	Tweets      time.Duration
+	Friends     time.Duration
+	Followers   time.Duration
+	Refresh     time.Duration
+	Memberships time.Duration
+	Lists       time.Duration
+	Account     time.Duration
+}
+
+//DefaultIntervals are the intervals used when none are given
+var DefaultIntervals = Intervals{
+	Users:       2 * time.Second,
+	Tweets:      2 * time.Second,
+	Friends:     2 * time.Second,
+	Followers:   2 * time.Second,
+	Refresh:     24 * time.Hour,
+	Memberships: 2 * time.Second,
+	Lists:       2 * time.Second,
+	Account:     15 * time.Minute,
+}
+
+//The collection phases run by StartCollection. PhaseRefresh, which looks up
+//the stored users again, PhaseMemberships, which collects the lists the
+//users are on, PhaseLists, which collects the lists they created, and
+//PhaseAccount, which collects the timelines of the authenticating account,
+//are only run when they're given to WithPhases.
+const (
+	PhaseUsers       = "users"
+	PhaseTweets      = "tweets"
+	PhaseFriends     = "friends"
+	PhaseFollowers   = "followers"
+	PhaseRefresh     = "refresh"
+	PhaseMemberships = "memberships"
+	PhaseLists       = "lists"
+	PhaseAccount     = "account"
+)
+
+var allPhases = []string{PhaseUsers, PhaseTweets, PhaseFriends, PhaseFollowers}
+
+var optionalPhases = []string{PhaseRefresh, PhaseMemberships, PhaseLists, PhaseAccount}
+
+//Caps limits the size of the collection. A zero value means no limit.
+type Caps struct {
+	//MaxUsers stops the collection of new users once the `users` table has as many rows.
+	MaxUsers int
+	//MaxTweetsPerUser limits the tweets collected for a user in one call to GetTweets.
+	MaxTweetsPerUser int
+}
+
+//Option configures a TwitterCollector. Options are passed to NewTwitterCollector.
+type Option func(*TwitterCollector)
+
+//WithStorage sets the Storage the collector writes to.
+//Defaults to a sqlite database named DefaultDBName.
+func WithStorage(s *Storage) Option {
+	return func(t *TwitterCollector) {
+		t.s = s
+	}
+}
+
+//WithNetwork sets the API the collector uses to talk to Twitter, usually a *Network.
+//Defaults to a Network using DefaultAuthFileName and DefaultWindow.
+func WithNetwork(n API) Option {
+	return func(t *TwitterCollector) {
+		t.n = n
+	}
+}
+
+//WithFilter sets the filter function applied to every collected user.
+//Defaults to AcceptAll.
+func WithFilter(fu FilterUser) Option {
+	return func(t *TwitterCollector) {
+		t.filterUser = fu
+	}
+}
+
+//WithLogger sets the logger used to report collection progress.
+func WithLogger(l *log.Logger) Option {
+	return func(t *TwitterCollector) {
+		t.logger = l
+	}
+}
+
+//WithConcurrency sets the number of users whose tweets, friends or followers
+//are collected in parallel within a phase. Defaults to 1.
+func WithConcurrency(n int) Option {
+	return func(t *TwitterCollector) {
+		if n < 1 {
+			n = 1
+		}
+		t.concurrency = n
+	}
+}
+
+//WithIntervals sets how often each collection phase is repeated.
+//Zero values are replaced with the ones in DefaultIntervals.
+func WithIntervals(i Intervals) Option {
+	return func(t *TwitterCollector) {
+		if i.Users == 0 {
+			i.Users = DefaultIntervals.Users
+		}
+		if i.Tweets == 0 {
+			i.Tweets = DefaultIntervals.Tweets
+		}
+		if i.Friends == 0 {
+			i.Friends = DefaultIntervals.Friends
+		}
+		if i.Followers == 0 {
+			i.Followers = DefaultIntervals.Followers
+		}
+		if i.Refresh == 0 {
+			i.Refresh = DefaultIntervals.Refresh
+		}
+		if i.Memberships == 0 {
+			i.Memberships = DefaultIntervals.Memberships
+		}
+		if i.Lists == 0 {
+			i.Lists = DefaultIntervals.Lists
+		}
+		if i.Account == 0 {
+			i.Account = DefaultIntervals.Account
+		}
+		t.intervals = i
+	}
+}
+
+//WithPhases sets the collection phases run by StartCollection. Valid phases
+//are PhaseUsers, PhaseTweets, PhaseFriends, PhaseFollowers, PhaseRefresh,
+//PhaseMemberships, PhaseLists and PhaseAccount. Defaults to all phases but
+//the optional PhaseRefresh, PhaseMemberships, PhaseLists and PhaseAccount.
+func WithPhases(phases ...string) Option {
+	return func(t *TwitterCollector) {
+		t.phases = make(map[string]bool)
+		for _, phase := range phases {
+			if !isPhase(phase) {
+				log.Fatalf("unknown phase %q, valid phases are %v", phase, append(allPhases, optionalPhases...))
+			}
+			t.phases[phase] = true
+		}
+	}
+}
+
+//WithCaps sets limits on the size of the collection.
+func WithCaps(c Caps) Option {
+	return func(t *TwitterCollector) {
+		t.caps = c
+	}
+}
+
+//WithExporter sets an Exporter that receives every user and tweet as it is
+//stored, so that downstream processing can start before the crawl finishes.
+func WithExporter(e Exporter) Option {
+	return func(t *TwitterCollector) {
+		t.exporter = e
+	}
+}
+
+//WithAnnotator sets an Annotator run on every tweet as it is stored. Use
+//Storage.AnnotateTweets to annotate tweets collected earlier.
+func WithAnnotator(a Annotator) Option {
+	return func(t *TwitterCollector) {
+		t.annotator = a
+	}
+}
+
+//DefaultBackupInterval is the time between two backups of the database
+const DefaultBackupInterval = 24 * time.Hour
+
+//WithBackups uploads a backup of the database to the store every interval
+//while StartCollection runs. Intervals that are not positive are replaced
+//with DefaultBackupInterval.
+func WithBackups(store ObjectStore, interval time.Duration) Option {
+	return func(t *TwitterCollector) {
+		if interval <= 0 {
+			interval = DefaultBackupInterval
+		}
+		t.backupStore = store
+		t.backupInterval = interval
+	}
+}
+
+//WithMaintenance runs Storage.Maintain every interval while StartCollection
+//runs, skipping the intervals in which the writer is busy.
+func WithMaintenance(interval time.Duration) Option {
+	return func(t *TwitterCollector) {
+		t.maintenanceInterval = interval
+	}
+}
+
+//WithProtectedRecheck looks up the users stored as protected again every
+//interval while StartCollection runs, and collects the ones that made their
+//account public, see RecheckProtectedUsers.
+func WithProtectedRecheck(interval time.Duration) Option {
+	return func(t *TwitterCollector) {
+		t.protectedRecheckInterval = interval
+	}
+}
+
+//DefaultUnavailableAfter is the number of times in a row Twitter can refuse
+//the collection for a user before the user is marked unavailable.
+const DefaultUnavailableAfter = 3
+
+//DefaultLookupChunkSize is the number of users looked up per request, the
+//most Twitter's v1.1 API takes.
+const DefaultLookupChunkSize = 100
+
+//WithLookupChunkSize sets the number of user IDs handed to the API's GetUsers
+//at a time. APIs that take fewer IDs at once, see Network.MaxLookupIDs, get
+//chunks of their limit instead. Values below 1 are replaced with
+//DefaultLookupChunkSize.
+func WithLookupChunkSize(n int) Option {
+	return func(t *TwitterCollector) {
+		if n < 1 {
+			n = DefaultLookupChunkSize
+		}
+		t.lookupChunkSize = n
+	}
+}
+
+//DefaultWriteWatermark is the number of writes queued up for the writer past
+//which the collector holds off API requests.
+const DefaultWriteWatermark = writeQueueSize * 3 / 4
+
+//WithUnavailableAfter sets the number of times in a row Twitter can refuse
+//the collection for a user, because the account is protected, suspended or
+//deleted, before the user is marked unavailable with SkipReasonUnavailable
+//and no longer collected. Zero never marks users unavailable.
+//Defaults to DefaultUnavailableAfter.
+func WithUnavailableAfter(n int) Option {
+	return func(t *TwitterCollector) {
+		t.unavailableAfter = n
+	}
+}
+
+//WithWriteWatermark sets the number of writes queued up for the writer past
+//which the collector holds off API requests until the writer is down to half
+//of them, see Storage.Backpressure. The rate limits leave slack for the
+//pause, and it bounds the memory used when SQLite can't keep up with a burst
+//of followers. Zero never holds off requests. Defaults to
+//DefaultWriteWatermark.
+func WithWriteWatermark(n int) Option {
+	return func(t *TwitterCollector) {
+		t.writeWatermark = n
+	}
+}
+
+//WithMonthlyCap sets a cap on the tweets read from Twitter's API each month.
+//A warning is logged once c.WarnAt of the cap is read, and reading tweets
+//pauses until the next month once c.PauseAt of it is.
+func WithMonthlyCap(c MonthlyCap) Option {
+	return func(t *TwitterCollector) {
+		if c.WarnAt == 0 {
+			c.WarnAt = DefaultCapWarnAt
+		}
+		if c.PauseAt == 0 {
+			c.PauseAt = DefaultCapPauseAt
+		}
+		t.monthlyCap = c
+	}
+}
+
+//WithRun names the run of the collector the API usage is recorded under in
+//the `api_usage` table, see Storage.GetAPIUsage. Defaults to the time the
+//collector was created, in UTC.
+func WithRun(name string) Option {
+	return func(t *TwitterCollector) {
+		t.run = name
+	}
+}
+
+//WithHealthCheck serves the collector's health on addr at /healthz while
+//StartCollection runs.
+func WithHealthCheck(addr string) Option {
+	return func(t *TwitterCollector) {
+		t.healthAddr = addr
+	}
+}
+
+//WithCompletionHook sets a function called once StartCollection finds the
+//collection complete, right before StartCollection returns.
+func WithCompletionHook(hook func()) Option {
+	return func(t *TwitterCollector) {
+		t.completionHook = hook
+	}
+}
+
+func isPhase(phase string) bool {
+	for _, p := range append(allPhases, optionalPhases...) {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+//AcceptAll is a FilterUser that accepts every user.
+func AcceptAll(blob []byte) bool {
+	return true
+}
+
+func defaultLogger() *log.Logger {
+	return log.New(os.Stderr, "callosum: ", log.LstdFlags)
+}
@@ -0,0 +1,93 @@
+package callosum
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+//DefaultDamping is the usual PageRank damping factor
+const DefaultDamping = 0.85
+
+//UserRank is a user along with their PageRank
+type UserRank struct {
+	ID   int64
+	Rank float64
+}
+
+//PageRank computes the PageRank of every node, iterating until the ranks
+//change by less than tolerance in total or maxIterations is reached. Ranks
+//add up to 1. Rank held by users who follow no one in the graph is spread
+//evenly over all users.
+func (g *Graph) PageRank(damping float64, maxIterations int, tolerance float64) map[int64]float64 {
+	n := float64(len(g.Nodes))
+	ranks := make(map[int64]float64, len(g.Nodes))
+	for ID := range g.Nodes {
+		ranks[ID] = 1 / n
+	}
+
+	for i := 0; i < maxIterations; i++ {
+		var dangling float64
+		for ID := range g.Nodes {
+			if len(g.Out[ID]) == 0 {
+				dangling += ranks[ID]
+			}
+		}
+
+		next := make(map[int64]float64, len(g.Nodes))
+		base := (1-damping)/n + damping*dangling/n
+		for ID := range g.Nodes {
+			next[ID] = base
+		}
+		for from, out := range g.Out {
+			share := damping * ranks[from] / float64(len(out))
+			for _, to := range out {
+				next[to] += share
+			}
+		}
+
+		var delta float64
+		for ID := range g.Nodes {
+			delta += math.Abs(next[ID] - ranks[ID])
+		}
+		ranks = next
+		if delta < tolerance {
+			break
+		}
+	}
+	return ranks
+}
+
+//RankUsers computes the PageRank of the accepted users over the stored follow
+//edges and writes them to the `user_rank` table, replacing earlier results.
+func (s *Storage) RankUsers() map[int64]float64 {
+	ranks := s.Graph().PageRank(DefaultDamping, 100, 1e-9)
+
+	computedAt := time.Now().UTC().Unix()
+	chQueryArgs <- &queryArgs{"DELETE FROM user_rank", nil}
+	for ID, rank := range ranks {
+		chQueryArgs <- &queryArgs{"INSERT INTO user_rank (user_id, rank, computed_at) VALUES (?, ?, ?)",
+			[]interface{}{ID, rank, computedAt}}
+	}
+	return ranks
+}
+
+//GetTopRankedUsers gets the n users with the highest rank from the `user_rank` table
+func (s *Storage) GetTopRankedUsers(n int) []UserRank {
+	rows, err := s.db.Query("SELECT user_id, rank FROM user_rank ORDER BY rank DESC LIMIT ?", n)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var results []UserRank
+	for rows.Next() {
+		var r UserRank
+		err = rows.Scan(&r.ID, &r.Rank)
+		if err != nil {
+			log.Fatal(err)
+		}
+		results = append(results, r)
+	}
+	return results
+}
@@ -0,0 +1,41 @@
+package callosum
+
+//PathBetween finds a shortest chain of follows from user a to user b with a
+//breadth first search, returning the IDs on the path starting with a and
+//ending with b. It returns nil when b can not be reached from a.
+func (g *Graph) PathBetween(a, b int64) []int64 {
+	if g.Nodes[a] == nil || g.Nodes[b] == nil {
+		return nil
+	}
+	if a == b {
+		return []int64{a}
+	}
+
+	previous := map[int64]int64{a: a}
+	queue := []int64{a}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range g.Out[current] {
+			if _, seen := previous[next]; seen {
+				continue
+			}
+			previous[next] = current
+			if next == b {
+				var path []int64
+				for ID := b; ID != a; ID = previous[ID] {
+					path = append([]int64{ID}, path...)
+				}
+				return append([]int64{a}, path...)
+			}
+			queue = append(queue, next)
+		}
+	}
+	return nil
+}
+
+//PathBetween finds a shortest chain of follows from user a to user b over
+//all the stored follow edges, see Graph.PathBetween.
+func (s *Storage) PathBetween(a, b int64) []int64 {
+	return s.FullGraph().PathBetween(a, b)
+}
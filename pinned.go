@@ -0,0 +1,104 @@
+package callosum
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+)
+
+//pinnedTweetID gets the ID of the tweet pinned to the profile from a user
+//blob. API v2 users have a `pinned_tweet_id` and v1.1 users, where Twitter
+//includes it, a `pinned_tweet_ids_str` list. It returns 0 when no tweet is
+//pinned.
+func pinnedTweetID(blob []byte) int64 {
+	var u struct {
+		PinnedTweetID     json.Number `json:"pinned_tweet_id"`
+		PinnedTweetIDsStr []string    `json:"pinned_tweet_ids_str"`
+	}
+	if json.Unmarshal(blob, &u) != nil {
+		return 0
+	}
+	ID := string(u.PinnedTweetID)
+	if ID == "" && len(u.PinnedTweetIDsStr) > 0 {
+		ID = u.PinnedTweetIDsStr[0]
+	}
+	pinnedID, _ := strconv.ParseInt(ID, 10, 64)
+	return pinnedID
+}
+
+//CollectPinnedTweet stores the tweet pinned to the profile of userID and
+//flags it as pinned. Pinned tweets are often older than what the timeline
+//returns, so when it's not stored yet it is looked up by ID, if the network
+//can look up tweets. A pinned tweet that is not available is recorded in the
+//`missing_pinned_id` column of the user and not looked up again while it
+//stays pinned. CollectPinnedTweet reports whether the user has a pinned
+//tweet.
+func (t *TwitterCollector) CollectPinnedTweet(userID int64) bool {
+	u := t.s.GetUserByScreenNameOrID(userID)
+	pinnedID := pinnedTweetID(u.Blob)
+	if pinnedID != 0 && !t.s.HasTweet(pinnedID) {
+		l, ok := t.n.(tweetLookup)
+		switch {
+		case pinnedID == t.s.getMissingPinnedTweet(userID):
+			pinnedID = 0
+		case !ok:
+			return false
+		default:
+			t.waitForBudget()
+			tweet, ok := t.lookupTweets(l, PhaseTweets, []int64{pinnedID})[pinnedID]
+			if !ok {
+				t.logger.Printf("pinned tweet %d of user %d is not available", pinnedID, userID)
+				t.s.markPinnedTweetMissing(userID, pinnedID)
+				pinnedID = 0
+			} else {
+				t.consume(1)
+				t.storeTweet(userID, tweet)
+			}
+		}
+	}
+	if pinned := t.s.GetPinnedTweet(userID); pinned == nil && pinnedID != 0 || pinned != nil && pinned.ID != pinnedID {
+		t.s.MarkPinnedTweet(userID, pinnedID)
+	}
+	return pinnedID != 0
+}
+
+//HasTweet reports whether the tweet is in the `tweets` table
+func (s *Storage) HasTweet(tweetID int64) bool {
+	return s.count("SELECT COUNT(*) FROM tweets WHERE tweet_id=?", tweetID) > 0
+}
+
+//markPinnedTweetMissing records that the tweet pinned by userID is no longer
+//available
+func (s *Storage) markPinnedTweetMissing(userID, tweetID int64) {
+	chPriorityArgs <- &queryArgs{"UPDATE users SET missing_pinned_id=? WHERE user_id=?", []interface{}{tweetID, userID}}
+}
+
+//getMissingPinnedTweet gets the ID of the tweet pinned by userID that was
+//found not available, or 0
+func (s *Storage) getMissingPinnedTweet(userID int64) int64 {
+	var tweetID int64
+	err := s.db.QueryRow("SELECT missing_pinned_id FROM users WHERE user_id=?", userID).Scan(&tweetID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Fatal(err)
+	}
+	return tweetID
+}
+
+//MarkPinnedTweet flags the tweet as the one pinned by userID, clearing the
+//flag on any tweet the user pinned before. A pinnedID of 0 clears it.
+func (s *Storage) MarkPinnedTweet(userID, pinnedID int64) {
+	chQueryArgs <- &queryArgs{"UPDATE tweets SET pinned=(tweet_id=?) WHERE user_id=? AND (pinned=1 OR tweet_id=?)",
+		[]interface{}{pinnedID, userID, pinnedID}}
+}
+
+//GetPinnedTweet gets the tweet pinned by userID, or nil if there's none.
+func (s *Storage) GetPinnedTweet(userID int64) *Tweet {
+	tweets := s.queryTweets(`SELECT tweet_id, created_at, langugage, "desc", blob FROM tweets
+		WHERE user_id=? AND pinned=1`, userID)
+	if len(tweets) == 0 {
+		return nil
+	}
+	return tweets[0]
+}
@@ -0,0 +1,174 @@
+package callosum
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+)
+
+//placesBatchSize is the number of places read from the `places` table at a
+//time by LookupPlaces
+const placesBatchSize = 100
+
+//placeLookup is implemented by APIs that can look up and search places
+type placeLookup interface {
+	GetPlace(placeID string) (*Place, error)
+	SearchPlaces(query string) ([]*Place, error)
+}
+
+//GetPlace makes one API request to get the details of the place, including
+//the place it is contained within. The error matches ErrNotFound if Twitter
+//doesn't know the place.
+func (n *Network) GetPlace(placeID string) (*Place, error) {
+	v := url.Values{}
+	v.Add("place_id", placeID)
+	data, err := n.get("geo/id/:place_id", v)
+	if err != nil {
+		return nil, err
+	}
+	var p Place
+	err = json.Unmarshal(data, &p)
+	if err != nil {
+		log.Fatal(err, data)
+	}
+	p.Blob = json.RawMessage(data)
+	return &p, nil
+}
+
+//SearchPlaces makes one API request to get the places matching the query,
+//a name like "San Francisco".
+func (n *Network) SearchPlaces(query string) ([]*Place, error) {
+	v := url.Values{}
+	v.Add("query", query)
+	data, err := n.get("geo/search", v)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Result struct {
+			Places []json.RawMessage `json:"places"`
+		} `json:"result"`
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		log.Fatal(err, data)
+	}
+	places := make([]*Place, len(result.Result.Places))
+	for index, blob := range result.Result.Places {
+		places[index] = &Place{Blob: blob}
+		json.Unmarshal(blob, places[index])
+	}
+	return places, nil
+}
+
+//LookupPlaces looks up the places in the `places` table that were not looked
+//up yet, usually the places tweets are tagged with, and stores their details:
+//the place object in `blob` and the place they are contained within in
+//`parent_id`, which is stored too, so that tweets can be sliced by city and
+//by country. Places Twitter doesn't know are left as they are. LookupPlaces
+//returns the number of places looked up.
+func (t *TwitterCollector) LookupPlaces() int {
+	l, ok := t.n.(placeLookup)
+	if !ok {
+		return 0
+	}
+	var lookedUp int
+	var afterID string
+	for {
+		var IDs []string
+		t.s.queryScreenNamesOrIDs(`SELECT place_id FROM places
+			WHERE looked_up_at=0 AND place_id > ?
+			ORDER BY place_id LIMIT ?`, &IDs, afterID, placesBatchSize)
+		if len(IDs) == 0 {
+			break
+		}
+		afterID = IDs[len(IDs)-1]
+
+		for _, ID := range IDs {
+			t.countRequest(UsagePlaces, "geo/id/:place_id")
+			p, err := l.GetPlace(ID)
+			switch {
+			case errors.Is(err, ErrNotFound):
+				t.s.MarkPlaceLookedUp(ID, "", nil)
+				continue
+			case err != nil:
+				log.Fatal(err)
+			}
+			t.s.StorePlace(p.Blob)
+			lookedUp++
+		}
+	}
+	if lookedUp > 0 {
+		t.logger.Printf("looked up %d places", lookedUp)
+	}
+	return lookedUp
+}
+
+//SearchPlaces gets the places matching the query, like "San Francisco", and
+//stores them, to find the IDs of places to slice the corpus by, see
+//GeoQuery.
+func (t *TwitterCollector) SearchPlaces(query string) ([]*Place, error) {
+	l, ok := t.n.(placeLookup)
+	if !ok {
+		return nil, nil
+	}
+	t.countRequest(UsagePlaces, "geo/search")
+	places, err := l.SearchPlaces(query)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range places {
+		t.s.StorePlace(p.Blob)
+	}
+	return places, nil
+}
+
+//StorePlace stores a place object of Twitter's API as looked up in the
+//`places` table, and the places it is contained within.
+func (s *Storage) StorePlace(blob []byte) {
+	var p struct {
+		placeObject
+		ContainedWithin []*placeObject `json:"contained_within"`
+	}
+	if json.Unmarshal(blob, &p) != nil || p.ID == "" {
+		return
+	}
+	s.storePlace(&p.placeObject)
+	var parentID string
+	for _, parent := range p.ContainedWithin {
+		if parent.ID != "" && parent.ID != p.ID {
+			s.storePlace(parent)
+			parentID = parent.ID
+			break
+		}
+	}
+	s.MarkPlaceLookedUp(p.ID, parentID, blob)
+}
+
+//MarkPlaceLookedUp sets the place the place is contained within, its place
+//object and when it was looked up.
+func (s *Storage) MarkPlaceLookedUp(placeID, parentID string, blob []byte) {
+	chQueryArgs <- &queryArgs{"UPDATE places SET parent_id=?, blob=?, looked_up_at=? WHERE place_id=?",
+		[]interface{}{parentID, blob, time.Now().Unix(), placeID}}
+}
+
+//GetPlace gets the place from the `places` table. The error matches
+//ErrNotFound if the place is not stored.
+func (s *Storage) GetPlace(placeID string) (*Place, error) {
+	var p Place
+	err := s.db.QueryRow(`SELECT place_id, name, full_name, place_type, country_code, country, latitude, longitude,
+			parent_id, blob
+		FROM places WHERE place_id=?`, placeID).Scan(&p.ID, &p.Name, &p.FullName, &p.PlaceType,
+		&p.CountryCode, &p.Country, &p.Latitude, &p.Longitude, &p.ParentID, &p.Blob)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("place %v: %w", placeID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
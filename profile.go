@@ -0,0 +1,141 @@
+package callosum
+
+import (
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+)
+
+//Entity types of the `bio_entities` table
+const (
+	EntityHashtag = "hashtag"
+	EntityMention = "mention"
+	EntityURL     = "url"
+)
+
+//hashtagPattern matches hashtags in any script, not only ASCII ones, as \w would
+var hashtagPattern = regexp.MustCompile(`#[\p{L}\p{N}_]+`)
+
+//Profile holds the entities of a user's profile: the homepage URL and the
+//hashtags, mentions and URLs of the bio. URLs are expanded from Twitter's
+//t.co links where the user object has the expansion. Hashtags and mentions
+//are lowercased, without the # and @.
+type Profile struct {
+	URL      string
+	Hashtags []string
+	Mentions []string
+	URLs     []string
+}
+
+//profileURLs holds the URL entities of a v1.1 user object
+type profileURLs struct {
+	URLs []struct {
+		URL         string `json:"url"`
+		ExpandedURL string `json:"expanded_url"`
+	} `json:"urls"`
+}
+
+func (p *profileURLs) expand(URL string) string {
+	for _, u := range p.URLs {
+		if u.URL == URL && u.ExpandedURL != "" {
+			return u.ExpandedURL
+		}
+	}
+	return URL
+}
+
+//ParseProfile extracts the profile entities from a user's blob, for use in
+//filters that classify accounts. The v1.1 API only has the URL entities of
+//the bio, so hashtags and mentions are found in its text.
+func ParseProfile(blob []byte) Profile {
+	var u struct {
+		URL         string `json:"url"`
+		Description string `json:"description"`
+		Entities    struct {
+			URL         profileURLs `json:"url"`
+			Description profileURLs `json:"description"`
+		} `json:"entities"`
+	}
+	var p Profile
+	if json.Unmarshal(blob, &u) != nil {
+		return p
+	}
+	if u.URL != "" {
+		p.URL = u.Entities.URL.expand(u.URL)
+	}
+	seen := make(map[string]bool)
+	for _, hashtag := range hashtagPattern.FindAllString(u.Description, -1) {
+		hashtag = strings.ToLower(hashtag[1:])
+		if !seen["#"+hashtag] {
+			seen["#"+hashtag] = true
+			p.Hashtags = append(p.Hashtags, hashtag)
+		}
+	}
+	for _, mention := range mentionPattern.FindAllString(u.Description, -1) {
+		mention = strings.ToLower(mention[1:])
+		if !seen["@"+mention] {
+			seen["@"+mention] = true
+			p.Mentions = append(p.Mentions, mention)
+		}
+	}
+	for _, URL := range urlPattern.FindAllString(u.Description, -1) {
+		URL = u.Entities.Description.expand(URL)
+		if !seen[URL] {
+			seen[URL] = true
+			p.URLs = append(p.URLs, URL)
+		}
+	}
+	return p
+}
+
+//storeProfile replaces the user's rows in the `bio_entities` table with the
+//entities of their bio.
+func (s *Storage) storeProfile(userID int64, p Profile) {
+	chQueryArgs <- &queryArgs{"DELETE FROM bio_entities WHERE user_id=?", []interface{}{userID}}
+	for entityType, values := range map[string][]string{EntityHashtag: p.Hashtags, EntityMention: p.Mentions, EntityURL: p.URLs} {
+		for _, value := range values {
+			chQueryArgs <- &queryArgs{"INSERT OR IGNORE INTO bio_entities (user_id, entity_type, value) VALUES (?, ?, ?)",
+				[]interface{}{userID, entityType, value}}
+		}
+	}
+}
+
+//BackfillProfiles extracts the profile URL, bio entities and verified status
+//of all the stored users, for users stored before they were extracted. It returns the
+//number of users read.
+func (s *Storage) BackfillProfiles() int {
+	rows, err := s.db.Query("SELECT user_id, blob FROM users WHERE blob IS NOT NULL ORDER BY user_id")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var userID int64
+		var blob []byte
+		err = rows.Scan(&userID, &blob)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p := ParseProfile(blob)
+		chQueryArgs <- &queryArgs{"UPDATE users SET url=?, verified=? WHERE user_id=?", []interface{}{p.URL, Verified(blob), userID}}
+		s.storeProfile(userID, p)
+		count++
+	}
+	return count
+}
+
+//GetUsersWithBioEntity gets the IDs of the users whose bio has the entity,
+//for example EntityHashtag and "golang". Hashtags and mentions are matched
+//without regard to case.
+func (s *Storage) GetUsersWithBioEntity(entityType, value string) []int64 {
+	if entityType != EntityURL {
+		value = strings.ToLower(strings.TrimLeft(value, "#@"))
+	}
+	var results []int64
+	s.queryScreenNamesOrIDs("SELECT user_id FROM bio_entities WHERE entity_type=? AND value=? ORDER BY user_id",
+		&results, entityType, value)
+	return results
+}
@@ -0,0 +1,64 @@
+package callosum
+
+import (
+	"log"
+	"time"
+)
+
+//RecheckProtectedUsers looks up the users stored as protected that were not
+//checked in the last recheckAfter, since they may have made their account
+//public. Users found public are stored again and filtered, so that the
+//tweets, friends and followers of the accepted ones are collected by the
+//next rounds, and their `skip_reason` and refusals are cleared, also when
+//they were marked unavailable after being refused as protected. RecheckProtectedUsers
+//returns the number of users found public.
+func (t *TwitterCollector) RecheckProtectedUsers(recheckAfter time.Duration) int {
+	var public int
+	checkedBefore := time.Now().Add(-recheckAfter).Unix()
+	var afterID int64
+	for {
+		var IDs []int64
+		t.s.queryScreenNamesOrIDs(`SELECT user_id FROM users
+			WHERE protected=1 AND protected_checked_at <= ? AND user_id > ?
+			ORDER BY user_id LIMIT ?`, &IDs, checkedBefore, afterID, t.lookupChunk())
+		if len(IDs) == 0 {
+			break
+		}
+		afterID = IDs[len(IDs)-1]
+
+		t.countRequest(UsageProtected, "users/lookup")
+		users, err := t.n.GetUsers(IDs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		checkedAt := time.Now().Unix()
+		for _, u := range users {
+			if u.Protected {
+				continue
+			}
+			t.storeUser(u)
+			t.s.MarkUserSkipped(u.ID, "")
+			t.s.ClearUserFailures(u.ID)
+			public++
+		}
+		for _, ID := range IDs {
+			t.s.MarkProtectedChecked(ID, checkedAt)
+		}
+	}
+	if public > 0 {
+		t.logger.Printf("%d protected users made their account public", public)
+	}
+	return public
+}
+
+//MarkUserProtected marks the user as protected, once Twitter refuses the
+//collection for a user stored before the account was made protected
+func (s *Storage) MarkUserProtected(userID int64) {
+	chPriorityArgs <- &queryArgs{"UPDATE users SET protected=1 WHERE user_id=?", []interface{}{userID}}
+}
+
+//MarkProtectedChecked sets the time the protected user was last looked up
+//again, see RecheckProtectedUsers.
+func (s *Storage) MarkProtectedChecked(userID, checkedAt int64) {
+	chPriorityArgs <- &queryArgs{"UPDATE users SET protected_checked_at=? WHERE user_id=?", []interface{}{checkedAt, userID}}
+}
@@ -0,0 +1,89 @@
+package callosum
+
+import (
+	"log"
+	"strings"
+	"time"
+)
+
+//GetUserTweets gets up to limit tweets of the user from the `tweets` table
+//older than maxID, most recent first. Pass 0 as maxID to start from the
+//most recent tweet.
+func (s *Storage) GetUserTweets(userID, maxID int64, limit int) Tweets {
+	query := `SELECT tweet_id, created_at, langugage, "desc", blob FROM tweets WHERE user_id=?`
+	args := []interface{}{userID}
+	if maxID != 0 {
+		query += " AND tweet_id < ?"
+		args = append(args, maxID)
+	}
+	query += " ORDER BY tweet_id DESC LIMIT ?"
+	args = append(args, limit)
+	return s.queryTweets(query, args...)
+}
+
+func (s *Storage) queryTweets(query string, args ...interface{}) Tweets {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var tweets Tweets
+	for rows.Next() {
+		tweet := &Tweet{}
+		var createdAt int64
+		err = rows.Scan(&tweet.ID, &createdAt, &tweet.Language, &tweet.Text, &tweet.Blob)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tweet.CreatedAt = time.Unix(createdAt, 0).UTC().Format(time.RubyDate)
+		tweets = append(tweets, tweet)
+	}
+	return tweets
+}
+
+//SearchTweets gets up to limit tweets whose text contains text, ignoring
+//case for ASCII letters, most recent first. The full-text index is used when
+//the database has one, see WithFullTextSearch, and text is then matched as a
+//phrase of whole words, or as a substring with the trigram tokenizer.
+func (s *Storage) SearchTweets(text string, limit int) Tweets {
+	if tweets, ok := s.searchFullText(text, limit); ok {
+		return tweets
+	}
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text) + "%"
+	return s.queryTweets(`SELECT tweet_id, created_at, langugage, "desc", blob FROM tweets
+		WHERE "desc" LIKE ? ESCAPE '\' ORDER BY tweet_id DESC LIMIT ?`, pattern, limit)
+}
+
+//GetFollowing gets the IDs of the users the user follows from the
+//`following` and `followers` tables.
+func (s *Storage) GetFollowing(userID int64) []int64 {
+	return s.queryIDs(`SELECT following_id FROM following WHERE user_id=?
+		UNION SELECT user_id FROM followers WHERE follower_id=? ORDER BY 1`, userID, userID)
+}
+
+//GetFollowers gets the IDs of the users following the user from the
+//`followers` and `following` tables.
+func (s *Storage) GetFollowers(userID int64) []int64 {
+	return s.queryIDs(`SELECT follower_id FROM followers WHERE user_id=?
+		UNION SELECT user_id FROM following WHERE following_id=? ORDER BY 1`, userID, userID)
+}
+
+func (s *Storage) queryIDs(query string, args ...interface{}) []int64 {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	IDs := []int64{}
+	for rows.Next() {
+		var ID int64
+		err = rows.Scan(&ID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		IDs = append(IDs, ID)
+	}
+	return IDs
+}
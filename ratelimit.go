@@ -0,0 +1,178 @@
+package callosum
+
+import (
+	"log"
+	"time"
+)
+
+//RateLimits holds the number of requests that can be made to each endpoint
+//of Twitter's API in a rate limit window, using both the user and the
+//application authentication as the Network does. Requests to endpoints that
+//are not listed are not limited by the Network, only by kuruvi.
+var RateLimits = map[string]int{
+	"statuses/user_timeline":     900 + 1500,
+	"statuses/lookup":            900 + 300,
+	"users/show":                 900 + 900,
+	"users/lookup":               900 + 300,
+	"friends/ids":                15 + 15,
+	"followers/ids":              15 + 15,
+	"lists/memberships":          75 + 75,
+	"lists/ownerships":           15 + 15,
+	"lists/members":              900 + 75,
+	"statuses/home_timeline":     15,
+	"favorites/list":             75 + 75,
+	"geo/id/:place_id":           75,
+	"geo/search":                 15,
+	"account/verify_credentials": 75,
+}
+
+//RateLimit is the state of the rate limit window of an endpoint: the
+//requests that can still be made in it and when it resets.
+type RateLimit struct {
+	Endpoint  string
+	Remaining int
+	ResetAt   time.Time
+}
+
+//rateLimiter is implemented by APIs whose rate limit state can be saved and
+//restored across restarts
+type rateLimiter interface {
+	RateLimits() []RateLimit
+	RestoreRateLimits(limits []RateLimit)
+}
+
+//waitForWindow waits for the window of the endpoint to reset when it has no
+//requests left, and counts the request about to be made. Requests waiting
+//for the same window may use it up again before this one is counted, or
+//Twitter may refuse one and spend it, so it waits until a window has a
+//request left.
+func (n *Network) waitForWindow(endpoint string) {
+	limit, ok := RateLimits[endpoint]
+	if !ok {
+		return
+	}
+	n.limitsMutex.Lock()
+	defer n.limitsMutex.Unlock()
+	if n.limits == nil {
+		n.limits = make(map[string]*RateLimit)
+	}
+	for {
+		l := n.limits[endpoint]
+		now := time.Now()
+		if l == nil || !now.Before(l.ResetAt) {
+			l = &RateLimit{Endpoint: endpoint, Remaining: limit, ResetAt: now.Add(n.window)}
+			n.limits[endpoint] = l
+		}
+		if l.Remaining > 0 {
+			l.Remaining--
+			return
+		}
+		n.limitsMutex.Unlock()
+		log.Printf("waiting until %s for the rate limit window of %s", l.ResetAt.Format(time.Kitchen), endpoint)
+		time.Sleep(l.ResetAt.Sub(now))
+		n.limitsMutex.Lock()
+	}
+}
+
+//spendWindow records that Twitter refused a request to the endpoint because
+//its rate limit window is spent.
+func (n *Network) spendWindow(endpoint string) {
+	n.limitsMutex.Lock()
+	defer n.limitsMutex.Unlock()
+	if l := n.limits[endpoint]; l != nil {
+		l.Remaining = 0
+	}
+}
+
+//RateLimits returns the state of the rate limit windows of the endpoints
+//requested in the current window, so that it can be saved with
+//Storage.StoreRateLimits.
+func (n *Network) RateLimits() []RateLimit {
+	n.limitsMutex.Lock()
+	defer n.limitsMutex.Unlock()
+	var limits []RateLimit
+	now := time.Now()
+	for _, l := range n.limits {
+		if now.Before(l.ResetAt) {
+			limits = append(limits, *l)
+		}
+	}
+	return limits
+}
+
+//RestoreRateLimits sets the state of the rate limit windows, for example as
+//saved by a Network before a restart, so that the requests left in a window
+//are not made again. Windows that have reset since are left out.
+func (n *Network) RestoreRateLimits(limits []RateLimit) {
+	n.limitsMutex.Lock()
+	defer n.limitsMutex.Unlock()
+	if n.limits == nil {
+		n.limits = make(map[string]*RateLimit)
+	}
+	now := time.Now()
+	for _, l := range limits {
+		if now.Before(l.ResetAt) {
+			l := l
+			n.limits[l.Endpoint] = &l
+		}
+	}
+}
+
+//StoreRateLimits replaces the rate limit windows stored in the `rate_limits`
+//table with the given ones.
+func (s *Storage) StoreRateLimits(limits []RateLimit) {
+	chPriorityArgs <- &queryArgs{"DELETE FROM rate_limits", nil}
+	for _, l := range limits {
+		chPriorityArgs <- &queryArgs{"INSERT INTO rate_limits (endpoint, remaining, reset_at) VALUES (?, ?, ?)",
+			[]interface{}{l.Endpoint, l.Remaining, l.ResetAt.Unix()}}
+	}
+}
+
+//GetRateLimits gets the rate limit windows stored in the `rate_limits` table
+//that have not reset yet.
+func (s *Storage) GetRateLimits() []RateLimit {
+	rows, err := s.db.Query("SELECT endpoint, remaining, reset_at FROM rate_limits WHERE reset_at > ? ORDER BY endpoint", time.Now().Unix())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var limits []RateLimit
+	for rows.Next() {
+		var l RateLimit
+		var resetAt int64
+		err = rows.Scan(&l.Endpoint, &l.Remaining, &resetAt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		l.ResetAt = time.Unix(resetAt, 0)
+		limits = append(limits, l)
+	}
+	return limits
+}
+
+//SaveRateLimits stores the state of the network's rate limit windows in the
+//database, so that a collector restarted within the windows doesn't make
+//the requests it had already made again. Call it before shutting down,
+//StartCollection saves them once the collection is complete.
+func (t *TwitterCollector) SaveRateLimits() {
+	r, ok := t.n.(rateLimiter)
+	if !ok {
+		return
+	}
+	t.s.StoreRateLimits(r.RateLimits())
+	t.s.flush()
+}
+
+//restoreRateLimits restores the network's rate limit windows saved by SaveRateLimits
+func (t *TwitterCollector) restoreRateLimits() {
+	r, ok := t.n.(rateLimiter)
+	if !ok {
+		return
+	}
+	limits := t.s.GetRateLimits()
+	if len(limits) > 0 {
+		r.RestoreRateLimits(limits)
+		t.logger.Printf("restored the rate limit windows of %d endpoints", len(limits))
+	}
+}
@@ -0,0 +1,51 @@
+package callosum
+
+//Reciprocity holds the mutual-follow rates of a Graph.
+type Reciprocity struct {
+	//Global is the fraction of edges whose reverse edge is also in the graph
+	Global float64
+	//MutualPairs is the number of pairs of users following each other
+	MutualPairs int
+	//PerUser holds, for every user following someone, the fraction of
+	//the users they follow who follow them back
+	PerUser map[int64]float64
+}
+
+//Reciprocity computes the global and per-user reciprocity of the graph.
+func (g *Graph) Reciprocity() *Reciprocity {
+	r := &Reciprocity{PerUser: make(map[int64]float64)}
+
+	followers := make(map[int64]map[int64]bool, len(g.In))
+	for ID, in := range g.In {
+		followers[ID] = make(map[int64]bool, len(in))
+		for _, from := range in {
+			followers[ID][from] = true
+		}
+	}
+
+	var edges, reciprocated int
+	for from, out := range g.Out {
+		var mutual int
+		for _, to := range out {
+			if followers[from][to] {
+				mutual++
+			}
+		}
+		edges += len(out)
+		reciprocated += mutual
+		if len(out) > 0 {
+			r.PerUser[from] = float64(mutual) / float64(len(out))
+		}
+	}
+
+	if edges > 0 {
+		r.Global = float64(reciprocated) / float64(edges)
+	}
+	r.MutualPairs = reciprocated / 2
+	return r
+}
+
+//Reciprocity computes the reciprocity of the follow graph between accepted users.
+func (s *Storage) Reciprocity() *Reciprocity {
+	return s.Graph().Reciprocity()
+}
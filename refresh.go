@@ -0,0 +1,127 @@
+package callosum
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+//profilePhases are the phases run by WithProfilesOnly
+var profilePhases = []string{PhaseUsers, PhaseRefresh}
+
+//WithProfilesOnly collects only the user objects, the profiles and counts,
+//of the seeded and queued users and refreshes them, without their tweets,
+//friends or followers, for studies that only need account metadata. It is
+//the same as WithPhases(PhaseUsers, PhaseRefresh). Queue users by ID with
+//SeedUserIDs.
+func WithProfilesOnly() Option {
+	return WithPhases(profilePhases...)
+}
+
+//SeedUserIDs inserts the given Twitter user IDs into the `userids` table,
+//the queue of users picked up by the users phase.
+func (t *TwitterCollector) SeedUserIDs(userIDs []int64) {
+	t.s.StoreUserIDs(userIDs)
+}
+
+//RefreshAllUsers looks up the stored users whose profile was stored more
+//than the refresh interval ago again, see WithIntervals, as one collection
+//round.
+func (t *TwitterCollector) RefreshAllUsers() {
+	t.runPhase(PhaseRefresh, func() int {
+		return t.RefreshUsers(t.intervals.Refresh)
+	})
+}
+
+//RefreshUsers looks up the stored users whose profile was stored more than
+//refreshAfter ago again and stores their current profile, and the counts of
+//their followers, friends and tweets in the `user_counts` table. Users
+//Twitter no longer returns are left as they are. RefreshUsers returns the
+//number of users refreshed.
+func (t *TwitterCollector) RefreshUsers(refreshAfter time.Duration) int {
+	var refreshed int
+	refreshedBefore := time.Now().Add(-refreshAfter).Unix()
+	var afterID int64
+	for {
+		var IDs []int64
+		t.s.queryScreenNamesOrIDs(`SELECT user_id FROM users
+			WHERE profile_refreshed_at <= ? AND user_id > ?
+			ORDER BY user_id LIMIT ?`, &IDs, refreshedBefore, afterID, t.lookupChunk())
+		if len(IDs) == 0 {
+			break
+		}
+		afterID = IDs[len(IDs)-1]
+
+		t.countRequest(PhaseRefresh, "users/lookup")
+		users, err := t.n.GetUsers(IDs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, u := range users {
+			t.storeUser(u)
+		}
+		refreshed += len(users)
+		//the users that were not returned wait for the next refresh
+		refreshedAt := time.Now().Unix()
+		for _, ID := range IDs {
+			t.s.MarkUserRefreshed(ID, refreshedAt)
+		}
+	}
+	if refreshed > 0 {
+		t.logger.Printf("refreshed %d users", refreshed)
+	}
+	return refreshed
+}
+
+//MarkUserRefreshed sets the time the user's profile was last stored
+func (s *Storage) MarkUserRefreshed(userID, refreshedAt int64) {
+	chPriorityArgs <- &queryArgs{"UPDATE users SET profile_refreshed_at=? WHERE user_id=?", []interface{}{refreshedAt, userID}}
+}
+
+//UserCounts are the counts of a user's followers, friends and tweets at the
+//time the user was stored.
+type UserCounts struct {
+	UserID    int64
+	Followers int
+	Friends   int
+	Tweets    int
+	TakenAt   time.Time
+}
+
+//storeUserCounts stores the counts in the user blob in the `user_counts` table
+func (s *Storage) storeUserCounts(userID, takenAt int64, blob []byte) {
+	var u struct {
+		FollowersCount int `json:"followers_count"`
+		FriendsCount   int `json:"friends_count"`
+		StatusesCount  int `json:"statuses_count"`
+	}
+	if json.Unmarshal(blob, &u) != nil {
+		return
+	}
+	chQueryArgs <- &queryArgs{`INSERT OR REPLACE INTO user_counts (user_id, taken_at, followers, friends, tweets)
+		VALUES (?, ?, ?, ?, ?)`, []interface{}{userID, takenAt, u.FollowersCount, u.FriendsCount, u.StatusesCount}}
+}
+
+//GetUserCounts gets the counts of the user's followers, friends and tweets
+//from the `user_counts` table every time the user was stored, oldest first.
+func (s *Storage) GetUserCounts(userID int64) []UserCounts {
+	rows, err := s.db.Query(`SELECT user_id, followers, friends, tweets, taken_at FROM user_counts
+		WHERE user_id=? ORDER BY taken_at`, userID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var counts []UserCounts
+	for rows.Next() {
+		var c UserCounts
+		var takenAt int64
+		err = rows.Scan(&c.UserID, &c.Followers, &c.Friends, &c.Tweets, &takenAt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.TakenAt = time.Unix(takenAt, 0).UTC()
+		counts = append(counts, c)
+	}
+	return counts
+}
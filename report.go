@@ -0,0 +1,301 @@
+package callosum
+
+import (
+	"encoding/json"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"sort"
+	"text/template"
+	"time"
+)
+
+//ReportFormat is the output format of GenerateReport
+type ReportFormat int
+
+//The formats GenerateReport can produce
+const (
+	Markdown ReportFormat = iota
+	HTML
+)
+
+//TableCount is the number of rows in a table
+type TableCount struct {
+	Table string `json:"table"`
+	Rows  int    `json:"rows"`
+}
+
+//ReportUser is a user listed in a Report
+type ReportUser struct {
+	ID             int64
+	ScreenName     string
+	FollowersCount int
+	Rank           float64
+}
+
+//EndpointCount is the number of requests made to an endpoint
+type EndpointCount struct {
+	Endpoint string
+	Calls    int
+}
+
+//Report summarizes a corpus, see GenerateReport.
+type Report struct {
+	GeneratedAt time.Time
+	Seeds       []string
+	//FirstTweetAt and LastTweetAt span the creation times of the stored tweets
+	FirstTweetAt time.Time
+	LastTweetAt  time.Time
+	//FirstLookAt and LastLookAt span the times the users' timelines were collected
+	FirstLookAt time.Time
+	LastLookAt  time.Time
+	Tables      []TableCount
+	Languages   []LanguageCount
+	//TopUsers are the accepted users with the highest PageRank, when RankUsers
+	//was run, or else with the most followers.
+	TopUsers []ReportUser
+	RankedBy string
+	//APIUsage holds the requests made by the collector's network since it was created
+	APIUsage []EndpointCount
+}
+
+//callCounter is implemented by APIs that count the requests made to each endpoint
+type callCounter interface {
+	Calls() map[string]int
+}
+
+//GenerateReport writes a Markdown or HTML summary of the corpus to w: the
+//seed list, collection period, row counts per table, language breakdown,
+//top users and API usage. This is documentation most datasets need anyway.
+func (t *TwitterCollector) GenerateReport(w io.Writer, format ReportFormat) error {
+	r := t.s.Report(10)
+	for endpoint, calls := range t.APICalls() {
+		r.APIUsage = append(r.APIUsage, EndpointCount{endpoint, calls})
+	}
+	sort.Slice(r.APIUsage, func(i, j int) bool { return r.APIUsage[i].Endpoint < r.APIUsage[j].Endpoint })
+	return r.Write(w, format)
+}
+
+//APICalls returns the number of requests made to each endpoint of Twitter's
+//API since the collector's network was created, or nil when the network
+//doesn't count them.
+func (t *TwitterCollector) APICalls() map[string]int {
+	if c, ok := t.n.(callCounter); ok {
+		return c.Calls()
+	}
+	return nil
+}
+
+//TableCounts counts the rows of every table in the database.
+func (s *Storage) TableCounts() []TableCount {
+	var tables []string
+	var counts []TableCount
+	s.queryScreenNamesOrIDs("SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name", &tables)
+	for _, table := range tables {
+		counts = append(counts, TableCount{table, s.count(`SELECT COUNT(*) FROM "` + table + `"`)})
+	}
+	return counts
+}
+
+//EstimateTableRows estimates the rows of the tables of the database from the
+//statistics of the query planner, gathered by Analyze and refreshed by
+//Maintain, without the full scans of TableCounts. The estimates are as old
+//as the statistics, and the tables that were not analyzed yet are left out.
+func (s *Storage) EstimateTableRows() []TableCount {
+	if s.count("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='sqlite_stat1'") == 0 {
+		return nil
+	}
+	//the first number of stat is the number of rows in the table
+	rows, err := s.db.Query(`SELECT tbl, MAX(CAST(stat AS INTEGER)) FROM sqlite_stat1
+		WHERE tbl IN (SELECT name FROM sqlite_master WHERE type='table') GROUP BY tbl ORDER BY tbl`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var counts []TableCount
+	for rows.Next() {
+		var c TableCount
+		err = rows.Scan(&c.Table, &c.Rows)
+		if err != nil {
+			log.Fatal(err)
+		}
+		counts = append(counts, c)
+	}
+	return counts
+}
+
+//Report gathers the summary of the corpus stored in the database, listing
+//topN top users. The APIUsage of the returned report is empty as the
+//database does not know about it.
+func (s *Storage) Report(topN int) *Report {
+	r := &Report{GeneratedAt: time.Now().UTC()}
+
+	s.queryScreenNamesOrIDs("SELECT screen_name FROM screennames ORDER BY screen_name", &r.Seeds)
+
+	var first, last, firstLook, lastLook int64
+	err := s.db.QueryRow(`SELECT COALESCE(MIN(created_at), 0), COALESCE(MAX(created_at), 0) FROM tweets`).Scan(&first, &last)
+	if err == nil {
+		err = s.db.QueryRow(`SELECT COALESCE(MIN(last_looked_at), 0), COALESCE(MAX(last_looked_at), 0)
+			FROM users WHERE last_looked_at > 0`).Scan(&firstLook, &lastLook)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	r.FirstTweetAt, r.LastTweetAt = unixOrZero(first), unixOrZero(last)
+	r.FirstLookAt, r.LastLookAt = unixOrZero(firstLook), unixOrZero(lastLook)
+
+	r.Tables = s.TableCounts()
+
+	r.Languages = s.LanguageReport()
+	r.TopUsers, r.RankedBy = s.topUsers(topN)
+	return r
+}
+
+func unixOrZero(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+func (s *Storage) topUsers(n int) ([]ReportUser, string) {
+	var users []ReportUser
+	rankedBy := "PageRank"
+	query := `SELECT users.user_id, users.blob, user_rank.rank FROM users
+		JOIN user_rank ON user_rank.user_id = users.user_id
+		WHERE users.accepted=1`
+	if s.count("SELECT COUNT(*) FROM user_rank") == 0 {
+		rankedBy = "followers"
+		query = "SELECT user_id, blob, 0 FROM users WHERE accepted=1"
+	}
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u ReportUser
+		var blob []byte
+		err = rows.Scan(&u.ID, &blob, &u.Rank)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var fields struct {
+			ScreenName     string `json:"screen_name"`
+			FollowersCount int    `json:"followers_count"`
+		}
+		json.Unmarshal(blob, &fields)
+		u.ScreenName, u.FollowersCount = fields.ScreenName, fields.FollowersCount
+		users = append(users, u)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Rank != users[j].Rank {
+			return users[i].Rank > users[j].Rank
+		}
+		return users[i].FollowersCount > users[j].FollowersCount
+	})
+	if len(users) > n {
+		users = users[:n]
+	}
+	return users, rankedBy
+}
+
+const markdownReport = `# Corpus report
+
+Generated at {{date .GeneratedAt}}.
+
+## Seeds
+
+{{range .Seeds}}- @{{.}}
+{{else}}No seeds.
+{{end}}
+## Collection period
+
+- Tweets created between {{date .FirstTweetAt}} and {{date .LastTweetAt}}
+- Timelines collected between {{date .FirstLookAt}} and {{date .LastLookAt}}
+
+## Tables
+
+| Table | Rows |
+|-------|-----:|
+{{range .Tables}}| {{.Table}} | {{.Rows}} |
+{{end}}
+## Languages
+
+| Language | Tweets | % | Users | % |
+|----------|-------:|--:|------:|--:|
+{{range .Languages}}| {{.Language}} | {{.Tweets}} | {{printf "%.1f" .TweetsPercent}} | {{.Users}} | {{printf "%.1f" .UsersPercent}} |
+{{end}}
+## Top users by {{.RankedBy}}
+
+| User | ID | Followers | PageRank |
+|------|---:|----------:|---------:|
+{{range .TopUsers}}| @{{.ScreenName}} | {{.ID}} | {{.FollowersCount}} | {{printf "%.6f" .Rank}} |
+{{end}}
+## API usage
+
+| Endpoint | Requests |
+|----------|---------:|
+{{range .APIUsage}}| {{.Endpoint}} | {{.Calls}} |
+{{end}}`
+
+const htmlReport = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Corpus report</title></head>
+<body>
+<h1>Corpus report</h1>
+<p>Generated at {{date .GeneratedAt}}.</p>
+<h2>Seeds</h2>
+<ul>{{range .Seeds}}<li>@{{.}}</li>{{end}}</ul>
+<h2>Collection period</h2>
+<ul>
+<li>Tweets created between {{date .FirstTweetAt}} and {{date .LastTweetAt}}</li>
+<li>Timelines collected between {{date .FirstLookAt}} and {{date .LastLookAt}}</li>
+</ul>
+<h2>Tables</h2>
+<table>
+<tr><th>Table</th><th>Rows</th></tr>
+{{range .Tables}}<tr><td>{{.Table}}</td><td>{{.Rows}}</td></tr>
+{{end}}</table>
+<h2>Languages</h2>
+<table>
+<tr><th>Language</th><th>Tweets</th><th>%</th><th>Users</th><th>%</th></tr>
+{{range .Languages}}<tr><td>{{.Language}}</td><td>{{.Tweets}}</td><td>{{printf "%.1f" .TweetsPercent}}</td><td>{{.Users}}</td><td>{{printf "%.1f" .UsersPercent}}</td></tr>
+{{end}}</table>
+<h2>Top users by {{.RankedBy}}</h2>
+<table>
+<tr><th>User</th><th>ID</th><th>Followers</th><th>PageRank</th></tr>
+{{range .TopUsers}}<tr><td>@{{.ScreenName}}</td><td>{{.ID}}</td><td>{{.FollowersCount}}</td><td>{{printf "%.6f" .Rank}}</td></tr>
+{{end}}</table>
+<h2>API usage</h2>
+<table>
+<tr><th>Endpoint</th><th>Requests</th></tr>
+{{range .APIUsage}}<tr><td>{{.Endpoint}}</td><td>{{.Calls}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`
+
+func formatReportDate(t time.Time) string {
+	if t.IsZero() {
+		return "n/a"
+	}
+	return t.Format("2006-01-02 15:04 MST")
+}
+
+//Write writes the report to w in the given format.
+func (r *Report) Write(w io.Writer, format ReportFormat) error {
+	switch format {
+	case Markdown:
+		tmpl := template.Must(template.New("report").Funcs(template.FuncMap{"date": formatReportDate}).Parse(markdownReport))
+		return tmpl.Execute(w, r)
+	case HTML:
+		tmpl := htmltemplate.Must(htmltemplate.New("report").Funcs(htmltemplate.FuncMap{"date": formatReportDate}).Parse(htmlReport))
+		return tmpl.Execute(w, r)
+	}
+	log.Fatalf("unknown report format %d", format)
+	return nil
+}
@@ -0,0 +1,204 @@
+package callosum
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//MaxQueryLimit caps the number of tweets a single request to the QueryHandler returns
+const MaxQueryLimit = 1000
+
+//QueryUser is a user as served by the QueryHandler
+type QueryUser struct {
+	ID          int64           `json:"id"`
+	ScreenName  string          `json:"screen_name"`
+	Description string          `json:"description"`
+	Protected   bool            `json:"protected"`
+	Accepted    bool            `json:"accepted"`
+	SkipReason  string          `json:"skip_reason,omitempty"`
+	User        json.RawMessage `json:"user,omitempty"`
+}
+
+//QueryTweet is a tweet as served by the QueryHandler
+type QueryTweet struct {
+	ID        int64           `json:"id"`
+	CreatedAt string          `json:"created_at"`
+	Language  string          `json:"lang"`
+	Text      string          `json:"text"`
+	Tweet     json.RawMessage `json:"tweet,omitempty"`
+}
+
+//QueryHandler serves read-only JSON queries over the corpus, so dashboards
+//and notebooks can use it without SQLite drivers:
+//
+//	GET /users/<id or screen name>            the user
+//	GET /users/<id>/tweets?max_id=&limit=     their tweets, most recent first
+//	GET /users/<id>/following                 the IDs they follow
+//	GET /users/<id>/followers                 the IDs following them
+//	GET /users/<id>/collection                the attempts at collecting them and
+//	                                          the tweets and edges collected
+//	GET /search?q=&limit=                     tweets containing q, most recent first
+//	GET /stats                                rows per table and tweets per language
+//	GET /usage?run=                           API requests per run, day and endpoint
+//
+//limit defaults to 100 and is capped at MaxQueryLimit. The Twitter objects
+//are left out unless blobs=1 is given. Serve it on its own, for example:
+//
+//	http.ListenAndServe(":8080", s.QueryHandler())
+func (s *Storage) QueryHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", s.serveUsers)
+	mux.HandleFunc("/search", s.serveSearch)
+	mux.HandleFunc("/stats", s.serveStats)
+	mux.HandleFunc("/usage", s.serveUsage)
+	return readOnly(mux)
+}
+
+func readOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+//queryLimit parses the limit parameter
+func queryLimit(r *http.Request) (int, bool) {
+	limit := 100
+	if l := r.FormValue("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return 0, false
+		}
+	}
+	if limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+	return limit, true
+}
+
+func queryTweets(tweets Tweets, blobs bool) []QueryTweet {
+	results := make([]QueryTweet, 0, len(tweets))
+	for _, tweet := range tweets {
+		t := QueryTweet{
+			ID:        tweet.ID,
+			CreatedAt: tweet.CreatedAt,
+			Language:  tweet.Language,
+			Text:      tweet.Text,
+		}
+		if blobs {
+			t.Tweet = tweet.Blob
+		}
+		results = append(results, t)
+	}
+	return results
+}
+
+func (s *Storage) serveUsers(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/users/"), "/")
+	if parts[0] == "" || len(parts) > 2 {
+		writeJSONError(w, http.StatusNotFound, "not found")
+		return
+	}
+	var screenNameOrID interface{} = parts[0]
+	if ID, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
+		screenNameOrID = ID
+	}
+	blobs := r.FormValue("blobs") == "1"
+
+	if len(parts) == 1 {
+		u, err := s.LookupUser(screenNameOrID)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			writeJSONError(w, http.StatusNotFound, "user not found")
+			return
+		case err != nil:
+			writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		result := QueryUser{
+			ID:          u.ID,
+			ScreenName:  u.ScreenName,
+			Description: u.Description,
+			Protected:   u.Protected != 0,
+			Accepted:    u.Accepted != 0,
+			SkipReason:  u.SkipReason,
+		}
+		if blobs {
+			result.User = u.Blob
+		}
+		writeJSON(w, result)
+		return
+	}
+
+	ID, ok := screenNameOrID.(int64)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "user ID needs to be a number")
+		return
+	}
+	switch parts[1] {
+	case "tweets":
+		limit, ok := queryLimit(r)
+		maxID, err := strconv.ParseInt(r.FormValue("max_id"), 10, 64)
+		if r.FormValue("max_id") == "" {
+			maxID, err = 0, nil
+		}
+		if !ok || err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid limit or max_id")
+			return
+		}
+		writeJSON(w, queryTweets(s.GetUserTweets(ID, maxID, limit), blobs))
+	case "following":
+		writeJSON(w, s.GetFollowing(ID))
+	case "followers":
+		writeJSON(w, s.GetFollowers(ID))
+	case "collection":
+		c, err := s.GetCollectionStats(ID)
+		if errors.Is(err, ErrNotFound) {
+			writeJSONError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		writeJSON(w, c)
+	default:
+		writeJSONError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Storage) serveSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.FormValue("q")
+	limit, ok := queryLimit(r)
+	if q == "" || !ok {
+		writeJSONError(w, http.StatusBadRequest, "q is required and limit needs to be a positive number")
+		return
+	}
+	writeJSON(w, queryTweets(s.SearchTweets(q, limit), r.FormValue("blobs") == "1"))
+}
+
+func (s *Storage) serveStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, struct {
+		Tables    []TableCount    `json:"tables"`
+		Languages []LanguageCount `json:"languages"`
+	}{s.TableCounts(), s.LanguageReport()})
+}
@@ -0,0 +1,891 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: callosum.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ExportRequest_Format int32
+
+const (
+	ExportRequest_TWARC_V1   ExportRequest_Format = 0
+	ExportRequest_TWARC_V2   ExportRequest_Format = 1
+	ExportRequest_TEXT       ExportRequest_Format = 2
+	ExportRequest_PARTITIONS ExportRequest_Format = 3
+)
+
+// Enum value maps for ExportRequest_Format.
+var (
+	ExportRequest_Format_name = map[int32]string{
+		0: "TWARC_V1",
+		1: "TWARC_V2",
+		2: "TEXT",
+		3: "PARTITIONS",
+	}
+	ExportRequest_Format_value = map[string]int32{
+		"TWARC_V1":   0,
+		"TWARC_V2":   1,
+		"TEXT":       2,
+		"PARTITIONS": 3,
+	}
+)
+
+func (x ExportRequest_Format) Enum() *ExportRequest_Format {
+	p := new(ExportRequest_Format)
+	*p = x
+	return p
+}
+
+func (x ExportRequest_Format) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ExportRequest_Format) Descriptor() protoreflect.EnumDescriptor {
+	return file_callosum_proto_enumTypes[0].Descriptor()
+}
+
+func (ExportRequest_Format) Type() protoreflect.EnumType {
+	return &file_callosum_proto_enumTypes[0]
+}
+
+func (x ExportRequest_Format) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ExportRequest_Format.Descriptor instead.
+func (ExportRequest_Format) EnumDescriptor() ([]byte, []int) {
+	return file_callosum_proto_rawDescGZIP(), []int{8, 0}
+}
+
+type SeedRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ScreenNames   []string               `protobuf:"bytes,1,rep,name=screen_names,json=screenNames,proto3" json:"screen_names,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SeedRequest) Reset() {
+	*x = SeedRequest{}
+	mi := &file_callosum_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SeedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SeedRequest) ProtoMessage() {}
+
+func (x *SeedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_callosum_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SeedRequest.ProtoReflect.Descriptor instead.
+func (*SeedRequest) Descriptor() ([]byte, []int) {
+	return file_callosum_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SeedRequest) GetScreenNames() []string {
+	if x != nil {
+		return x.ScreenNames
+	}
+	return nil
+}
+
+type SeedResponse struct {
+	state                  protoimpl.MessageState `protogen:"open.v1"`
+	UnprocessedScreenNames int64                  `protobuf:"varint,1,opt,name=unprocessed_screen_names,json=unprocessedScreenNames,proto3" json:"unprocessed_screen_names,omitempty"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *SeedResponse) Reset() {
+	*x = SeedResponse{}
+	mi := &file_callosum_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SeedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SeedResponse) ProtoMessage() {}
+
+func (x *SeedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_callosum_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SeedResponse.ProtoReflect.Descriptor instead.
+func (*SeedResponse) Descriptor() ([]byte, []int) {
+	return file_callosum_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SeedResponse) GetUnprocessedScreenNames() int64 {
+	if x != nil {
+		return x.UnprocessedScreenNames
+	}
+	return 0
+}
+
+type StatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusRequest) Reset() {
+	*x = StatusRequest{}
+	mi := &file_callosum_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusRequest) ProtoMessage() {}
+
+func (x *StatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_callosum_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return file_callosum_proto_rawDescGZIP(), []int{2}
+}
+
+type StatusResponse struct {
+	state                  protoimpl.MessageState `protogen:"open.v1"`
+	Healthy                bool                   `protobuf:"varint,1,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	Complete               bool                   `protobuf:"varint,2,opt,name=complete,proto3" json:"complete,omitempty"`
+	TableRows              map[string]int64       `protobuf:"bytes,3,rep,name=table_rows,json=tableRows,proto3" json:"table_rows,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	UnprocessedScreenNames int64                  `protobuf:"varint,4,opt,name=unprocessed_screen_names,json=unprocessedScreenNames,proto3" json:"unprocessed_screen_names,omitempty"`
+	UnprocessedUserIds     int64                  `protobuf:"varint,5,opt,name=unprocessed_user_ids,json=unprocessedUserIds,proto3" json:"unprocessed_user_ids,omitempty"`
+	WriterQueued           int64                  `protobuf:"varint,6,opt,name=writer_queued,json=writerQueued,proto3" json:"writer_queued,omitempty"`
+	LastWriteAt            int64                  `protobuf:"varint,7,opt,name=last_write_at,json=lastWriteAt,proto3" json:"last_write_at,omitempty"`
+	LastApiCallAt          int64                  `protobuf:"varint,8,opt,name=last_api_call_at,json=lastApiCallAt,proto3" json:"last_api_call_at,omitempty"`
+	CredentialsValid       bool                   `protobuf:"varint,9,opt,name=credentials_valid,json=credentialsValid,proto3" json:"credentials_valid,omitempty"`
+	ApiCalls               map[string]int64       `protobuf:"bytes,10,rep,name=api_calls,json=apiCalls,proto3" json:"api_calls,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *StatusResponse) Reset() {
+	*x = StatusResponse{}
+	mi := &file_callosum_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusResponse) ProtoMessage() {}
+
+func (x *StatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_callosum_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
+func (*StatusResponse) Descriptor() ([]byte, []int) {
+	return file_callosum_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StatusResponse) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *StatusResponse) GetComplete() bool {
+	if x != nil {
+		return x.Complete
+	}
+	return false
+}
+
+func (x *StatusResponse) GetTableRows() map[string]int64 {
+	if x != nil {
+		return x.TableRows
+	}
+	return nil
+}
+
+func (x *StatusResponse) GetUnprocessedScreenNames() int64 {
+	if x != nil {
+		return x.UnprocessedScreenNames
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetUnprocessedUserIds() int64 {
+	if x != nil {
+		return x.UnprocessedUserIds
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetWriterQueued() int64 {
+	if x != nil {
+		return x.WriterQueued
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetLastWriteAt() int64 {
+	if x != nil {
+		return x.LastWriteAt
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetLastApiCallAt() int64 {
+	if x != nil {
+		return x.LastApiCallAt
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetCredentialsValid() bool {
+	if x != nil {
+		return x.CredentialsValid
+	}
+	return false
+}
+
+func (x *StatusResponse) GetApiCalls() map[string]int64 {
+	if x != nil {
+		return x.ApiCalls
+	}
+	return nil
+}
+
+type GetUserRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to User:
+	//
+	//	*GetUserRequest_Id
+	//	*GetUserRequest_ScreenName
+	User          isGetUserRequest_User `protobuf_oneof:"user"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUserRequest) Reset() {
+	*x = GetUserRequest{}
+	mi := &file_callosum_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUserRequest) ProtoMessage() {}
+
+func (x *GetUserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_callosum_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUserRequest.ProtoReflect.Descriptor instead.
+func (*GetUserRequest) Descriptor() ([]byte, []int) {
+	return file_callosum_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetUserRequest) GetUser() isGetUserRequest_User {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+func (x *GetUserRequest) GetId() int64 {
+	if x != nil {
+		if x, ok := x.User.(*GetUserRequest_Id); ok {
+			return x.Id
+		}
+	}
+	return 0
+}
+
+func (x *GetUserRequest) GetScreenName() string {
+	if x != nil {
+		if x, ok := x.User.(*GetUserRequest_ScreenName); ok {
+			return x.ScreenName
+		}
+	}
+	return ""
+}
+
+type isGetUserRequest_User interface {
+	isGetUserRequest_User()
+}
+
+type GetUserRequest_Id struct {
+	Id int64 `protobuf:"varint,1,opt,name=id,proto3,oneof"`
+}
+
+type GetUserRequest_ScreenName struct {
+	ScreenName string `protobuf:"bytes,2,opt,name=screen_name,json=screenName,proto3,oneof"`
+}
+
+func (*GetUserRequest_Id) isGetUserRequest_User() {}
+
+func (*GetUserRequest_ScreenName) isGetUserRequest_User() {}
+
+type User struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	ScreenName    string                 `protobuf:"bytes,2,opt,name=screen_name,json=screenName,proto3" json:"screen_name,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Protected     bool                   `protobuf:"varint,4,opt,name=protected,proto3" json:"protected,omitempty"`
+	Processed     bool                   `protobuf:"varint,5,opt,name=processed,proto3" json:"processed,omitempty"`
+	Accepted      bool                   `protobuf:"varint,6,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	SkipReason    string                 `protobuf:"bytes,7,opt,name=skip_reason,json=skipReason,proto3" json:"skip_reason,omitempty"`
+	LatestTweetId int64                  `protobuf:"varint,8,opt,name=latest_tweet_id,json=latestTweetId,proto3" json:"latest_tweet_id,omitempty"`
+	Blob          []byte                 `protobuf:"bytes,9,opt,name=blob,proto3" json:"blob,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *User) Reset() {
+	*x = User{}
+	mi := &file_callosum_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *User) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*User) ProtoMessage() {}
+
+func (x *User) ProtoReflect() protoreflect.Message {
+	mi := &file_callosum_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use User.ProtoReflect.Descriptor instead.
+func (*User) Descriptor() ([]byte, []int) {
+	return file_callosum_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *User) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *User) GetScreenName() string {
+	if x != nil {
+		return x.ScreenName
+	}
+	return ""
+}
+
+func (x *User) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *User) GetProtected() bool {
+	if x != nil {
+		return x.Protected
+	}
+	return false
+}
+
+func (x *User) GetProcessed() bool {
+	if x != nil {
+		return x.Processed
+	}
+	return false
+}
+
+func (x *User) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+func (x *User) GetSkipReason() string {
+	if x != nil {
+		return x.SkipReason
+	}
+	return ""
+}
+
+func (x *User) GetLatestTweetId() int64 {
+	if x != nil {
+		return x.LatestTweetId
+	}
+	return 0
+}
+
+func (x *User) GetBlob() []byte {
+	if x != nil {
+		return x.Blob
+	}
+	return nil
+}
+
+type ListTweetsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        int64                  `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	MaxId         int64                  `protobuf:"varint,2,opt,name=max_id,json=maxId,proto3" json:"max_id,omitempty"`
+	Limit         int32                  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTweetsRequest) Reset() {
+	*x = ListTweetsRequest{}
+	mi := &file_callosum_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTweetsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTweetsRequest) ProtoMessage() {}
+
+func (x *ListTweetsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_callosum_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTweetsRequest.ProtoReflect.Descriptor instead.
+func (*ListTweetsRequest) Descriptor() ([]byte, []int) {
+	return file_callosum_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListTweetsRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *ListTweetsRequest) GetMaxId() int64 {
+	if x != nil {
+		return x.MaxId
+	}
+	return 0
+}
+
+func (x *ListTweetsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type Tweet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId        int64                  `protobuf:"varint,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	CreatedAt     int64                  `protobuf:"varint,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	Language      string                 `protobuf:"bytes,4,opt,name=language,proto3" json:"language,omitempty"`
+	Text          string                 `protobuf:"bytes,5,opt,name=text,proto3" json:"text,omitempty"`
+	Blob          []byte                 `protobuf:"bytes,6,opt,name=blob,proto3" json:"blob,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Tweet) Reset() {
+	*x = Tweet{}
+	mi := &file_callosum_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Tweet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Tweet) ProtoMessage() {}
+
+func (x *Tweet) ProtoReflect() protoreflect.Message {
+	mi := &file_callosum_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Tweet.ProtoReflect.Descriptor instead.
+func (*Tweet) Descriptor() ([]byte, []int) {
+	return file_callosum_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Tweet) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Tweet) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *Tweet) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *Tweet) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *Tweet) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Tweet) GetBlob() []byte {
+	if x != nil {
+		return x.Blob
+	}
+	return nil
+}
+
+type ExportRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Format        ExportRequest_Format   `protobuf:"varint,1,opt,name=format,proto3,enum=callosum.ExportRequest_Format" json:"format,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportRequest) Reset() {
+	*x = ExportRequest{}
+	mi := &file_callosum_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportRequest) ProtoMessage() {}
+
+func (x *ExportRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_callosum_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportRequest.ProtoReflect.Descriptor instead.
+func (*ExportRequest) Descriptor() ([]byte, []int) {
+	return file_callosum_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ExportRequest) GetFormat() ExportRequest_Format {
+	if x != nil {
+		return x.Format
+	}
+	return ExportRequest_TWARC_V1
+}
+
+type ExportChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportChunk) Reset() {
+	*x = ExportChunk{}
+	mi := &file_callosum_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportChunk) ProtoMessage() {}
+
+func (x *ExportChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_callosum_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportChunk.ProtoReflect.Descriptor instead.
+func (*ExportChunk) Descriptor() ([]byte, []int) {
+	return file_callosum_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ExportChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_callosum_proto protoreflect.FileDescriptor
+
+const file_callosum_proto_rawDesc = "" +
+	"\n" +
+	"\x0ecallosum.proto\x12\bcallosum\"0\n" +
+	"\vSeedRequest\x12!\n" +
+	"\fscreen_names\x18\x01 \x03(\tR\vscreenNames\"H\n" +
+	"\fSeedResponse\x128\n" +
+	"\x18unprocessed_screen_names\x18\x01 \x01(\x03R\x16unprocessedScreenNames\"\x0f\n" +
+	"\rStatusRequest\"\xd9\x04\n" +
+	"\x0eStatusResponse\x12\x18\n" +
+	"\ahealthy\x18\x01 \x01(\bR\ahealthy\x12\x1a\n" +
+	"\bcomplete\x18\x02 \x01(\bR\bcomplete\x12F\n" +
+	"\n" +
+	"table_rows\x18\x03 \x03(\v2'.callosum.StatusResponse.TableRowsEntryR\ttableRows\x128\n" +
+	"\x18unprocessed_screen_names\x18\x04 \x01(\x03R\x16unprocessedScreenNames\x120\n" +
+	"\x14unprocessed_user_ids\x18\x05 \x01(\x03R\x12unprocessedUserIds\x12#\n" +
+	"\rwriter_queued\x18\x06 \x01(\x03R\fwriterQueued\x12\"\n" +
+	"\rlast_write_at\x18\a \x01(\x03R\vlastWriteAt\x12'\n" +
+	"\x10last_api_call_at\x18\b \x01(\x03R\rlastApiCallAt\x12+\n" +
+	"\x11credentials_valid\x18\t \x01(\bR\x10credentialsValid\x12C\n" +
+	"\tapi_calls\x18\n" +
+	" \x03(\v2&.callosum.StatusResponse.ApiCallsEntryR\bapiCalls\x1a<\n" +
+	"\x0eTableRowsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1a;\n" +
+	"\rApiCallsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"M\n" +
+	"\x0eGetUserRequest\x12\x10\n" +
+	"\x02id\x18\x01 \x01(\x03H\x00R\x02id\x12!\n" +
+	"\vscreen_name\x18\x02 \x01(\tH\x00R\n" +
+	"screenNameB\x06\n" +
+	"\x04user\"\x8e\x02\n" +
+	"\x04User\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1f\n" +
+	"\vscreen_name\x18\x02 \x01(\tR\n" +
+	"screenName\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x1c\n" +
+	"\tprotected\x18\x04 \x01(\bR\tprotected\x12\x1c\n" +
+	"\tprocessed\x18\x05 \x01(\bR\tprocessed\x12\x1a\n" +
+	"\baccepted\x18\x06 \x01(\bR\baccepted\x12\x1f\n" +
+	"\vskip_reason\x18\a \x01(\tR\n" +
+	"skipReason\x12&\n" +
+	"\x0flatest_tweet_id\x18\b \x01(\x03R\rlatestTweetId\x12\x12\n" +
+	"\x04blob\x18\t \x01(\fR\x04blob\"Y\n" +
+	"\x11ListTweetsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\x03R\x06userId\x12\x15\n" +
+	"\x06max_id\x18\x02 \x01(\x03R\x05maxId\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\"\x93\x01\n" +
+	"\x05Tweet\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\x03R\x06userId\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x03 \x01(\x03R\tcreatedAt\x12\x1a\n" +
+	"\blanguage\x18\x04 \x01(\tR\blanguage\x12\x12\n" +
+	"\x04text\x18\x05 \x01(\tR\x04text\x12\x12\n" +
+	"\x04blob\x18\x06 \x01(\fR\x04blob\"\x87\x01\n" +
+	"\rExportRequest\x126\n" +
+	"\x06format\x18\x01 \x01(\x0e2\x1e.callosum.ExportRequest.FormatR\x06format\">\n" +
+	"\x06Format\x12\f\n" +
+	"\bTWARC_V1\x10\x00\x12\f\n" +
+	"\bTWARC_V2\x10\x01\x12\b\n" +
+	"\x04TEXT\x10\x02\x12\x0e\n" +
+	"\n" +
+	"PARTITIONS\x10\x03\"!\n" +
+	"\vExportChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data2\xad\x02\n" +
+	"\bCallosum\x125\n" +
+	"\x04Seed\x12\x15.callosum.SeedRequest\x1a\x16.callosum.SeedResponse\x12;\n" +
+	"\x06Status\x12\x17.callosum.StatusRequest\x1a\x18.callosum.StatusResponse\x123\n" +
+	"\aGetUser\x12\x18.callosum.GetUserRequest\x1a\x0e.callosum.User\x12<\n" +
+	"\n" +
+	"ListTweets\x12\x1b.callosum.ListTweetsRequest\x1a\x0f.callosum.Tweet0\x01\x12:\n" +
+	"\x06Export\x12\x17.callosum.ExportRequest\x1a\x15.callosum.ExportChunk0\x01B Z\x1egithub.com/venkat/callosum/rpcb\x06proto3"
+
+var (
+	file_callosum_proto_rawDescOnce sync.Once
+	file_callosum_proto_rawDescData []byte
+)
+
+func file_callosum_proto_rawDescGZIP() []byte {
+	file_callosum_proto_rawDescOnce.Do(func() {
+		file_callosum_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_callosum_proto_rawDesc), len(file_callosum_proto_rawDesc)))
+	})
+	return file_callosum_proto_rawDescData
+}
+
+var file_callosum_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_callosum_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_callosum_proto_goTypes = []any{
+	(ExportRequest_Format)(0), // 0: callosum.ExportRequest.Format
+	(*SeedRequest)(nil),       // 1: callosum.SeedRequest
+	(*SeedResponse)(nil),      // 2: callosum.SeedResponse
+	(*StatusRequest)(nil),     // 3: callosum.StatusRequest
+	(*StatusResponse)(nil),    // 4: callosum.StatusResponse
+	(*GetUserRequest)(nil),    // 5: callosum.GetUserRequest
+	(*User)(nil),              // 6: callosum.User
+	(*ListTweetsRequest)(nil), // 7: callosum.ListTweetsRequest
+	(*Tweet)(nil),             // 8: callosum.Tweet
+	(*ExportRequest)(nil),     // 9: callosum.ExportRequest
+	(*ExportChunk)(nil),       // 10: callosum.ExportChunk
+	nil,                       // 11: callosum.StatusResponse.TableRowsEntry
+	nil,                       // 12: callosum.StatusResponse.ApiCallsEntry
+}
+var file_callosum_proto_depIdxs = []int32{
+	11, // 0: callosum.StatusResponse.table_rows:type_name -> callosum.StatusResponse.TableRowsEntry
+	12, // 1: callosum.StatusResponse.api_calls:type_name -> callosum.StatusResponse.ApiCallsEntry
+	0,  // 2: callosum.ExportRequest.format:type_name -> callosum.ExportRequest.Format
+	1,  // 3: callosum.Callosum.Seed:input_type -> callosum.SeedRequest
+	3,  // 4: callosum.Callosum.Status:input_type -> callosum.StatusRequest
+	5,  // 5: callosum.Callosum.GetUser:input_type -> callosum.GetUserRequest
+	7,  // 6: callosum.Callosum.ListTweets:input_type -> callosum.ListTweetsRequest
+	9,  // 7: callosum.Callosum.Export:input_type -> callosum.ExportRequest
+	2,  // 8: callosum.Callosum.Seed:output_type -> callosum.SeedResponse
+	4,  // 9: callosum.Callosum.Status:output_type -> callosum.StatusResponse
+	6,  // 10: callosum.Callosum.GetUser:output_type -> callosum.User
+	8,  // 11: callosum.Callosum.ListTweets:output_type -> callosum.Tweet
+	10, // 12: callosum.Callosum.Export:output_type -> callosum.ExportChunk
+	8,  // [8:13] is the sub-list for method output_type
+	3,  // [3:8] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_callosum_proto_init() }
+func file_callosum_proto_init() {
+	if File_callosum_proto != nil {
+		return
+	}
+	file_callosum_proto_msgTypes[4].OneofWrappers = []any{
+		(*GetUserRequest_Id)(nil),
+		(*GetUserRequest_ScreenName)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_callosum_proto_rawDesc), len(file_callosum_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_callosum_proto_goTypes,
+		DependencyIndexes: file_callosum_proto_depIdxs,
+		EnumInfos:         file_callosum_proto_enumTypes,
+		MessageInfos:      file_callosum_proto_msgTypes,
+	}.Build()
+	File_callosum_proto = out.File
+	file_callosum_proto_goTypes = nil
+	file_callosum_proto_depIdxs = nil
+}
@@ -0,0 +1,280 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: callosum.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Callosum_Seed_FullMethodName       = "/callosum.Callosum/Seed"
+	Callosum_Status_FullMethodName     = "/callosum.Callosum/Status"
+	Callosum_GetUser_FullMethodName    = "/callosum.Callosum/GetUser"
+	Callosum_ListTweets_FullMethodName = "/callosum.Callosum/ListTweets"
+	Callosum_Export_FullMethodName     = "/callosum.Callosum/Export"
+)
+
+// CallosumClient is the client API for Callosum service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CallosumClient interface {
+	Seed(ctx context.Context, in *SeedRequest, opts ...grpc.CallOption) (*SeedResponse, error)
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error)
+	ListTweets(ctx context.Context, in *ListTweetsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Tweet], error)
+	Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportChunk], error)
+}
+
+type callosumClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCallosumClient(cc grpc.ClientConnInterface) CallosumClient {
+	return &callosumClient{cc}
+}
+
+func (c *callosumClient) Seed(ctx context.Context, in *SeedRequest, opts ...grpc.CallOption) (*SeedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SeedResponse)
+	err := c.cc.Invoke(ctx, Callosum_Seed_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *callosumClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, Callosum_Status_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *callosumClient) GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(User)
+	err := c.cc.Invoke(ctx, Callosum_GetUser_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *callosumClient) ListTweets(ctx context.Context, in *ListTweetsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Tweet], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Callosum_ServiceDesc.Streams[0], Callosum_ListTweets_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListTweetsRequest, Tweet]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Callosum_ListTweetsClient = grpc.ServerStreamingClient[Tweet]
+
+func (c *callosumClient) Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ExportChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Callosum_ServiceDesc.Streams[1], Callosum_Export_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportRequest, ExportChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Callosum_ExportClient = grpc.ServerStreamingClient[ExportChunk]
+
+// CallosumServer is the server API for Callosum service.
+// All implementations must embed UnimplementedCallosumServer
+// for forward compatibility.
+type CallosumServer interface {
+	Seed(context.Context, *SeedRequest) (*SeedResponse, error)
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	GetUser(context.Context, *GetUserRequest) (*User, error)
+	ListTweets(*ListTweetsRequest, grpc.ServerStreamingServer[Tweet]) error
+	Export(*ExportRequest, grpc.ServerStreamingServer[ExportChunk]) error
+	mustEmbedUnimplementedCallosumServer()
+}
+
+// UnimplementedCallosumServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCallosumServer struct{}
+
+func (UnimplementedCallosumServer) Seed(context.Context, *SeedRequest) (*SeedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Seed not implemented")
+}
+func (UnimplementedCallosumServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedCallosumServer) GetUser(context.Context, *GetUserRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUser not implemented")
+}
+func (UnimplementedCallosumServer) ListTweets(*ListTweetsRequest, grpc.ServerStreamingServer[Tweet]) error {
+	return status.Errorf(codes.Unimplemented, "method ListTweets not implemented")
+}
+func (UnimplementedCallosumServer) Export(*ExportRequest, grpc.ServerStreamingServer[ExportChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedCallosumServer) mustEmbedUnimplementedCallosumServer() {}
+func (UnimplementedCallosumServer) testEmbeddedByValue()                  {}
+
+// UnsafeCallosumServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CallosumServer will
+// result in compilation errors.
+type UnsafeCallosumServer interface {
+	mustEmbedUnimplementedCallosumServer()
+}
+
+func RegisterCallosumServer(s grpc.ServiceRegistrar, srv CallosumServer) {
+	// If the following call pancis, it indicates UnimplementedCallosumServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Callosum_ServiceDesc, srv)
+}
+
+func _Callosum_Seed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SeedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CallosumServer).Seed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Callosum_Seed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CallosumServer).Seed(ctx, req.(*SeedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Callosum_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CallosumServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Callosum_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CallosumServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Callosum_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CallosumServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Callosum_GetUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CallosumServer).GetUser(ctx, req.(*GetUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Callosum_ListTweets_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListTweetsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CallosumServer).ListTweets(m, &grpc.GenericServerStream[ListTweetsRequest, Tweet]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Callosum_ListTweetsServer = grpc.ServerStreamingServer[Tweet]
+
+func _Callosum_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CallosumServer).Export(m, &grpc.GenericServerStream[ExportRequest, ExportChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Callosum_ExportServer = grpc.ServerStreamingServer[ExportChunk]
+
+// Callosum_ServiceDesc is the grpc.ServiceDesc for Callosum service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Callosum_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "callosum.Callosum",
+	HandlerType: (*CallosumServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Seed",
+			Handler:    _Callosum_Seed_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _Callosum_Status_Handler,
+		},
+		{
+			MethodName: "GetUser",
+			Handler:    _Callosum_GetUser_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListTweets",
+			Handler:       _Callosum_ListTweets_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Export",
+			Handler:       _Callosum_Export_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "callosum.proto",
+}
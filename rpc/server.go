@@ -0,0 +1,177 @@
+//Package rpc serves a gRPC API over a running collector, so that services
+//written in other languages can seed, monitor, query and export a corpus.
+//The service is defined in callosum.proto.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative callosum.proto
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/venkat/callosum"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//defaultListLimit is the number of tweets ListTweets streams when no limit is given
+const defaultListLimit = 200
+
+//Server implements CallosumServer over a TwitterCollector.
+type Server struct {
+	UnimplementedCallosumServer
+	t *callosum.TwitterCollector
+}
+
+//NewServer returns a Server for the collector.
+func NewServer(t *callosum.TwitterCollector) *Server {
+	return &Server{t: t}
+}
+
+//Serve serves the gRPC API of the collector on addr, for example ":50051".
+//It is meant to be run alongside StartCollection:
+//
+//	go rpc.Serve(t, ":50051")
+//	t.StartCollection()
+func Serve(t *callosum.TwitterCollector, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s := grpc.NewServer()
+	RegisterCallosumServer(s, NewServer(t))
+	return s.Serve(l)
+}
+
+//Seed stores the screen names and looks them up in the background, as
+//StartCollection only looks up the seeds present when it starts.
+func (s *Server) Seed(ctx context.Context, req *SeedRequest) (*SeedResponse, error) {
+	if len(req.ScreenNames) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no screen names")
+	}
+	s.t.SeedScreenNames(req.ScreenNames)
+	go s.t.ProcessScreenNames()
+	return &SeedResponse{UnprocessedScreenNames: int64(s.t.Storage().CountUnprocessedScreenNames())}, nil
+}
+
+func unixOrZero(seconds int64, zero bool) int64 {
+	if zero {
+		return 0
+	}
+	return seconds
+}
+
+//Status reports the collector's health, whether the collection is complete
+//and the rows in each table, estimated from the statistics of the query
+//planner so that polling the status doesn't scan every table, see
+//Storage.EstimateTableRows.
+func (s *Server) Status(ctx context.Context, req *StatusRequest) (*StatusResponse, error) {
+	h := s.t.Health()
+	st := s.t.Storage()
+	resp := &StatusResponse{
+		Healthy:                h.Healthy,
+		Complete:               s.t.IsComplete(),
+		TableRows:              make(map[string]int64),
+		UnprocessedScreenNames: int64(st.CountUnprocessedScreenNames()),
+		UnprocessedUserIds:     int64(st.CountUnprocessedUserIDs()),
+		WriterQueued:           int64(h.WriterQueued),
+		LastWriteAt:            unixOrZero(h.LastWriteAt.Unix(), h.LastWriteAt.IsZero()),
+		LastApiCallAt:          unixOrZero(h.LastAPICallAt.Unix(), h.LastAPICallAt.IsZero()),
+		CredentialsValid:       h.CredentialsValid,
+		ApiCalls:               make(map[string]int64),
+	}
+	for _, c := range st.EstimateTableRows() {
+		resp.TableRows[c.Table] = int64(c.Rows)
+	}
+	for endpoint, calls := range s.t.APICalls() {
+		resp.ApiCalls[endpoint] = int64(calls)
+	}
+	return resp, nil
+}
+
+//GetUser gets a stored user by ID or screen name.
+func (s *Server) GetUser(ctx context.Context, req *GetUserRequest) (*User, error) {
+	var u *callosum.UserRow
+	var err error
+	switch x := req.User.(type) {
+	case *GetUserRequest_Id:
+		u, err = s.t.Storage().LookupUser(x.Id)
+	case *GetUserRequest_ScreenName:
+		u, err = s.t.Storage().LookupUser(x.ScreenName)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "no user ID or screen name")
+	}
+	switch {
+	case errors.Is(err, callosum.ErrNotFound):
+		return nil, status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, callosum.ErrStorageClosed):
+		return nil, status.Error(codes.Unavailable, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &User{
+		Id:            u.ID,
+		ScreenName:    u.ScreenName,
+		Description:   u.Description,
+		Protected:     u.Protected != 0,
+		Processed:     u.Processed != 0,
+		Accepted:      u.Accepted != 0,
+		SkipReason:    u.SkipReason,
+		LatestTweetId: u.LatestTweetID,
+		Blob:          u.Blob,
+	}, nil
+}
+
+//ListTweets streams the stored tweets of a user, most recent first.
+func (s *Server) ListTweets(req *ListTweetsRequest, stream grpc.ServerStreamingServer[Tweet]) error {
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	for _, tweet := range s.t.Storage().GetUserTweets(req.UserId, req.MaxId, limit) {
+		err := stream.Send(&Tweet{
+			Id:        tweet.ID,
+			UserId:    req.UserId,
+			CreatedAt: tweet.CreatedAtTime().Unix(),
+			Language:  tweet.Language,
+			Text:      tweet.Text,
+			Blob:      tweet.Blob,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//chunkWriter sends whatever is written to it as ExportChunks
+type chunkWriter struct {
+	stream grpc.ServerStreamingServer[ExportChunk]
+}
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	err := w.stream.Send(&ExportChunk{Data: append([]byte(nil), p...)})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//Export streams an export of the corpus in the requested format.
+func (s *Server) Export(req *ExportRequest, stream grpc.ServerStreamingServer[ExportChunk]) error {
+	w := chunkWriter{stream}
+	st := s.t.Storage()
+	switch req.Format {
+	case ExportRequest_TWARC_V1:
+		return st.ExportTwarc(w, callosum.TwarcV1)
+	case ExportRequest_TWARC_V2:
+		return st.ExportTwarc(w, callosum.TwarcV2)
+	case ExportRequest_TEXT:
+		return st.ExportText(w, callosum.TextOptions{})
+	case ExportRequest_PARTITIONS:
+		return st.ExportPartitions(w)
+	}
+	return status.Errorf(codes.InvalidArgument, "unknown format %s", req.Format)
+}
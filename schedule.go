@@ -0,0 +1,71 @@
+package callosum
+
+import (
+	"log"
+	"time"
+)
+
+//collectedAtColumns are the columns of the `users` table holding when each
+//phase last collected a user
+var collectedAtColumns = map[string]string{
+	PhaseTweets:    "tweets_collected_at",
+	PhaseFriends:   "friends_collected_at",
+	PhaseFollowers: "followers_collected_at",
+}
+
+//eachLeastRecentUser calls eachUser for the accepted users of the tier or
+//above, least recently collected by the phase first, reading them
+//userIDsBatchSize at a time, and records when each user was collected. The
+//order is kept in the database so that the users late in the list get their
+//turn when a round is cut short by a restart instead of the same users
+//being collected first every time. Users collected since the round started
+//wait for the next round.
+func (t *TwitterCollector) eachLeastRecentUser(tier Tier, phase string, collect func(userID int64) int) int {
+	column := collectedAtColumns[phase]
+	started := time.Now().Unix()
+	var total int
+	var afterAt, afterID int64
+	for {
+		userIDs, collectedAts := t.s.getLeastRecentUserIDs(tier, column, started, afterAt, afterID, userIDsBatchSize)
+		if len(userIDs) == 0 {
+			return total
+		}
+		afterAt, afterID = collectedAts[len(collectedAts)-1], userIDs[len(userIDs)-1]
+		total += t.eachUser(userIDs, func(userID int64) int {
+			collected := collect(userID)
+			t.s.MarkUserCollectedAt(userID, column, time.Now().Unix())
+			return collected
+		})
+	}
+}
+
+//getLeastRecentUserIDs gets up to limit of the ids of the accepted users of
+//the tier, or above, collected before the given time, ordered by when they
+//were collected and paged after the user afterID collected at afterAt.
+func (s *Storage) getLeastRecentUserIDs(tier Tier, column string, before, afterAt, afterID int64, limit int) ([]int64, []int64) {
+	rows, err := s.db.Query(`SELECT user_id, `+column+` FROM users
+		WHERE accepted=1 AND tier >= ? AND `+column+` < ? AND (`+column+`, user_id) > (?, ?)
+		ORDER BY `+column+`, user_id LIMIT ?`, tier, before, afterAt, afterID, limit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var userIDs, collectedAts []int64
+	for rows.Next() {
+		var userID, collectedAt int64
+		err = rows.Scan(&userID, &collectedAt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		userIDs = append(userIDs, userID)
+		collectedAts = append(collectedAts, collectedAt)
+	}
+	return userIDs, collectedAts
+}
+
+//MarkUserCollectedAt sets when the user was last collected in the column,
+//one of the `*_collected_at` columns of the `users` table
+func (s *Storage) MarkUserCollectedAt(userID int64, column string, collectedAt int64) {
+	chPriorityArgs <- &queryArgs{"UPDATE users SET " + column + "=? WHERE user_id=?", []interface{}{collectedAt, userID}}
+}
@@ -0,0 +1,595 @@
+package callosum
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//SimulatorConfig describes the random social graph generated by a Simulator.
+type SimulatorConfig struct {
+	//Seed makes the generated graph and timelines deterministic.
+	Seed int64
+	//Users is the number of users in the graph.
+	Users int
+	//MeanFriends is the average number of users a user follows.
+	MeanFriends int
+	//MeanTweets is the average number of tweets on a user's timeline.
+	MeanTweets int
+	//ProtectedRate is the fraction of users with protected accounts.
+	ProtectedRate float64
+	//IDsPageSize is the number of IDs returned per page of friends or followers.
+	IDsPageSize int
+	//RetweetRate is the fraction of tweets that retweet another user's tweet.
+	RetweetRate float64
+	//ReplyRate is the fraction of tweets that reply to another user's tweet.
+	ReplyRate float64
+	//QuoteRate is the fraction of tweets that quote another user's tweet.
+	QuoteRate float64
+	//GeoRate is the fraction of tweets tagged with a place, half of them
+	//also with exact coordinates.
+	GeoRate float64
+}
+
+//Simulator is a deterministic, in-memory stand in for Twitter's API. It
+//implements API over a generated social graph so that the whole collection
+//pipeline can be run end to end without credentials, for example:
+//
+//	sim := callosum.NewSimulator(callosum.SimulatorConfig{Seed: 1, Users: 1000})
+//	t := callosum.NewTwitterCollector(callosum.WithNetwork(sim))
+//	t.SeedScreenNames(sim.ScreenNames()[:5])
+//
+//Users have IDs starting at 1 and screen names of the form "user<ID>".
+type Simulator struct {
+	mutex     sync.Mutex
+	config    SimulatorConfig
+	r         *rand.Rand
+	users     []*simUser
+	lastTweet int64
+	clock     time.Time
+	calls     map[string]int
+	lastCall  time.Time
+}
+
+type simUser struct {
+	id        int64
+	protected bool
+	friends   []int64 //most recent first, like Twitter's API
+	followers []int64 //most recent first
+	tweets    []simTweet
+	edited    []simTweet //earlier versions of edited tweets, off the timeline
+}
+
+type simTweet struct {
+	id        int64
+	createdAt time.Time
+	text      string
+	retweetOf *simTweetRef
+	replyTo   *simTweetRef
+	quoteOf   *simTweetRef
+	place     *simPlace
+	exact     bool
+	edits     []int64 //the IDs of the versions of an edited tweet, oldest first
+}
+
+type simPlace struct {
+	id, name, countryCode string
+	latitude, longitude   float64
+}
+
+var simPlaces = []simPlace{
+	{"5a110d312052166f", "San Francisco", "US", 37.76, -122.44},
+	{"01a9a39529b27f36", "Manhattan", "US", 40.78, -73.97},
+	{"457b4814b4240d87", "London", "GB", 51.50, -0.12},
+	{"1c69a67ad480e1b1", "Chennai", "IN", 13.08, 80.27},
+}
+
+//simTweetRef is another user's tweet that a tweet retweets, replies to or quotes
+type simTweetRef struct {
+	user  *simUser
+	tweet simTweet
+}
+
+var simWords = []string{"etsy", "craft", "coffee", "music", "code", "travel",
+	"photo", "news", "sports", "art", "books", "food", "design", "science"}
+
+//NewSimulator generates the social graph and timelines described by c.
+func NewSimulator(c SimulatorConfig) *Simulator {
+	if c.Users < 1 {
+		c.Users = 100
+	}
+	if c.IDsPageSize < 1 {
+		c.IDsPageSize = 5000
+	}
+	sim := &Simulator{
+		config: c,
+		r:      rand.New(rand.NewSource(c.Seed)),
+		clock:  time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC),
+		calls:  make(map[string]int),
+	}
+	for i := 0; i < c.Users; i++ {
+		sim.users = append(sim.users, &simUser{
+			id:        int64(i + 1),
+			protected: sim.r.Float64() < c.ProtectedRate,
+		})
+	}
+	sim.Grow(c.MeanFriends*c.Users, c.MeanTweets*c.Users)
+	return sim
+}
+
+//Grow adds new follow edges and tweets between random users, simulating
+//activity since the last collection so that restarts and checkpoints can
+//be exercised.
+func (sim *Simulator) Grow(edges, tweets int) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+
+	n := len(sim.users)
+	for i := 0; i < edges && n > 1; i++ {
+		u := sim.users[sim.r.Intn(n)]
+		f := sim.users[sim.r.Intn(n)]
+		if u == f || containsID(u.friends, f.id) {
+			continue
+		}
+		u.friends = append([]int64{f.id}, u.friends...)
+		f.followers = append([]int64{u.id}, f.followers...)
+	}
+	for i := 0; i < tweets; i++ {
+		u := sim.users[sim.r.Intn(n)]
+		sim.lastTweet++
+		sim.clock = sim.clock.Add(time.Duration(sim.r.Intn(3600)) * time.Second)
+		t := simTweet{
+			id:        sim.lastTweet,
+			createdAt: sim.clock,
+			text:      sim.text(),
+		}
+		if sim.config.RetweetRate > 0 && sim.r.Float64() < sim.config.RetweetRate {
+			if original := sim.users[sim.r.Intn(n)]; original != u && len(original.tweets) > 0 {
+				rt := &simTweetRef{original, original.tweets[sim.r.Intn(len(original.tweets))]}
+				t.retweetOf = rt
+				t.text = "RT @" + simScreenName(original.id) + ": " + rt.tweet.text
+			}
+		}
+		if t.retweetOf == nil && sim.config.ReplyRate > 0 && sim.r.Float64() < sim.config.ReplyRate {
+			if original := sim.users[sim.r.Intn(n)]; original != u && len(original.tweets) > 0 {
+				t.replyTo = &simTweetRef{original, original.tweets[sim.r.Intn(len(original.tweets))]}
+				t.text = "@" + simScreenName(original.id) + " " + t.text
+			}
+		}
+		if t.retweetOf == nil && sim.config.QuoteRate > 0 && sim.r.Float64() < sim.config.QuoteRate {
+			if original := sim.users[sim.r.Intn(n)]; original != u && len(original.tweets) > 0 {
+				t.quoteOf = &simTweetRef{original, original.tweets[sim.r.Intn(len(original.tweets))]}
+			}
+		}
+		if sim.config.GeoRate > 0 && sim.r.Float64() < sim.config.GeoRate {
+			t.place = &simPlaces[sim.r.Intn(len(simPlaces))]
+			t.exact = sim.r.Intn(2) == 0
+		}
+		u.tweets = append([]simTweet{t}, u.tweets...)
+	}
+}
+
+//Unfollow removes up to edges random follow edges, simulating users losing
+//followers between collections.
+func (sim *Simulator) Unfollow(edges int) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+
+	for i := 0; i < edges; i++ {
+		u := sim.users[sim.r.Intn(len(sim.users))]
+		if len(u.friends) == 0 {
+			continue
+		}
+		f := sim.users[u.friends[sim.r.Intn(len(u.friends))]-1]
+		u.friends = removeID(u.friends, f.id)
+		f.followers = removeID(f.followers, u.id)
+	}
+}
+
+func removeID(IDs []int64, ID int64) []int64 {
+	kept := IDs[:0]
+	for _, x := range IDs {
+		if x != ID {
+			kept = append(kept, x)
+		}
+	}
+	return kept
+}
+
+func (sim *Simulator) text() string {
+	words := make([]string, 3+sim.r.Intn(8))
+	for i := range words {
+		words[i] = simWords[sim.r.Intn(len(simWords))]
+	}
+	return strings.Join(words, " ")
+}
+
+func containsID(IDs []int64, ID int64) bool {
+	for _, x := range IDs {
+		if x == ID {
+			return true
+		}
+	}
+	return false
+}
+
+//ScreenNames returns the screen names of all the simulated users.
+func (sim *Simulator) ScreenNames() []string {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	screenNames := make([]string, len(sim.users))
+	for i, u := range sim.users {
+		screenNames[i] = simScreenName(u.id)
+	}
+	return screenNames
+}
+
+//Calls returns the number of requests made to each simulated endpoint.
+func (sim *Simulator) Calls() map[string]int {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	calls := make(map[string]int, len(sim.calls))
+	for endpoint, count := range sim.calls {
+		calls[endpoint] = count
+	}
+	return calls
+}
+
+//LastSuccessfulCall returns the time of the last request to the simulator.
+func (sim *Simulator) LastSuccessfulCall() time.Time {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	return sim.lastCall
+}
+
+//VerifyCredentials always succeeds, the simulator needs no credentials.
+func (sim *Simulator) VerifyCredentials() error {
+	return nil
+}
+
+//call counts a request to the endpoint. sim.mutex must be held.
+func (sim *Simulator) call(endpoint string) {
+	sim.calls[endpoint]++
+	sim.lastCall = time.Now()
+}
+
+func simScreenName(ID int64) string {
+	return "user" + strconv.FormatInt(ID, 10)
+}
+
+func (sim *Simulator) lookup(screenNameOrID interface{}) *simUser {
+	var ID int64
+	switch x := screenNameOrID.(type) {
+	case string:
+		if !strings.HasPrefix(x, "user") {
+			return nil
+		}
+		var err error
+		ID, err = strconv.ParseInt(strings.TrimPrefix(x, "user"), 10, 64)
+		if err != nil {
+			return nil
+		}
+	case int64:
+		ID = x
+	default:
+		log.Fatal("screenNameOrID needs to a string or int64")
+	}
+	if ID < 1 || ID > int64(len(sim.users)) {
+		return nil
+	}
+	return sim.users[ID-1]
+}
+
+func (sim *Simulator) tweetBlob(u *simUser, t simTweet) *Tweet {
+	tweet := &Tweet{
+		ID:        t.id,
+		Text:      t.text,
+		CreatedAt: t.createdAt.Format(time.RubyDate),
+		Language:  "en",
+	}
+	blob := map[string]interface{}{
+		"id":         tweet.ID,
+		"id_str":     strconv.FormatInt(tweet.ID, 10),
+		"text":       tweet.Text,
+		"created_at": tweet.CreatedAt,
+		"lang":       tweet.Language,
+		"user":       map[string]interface{}{"id": u.id, "id_str": strconv.FormatInt(u.id, 10)},
+	}
+	if t.retweetOf != nil {
+		blob["retweeted_status"] = json.RawMessage(sim.tweetBlob(t.retweetOf.user, t.retweetOf.tweet).Blob)
+		blob["entities"] = simMentions(t.retweetOf.user.id)
+	}
+	if t.replyTo != nil {
+		blob["in_reply_to_status_id"] = t.replyTo.tweet.id
+		blob["in_reply_to_user_id"] = t.replyTo.user.id
+		blob["in_reply_to_screen_name"] = simScreenName(t.replyTo.user.id)
+		blob["entities"] = simMentions(t.replyTo.user.id)
+	}
+	if t.quoteOf != nil {
+		blob["is_quote_status"] = true
+		blob["quoted_status_id"] = t.quoteOf.tweet.id
+		blob["quoted_status"] = json.RawMessage(sim.tweetBlob(t.quoteOf.user, t.quoteOf.tweet).Blob)
+	}
+	if len(t.edits) > 0 {
+		var edits []string
+		for _, ID := range t.edits {
+			edits = append(edits, strconv.FormatInt(ID, 10))
+		}
+		blob["edit_history"] = map[string]interface{}{"initial_tweet_id": edits[0], "edit_tweet_ids": edits}
+	}
+	if p := t.place; p != nil {
+		blob["place"] = map[string]interface{}{
+			"id":           p.id,
+			"name":         p.name,
+			"full_name":    p.name,
+			"place_type":   "city",
+			"country_code": p.countryCode,
+			"bounding_box": map[string]interface{}{"type": "Polygon", "coordinates": [][][]float64{{
+				{p.longitude - 0.1, p.latitude - 0.1}, {p.longitude + 0.1, p.latitude - 0.1},
+				{p.longitude + 0.1, p.latitude + 0.1}, {p.longitude - 0.1, p.latitude + 0.1},
+			}}},
+		}
+		if t.exact {
+			blob["coordinates"] = map[string]interface{}{"type": "Point", "coordinates": []float64{p.longitude + 0.01, p.latitude - 0.01}}
+		}
+	}
+	tweet.Blob = mustMarshal(blob)
+	return tweet
+}
+
+func simMentions(ID int64) map[string]interface{} {
+	return map[string]interface{}{"user_mentions": []interface{}{
+		map[string]interface{}{"id": ID, "id_str": strconv.FormatInt(ID, 10), "screen_name": simScreenName(ID)},
+	}}
+}
+
+func (sim *Simulator) userBlob(u *simUser) *User {
+	user := &User{
+		ID:          u.id,
+		Name:        simScreenName(u.id),
+		ScreenName:  simScreenName(u.id),
+		Description: fmt.Sprintf("simulated user %d", u.id),
+		Protected:   u.protected,
+	}
+	blob := map[string]interface{}{
+		"id":              user.ID,
+		"id_str":          strconv.FormatInt(user.ID, 10),
+		"name":            user.Name,
+		"screen_name":     user.ScreenName,
+		"description":     user.Description,
+		"protected":       user.Protected,
+		"friends_count":   len(u.friends),
+		"followers_count": len(u.followers),
+		"statuses_count":  len(u.tweets),
+		"lang":            "en",
+	}
+	//every third user pins their oldest tweet
+	if len(u.tweets) > 0 && u.id%3 == 0 {
+		blob["pinned_tweet_ids_str"] = []string{strconv.FormatInt(u.tweets[len(u.tweets)-1].id, 10)}
+	}
+	if len(u.tweets) > 0 && !u.protected {
+		user.LatestTweet = *sim.tweetBlob(u, u.tweets[0])
+		blob["status"] = json.RawMessage(user.LatestTweet.Blob)
+	}
+	user.Blob = mustMarshal(blob)
+	return user
+}
+
+func mustMarshal(v interface{}) []byte {
+	blob, err := json.Marshal(v)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return blob
+}
+
+//GetUserTimeline returns up to 200 of the user's tweets older than maxID,
+//most recent first. Like Twitter's API, the timelines of protected users
+//fail with ErrProtected.
+func (sim *Simulator) GetUserTimeline(screenNameOrID interface{}, maxID int64) (Tweets, error) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	sim.call("statuses/user_timeline")
+
+	var tweets Tweets
+	u, err := sim.lookupAuthorized(screenNameOrID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range u.tweets {
+		if maxID != 0 && t.id >= maxID {
+			continue
+		}
+		tweets = append(tweets, sim.tweetBlob(u, t))
+		if len(tweets) == 200 {
+			break
+		}
+	}
+	return tweets, nil
+}
+
+//GetUserTimelineSince returns up to 200 of the user's tweets newer than
+//sinceID, most recent first, see TailTweets.
+func (sim *Simulator) GetUserTimelineSince(screenNameOrID interface{}, sinceID int64) (Tweets, error) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	sim.call("statuses/user_timeline")
+
+	var tweets Tweets
+	u, err := sim.lookupAuthorized(screenNameOrID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range u.tweets {
+		if t.id <= sinceID || len(tweets) == 200 {
+			break
+		}
+		tweets = append(tweets, sim.tweetBlob(u, t))
+	}
+	return tweets, nil
+}
+
+//GetUser returns the simulated user.
+func (sim *Simulator) GetUser(screenNameOrID interface{}) (*User, error) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	sim.call("users/show")
+
+	u := sim.lookup(screenNameOrID)
+	if u == nil {
+		return nil, fmt.Errorf("simulated user %v: %w", screenNameOrID, ErrNotFound)
+	}
+	return sim.userBlob(u), nil
+}
+
+//GetUsers returns the simulated users with the given IDs. Like Twitter's
+//API, unknown IDs are left out of the result.
+func (sim *Simulator) GetUsers(IDs []int64) ([]*User, error) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	sim.call("users/lookup")
+
+	var users []*User
+	sorted := append([]int64(nil), IDs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for _, ID := range sorted {
+		if u := sim.lookup(ID); u != nil {
+			users = append(users, sim.userBlob(u))
+		}
+	}
+	return users, nil
+}
+
+func (sim *Simulator) page(IDs []int64, cursorID int64) ([]int64, int64, error) {
+	if cursorID == 0 {
+		return []int64{}, 0, nil
+	}
+	start := int(cursorID)
+	if cursorID == -1 {
+		start = 0
+	}
+	if start >= len(IDs) {
+		return []int64{}, 0, nil
+	}
+	end := start + sim.config.IDsPageSize
+	if end >= len(IDs) {
+		return append([]int64(nil), IDs[start:]...), 0, nil
+	}
+	return append([]int64(nil), IDs[start:end]...), int64(end), nil
+}
+
+//lookupAuthorized looks up a user whose tweets, friends and followers can
+//be read, failing like Twitter's API for unknown and protected users.
+func (sim *Simulator) lookupAuthorized(screenNameOrID interface{}) (*simUser, error) {
+	u := sim.lookup(screenNameOrID)
+	switch {
+	case u == nil:
+		return nil, fmt.Errorf("simulated user %v: %w", screenNameOrID, ErrNotFound)
+	case u.protected:
+		return nil, fmt.Errorf("simulated user %v: %w", screenNameOrID, ErrProtected)
+	}
+	return u, nil
+}
+
+//GetFriendIDs returns a page of the IDs the user follows. Pass -1 as the
+//cursorID to start from the first page.
+func (sim *Simulator) GetFriendIDs(screenNameOrID interface{}, cursorID int64) ([]int64, int64, error) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	sim.call("friends/ids")
+
+	u, err := sim.lookupAuthorized(screenNameOrID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return sim.page(u.friends, cursorID)
+}
+
+//DeleteTweets removes the tweets with the given IDs from the timelines, as
+//if their authors had deleted them.
+func (sim *Simulator) DeleteTweets(IDs ...int64) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	for _, u := range sim.users {
+		tweets := u.tweets[:0]
+		for _, t := range u.tweets {
+			if !containsID(IDs, t.id) {
+				tweets = append(tweets, t)
+			}
+		}
+		u.tweets = tweets
+	}
+}
+
+//EditTweet replaces the tweet on its author's timeline with an edited
+//version, which gets a new ID and is linked to the earlier versions by its
+//edit history, as Twitter does, and returns the ID of the new version. The
+//earlier versions can still be looked up by ID.
+func (sim *Simulator) EditTweet(ID int64) int64 {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	for _, u := range sim.users {
+		for i, t := range u.tweets {
+			if t.id != ID {
+				continue
+			}
+			sim.lastTweet++
+			sim.clock = sim.clock.Add(time.Minute)
+			edited := t
+			edited.id = sim.lastTweet
+			edited.createdAt = sim.clock
+			edited.text = t.text + " (edited)"
+			if len(t.edits) == 0 {
+				t.edits = []int64{t.id}
+			}
+			edited.edits = append(append([]int64(nil), t.edits...), edited.id)
+			u.edited = append(u.edited, t)
+			u.tweets = append([]simTweet{edited}, append(u.tweets[:i:i], u.tweets[i+1:]...)...)
+			return edited.id
+		}
+	}
+	log.Fatalf("simulated tweet %d not found", ID)
+	return 0
+}
+
+//LookupTweets returns the simulated tweets with the given IDs, the earlier
+//versions of edited tweets included. Deleted tweets and tweets of protected
+//users are left out.
+func (sim *Simulator) LookupTweets(IDs []int64) (map[int64]*Tweet, error) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	sim.call("statuses/lookup")
+
+	tweets := make(map[int64]*Tweet)
+	for _, u := range sim.users {
+		if u.protected {
+			continue
+		}
+		for _, t := range append(append([]simTweet(nil), u.tweets...), u.edited...) {
+			if containsID(IDs, t.id) {
+				tweets[t.id] = sim.tweetBlob(u, t)
+			}
+		}
+	}
+	return tweets, nil
+}
+
+//GetFollowerIDs returns a page of the IDs following the user. Pass -1 as
+//the cursorID to start from the first page.
+func (sim *Simulator) GetFollowerIDs(screenNameOrID interface{}, cursorID int64) ([]int64, int64, error) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	sim.call("followers/ids")
+
+	u, err := sim.lookupAuthorized(screenNameOrID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return sim.page(u.followers, cursorID)
+}
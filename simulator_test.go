@@ -0,0 +1,767 @@
+package callosum
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//testIntervals keep the rounds of the phases short, so that a collection
+//over a small simulated graph completes in seconds
+var testIntervals = Intervals{
+	Users:     10 * time.Millisecond,
+	Tweets:    10 * time.Millisecond,
+	Friends:   10 * time.Millisecond,
+	Followers: 10 * time.Millisecond,
+}
+
+func newTestSimulator() *Simulator {
+	return NewSimulator(SimulatorConfig{
+		Seed:          7,
+		Users:         30,
+		MeanFriends:   4,
+		MeanTweets:    20,
+		ProtectedRate: 0.1,
+		IDsPageSize:   2,
+	})
+}
+
+//simTimeline pages through the simulated user's timeline with max IDs, as the
+//collector does, and returns the IDs of the tweets.
+func simTimeline(sim *Simulator, ID int64) ([]int64, error) {
+	var IDs []int64
+	var maxID int64
+	for {
+		tweets, err := sim.GetUserTimeline(ID, maxID)
+		if err != nil || len(tweets) == 0 {
+			return IDs, err
+		}
+		for _, tweet := range tweets {
+			IDs = append(IDs, tweet.ID)
+		}
+		maxID = tweets[len(tweets)-1].ID
+	}
+}
+
+//simIDs pages through the friend or follower IDs of the simulated user with
+//cursors, as the collector does.
+func simIDs(getIDs func(interface{}, int64) ([]int64, int64, error), ID int64) ([]int64, error) {
+	var IDs []int64
+	for cursorID := int64(-1); cursorID != 0; {
+		page, next, err := getIDs(ID, cursorID)
+		if err != nil {
+			return IDs, err
+		}
+		IDs = append(IDs, page...)
+		cursorID = next
+	}
+	return IDs, nil
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSimulatorIsDeterministic(t *testing.T) {
+	a, b := newTestSimulator(), newTestSimulator()
+	for ID := int64(1); ID <= int64(len(a.users)); ID++ {
+		timelineA, errA := simTimeline(a, ID)
+		timelineB, errB := simTimeline(b, ID)
+		if !equalIDs(timelineA, timelineB) || (errA == nil) != (errB == nil) {
+			t.Errorf("simulators with the same seed generated different timelines for user %d", ID)
+		}
+		friendsA, _ := simIDs(a.GetFriendIDs, ID)
+		friendsB, _ := simIDs(b.GetFriendIDs, ID)
+		if !equalIDs(friendsA, friendsB) {
+			t.Errorf("simulators with the same seed generated different friends for user %d", ID)
+		}
+	}
+}
+
+func TestSimulatorPaging(t *testing.T) {
+	sim := newTestSimulator()
+	for _, u := range sim.users {
+		timeline, err := simTimeline(sim, u.id)
+		if u.protected {
+			if !errors.Is(err, ErrProtected) {
+				t.Errorf("the timeline of protected user %d was served, got error %v", u.id, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []int64
+		for _, tweet := range u.tweets {
+			want = append(want, tweet.id)
+		}
+		if !equalIDs(timeline, want) {
+			t.Errorf("paged through timeline %v of user %d, want %v", timeline, u.id, want)
+		}
+		for i := 1; i < len(timeline); i++ {
+			if timeline[i] >= timeline[i-1] {
+				t.Errorf("the timeline of user %d is not most recent first", u.id)
+				break
+			}
+		}
+
+		if friends, _ := simIDs(sim.GetFriendIDs, u.id); !equalIDs(friends, u.friends) {
+			t.Errorf("paged through friends %v of user %d, want %v", friends, u.id, u.friends)
+		}
+		if followers, _ := simIDs(sim.GetFollowerIDs, u.id); !equalIDs(followers, u.followers) {
+			t.Errorf("paged through followers %v of user %d, want %v", followers, u.id, u.followers)
+		}
+	}
+}
+
+func newTestCollector(n API, s *Storage) *TwitterCollector {
+	return NewTwitterCollector(WithNetwork(n), WithStorage(s), WithIntervals(testIntervals),
+		WithLogger(log.New(ioutil.Discard, "", 0)))
+}
+
+//mostFollowedUser returns the public simulated user with the most followers,
+//whose followers take several pages
+func mostFollowedUser(sim *Simulator) *simUser {
+	var seed *simUser
+	for _, u := range sim.users {
+		if !u.protected && (seed == nil || len(u.followers) > len(seed.followers)) {
+			seed = u
+		}
+	}
+	return seed
+}
+
+//checkCollection checks that the users reachable from the seed through
+//follow edges are stored, with the tweets, friends and followers of the
+//public ones.
+func checkCollection(t *testing.T, sim *Simulator, s *Storage, seed *simUser) {
+	reached := map[int64]bool{seed.id: true}
+	queue := []*simUser{seed}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		if u.protected {
+			continue
+		}
+		for _, ID := range append(append([]int64{}, u.friends...), u.followers...) {
+			if !reached[ID] {
+				reached[ID] = true
+				queue = append(queue, sim.users[ID-1])
+			}
+		}
+	}
+
+	if users := s.CountUsers(); users != len(reached) {
+		t.Errorf("stored %d users, want the %d reachable from the seed", users, len(reached))
+	}
+	for ID := range reached {
+		u := sim.users[ID-1]
+		if s.GetUserByScreenNameOrID(ID) == nil {
+			t.Errorf("user %d is not stored", ID)
+			continue
+		}
+		if u.protected {
+			continue
+		}
+		if tweets := s.count("SELECT COUNT(*) FROM tweets WHERE user_id=?", ID); tweets != len(u.tweets) {
+			t.Errorf("stored %d tweets of user %d, want %d", tweets, ID, len(u.tweets))
+		}
+		if friends := s.count("SELECT COUNT(*) FROM following WHERE user_id=?", ID); friends != len(u.friends) {
+			t.Errorf("stored %d friends of user %d, want %d", friends, ID, len(u.friends))
+		}
+		if followers := s.count("SELECT COUNT(*) FROM followers WHERE user_id=?", ID); followers != len(u.followers) {
+			t.Errorf("stored %d followers of user %d, want %d", followers, ID, len(u.followers))
+		}
+	}
+}
+
+func TestCollectionOverSimulator(t *testing.T) {
+	sim := newTestSimulator()
+	seed := mostFollowedUser(sim)
+	s := NewStorage(filepath.Join(t.TempDir(), "corpus"))
+	defer s.Close()
+
+	c := newTestCollector(sim, s)
+	c.SeedScreenNames([]string{simScreenName(seed.id)})
+	c.StartCollection()
+	s.flush()
+
+	checkCollection(t, sim, s, seed)
+
+	//collections skipped for protected users count as attempts, by phase
+	for _, u := range sim.users {
+		if !u.protected || s.GetUserByScreenNameOrID(u.id) == nil {
+			continue
+		}
+		c.CollectTweets(u.id, 0)
+		c.CollectFriends(u.id, 0)
+		s.flush()
+		stats, err := s.GetCollectionStats(u.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, phase := range []string{PhaseTweets, PhaseFriends} {
+			if a := stats.Phases[phase]; a.Attempts != 1 || a.LastError == "" {
+				t.Errorf("%s of protected user %d attempted %d times, last with error %q", phase, u.id, a.Attempts, a.LastError)
+			}
+		}
+		if !strings.HasPrefix(stats.LastError, PhaseFriends+": ") {
+			t.Errorf("the last error of protected user %d is %q, want the friends phase's", u.id, stats.LastError)
+		}
+		break
+	}
+
+	recorded := make(map[string]int)
+	for _, u := range s.GetAPIUsage("") {
+		recorded[u.Endpoint] += u.Calls
+		if u.Endpoint == "statuses/user_timeline" && u.Phase != PhaseTweets {
+			t.Errorf("%d timeline requests recorded for phase %q", u.Calls, u.Phase)
+		}
+	}
+	for endpoint, calls := range sim.Calls() {
+		if recorded[endpoint] != calls {
+			t.Errorf("recorded %d requests to %s, the simulator got %d", recorded[endpoint], endpoint, calls)
+		}
+	}
+}
+
+//interruptedSimulator refuses a followers/ids request as rate limited, to
+//stop the paging of a user's followers part way, and records the cursors
+//requested.
+type interruptedSimulator struct {
+	*Simulator
+	mutex     sync.Mutex
+	refuseAt  int //the followers/ids request refused, counting from 1, none when 0
+	requested int
+	cursors   map[int64][]int64
+}
+
+func (sim *interruptedSimulator) GetFollowerIDs(screenNameOrID interface{}, cursorID int64) ([]int64, int64, error) {
+	sim.mutex.Lock()
+	sim.requested++
+	refused := sim.requested == sim.refuseAt
+	if ID, ok := screenNameOrID.(int64); ok && !refused {
+		sim.cursors[ID] = append(sim.cursors[ID], cursorID)
+	}
+	sim.mutex.Unlock()
+	if refused {
+		return nil, 0, &APIError{Endpoint: "followers/ids", Code: 88, Err: errors.New("rate limit exceeded")}
+	}
+	return sim.Simulator.GetFollowerIDs(screenNameOrID, cursorID)
+}
+
+func TestCollectionResumesAfterRestart(t *testing.T) {
+	sim := newTestSimulator()
+	seed := mostFollowedUser(sim)
+	if len(seed.followers) <= 2*sim.config.IDsPageSize {
+		t.Fatalf("the seed has %d followers, not enough pages to interrupt", len(seed.followers))
+	}
+	dbName := filepath.Join(t.TempDir(), "corpus")
+
+	//the first run stops while paging through the seed's followers
+	first := &interruptedSimulator{Simulator: sim, refuseAt: 2, cursors: make(map[int64][]int64)}
+	s := NewStorage(dbName)
+	c := newTestCollector(first, s)
+	c.SeedScreenNames([]string{simScreenName(seed.id)})
+	c.ProcessScreenNames()
+	s.flush()
+	c.CollectAllTweets()
+	c.CollectAllFollowers()
+	s.flush()
+	cursorID, _ := s.GetPagingCursor(seed.id, PhaseFollowers)
+	if cursorID == 0 {
+		t.Fatal("no paging cursor was kept for the interrupted followers")
+	}
+	tweets := s.count("SELECT COUNT(*) FROM tweets WHERE user_id=?", seed.id)
+	latestTweetID := s.GetUserByScreenNameOrID(seed.id).LatestTweetID
+	if tweets != len(seed.tweets) || latestTweetID == 0 {
+		t.Fatalf("the first run stored %d tweets of the seed up to %d, want %d", tweets, latestTweetID, len(seed.tweets))
+	}
+	err := s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the second run picks up from the checkpoints of the first
+	second := &interruptedSimulator{Simulator: sim, cursors: make(map[int64][]int64)}
+	s = NewStorage(dbName)
+	defer s.Close()
+	c = newTestCollector(second, s)
+	c.StartCollection()
+	s.flush()
+
+	if cursors := second.cursors[seed.id]; len(cursors) == 0 || cursors[0] != cursorID {
+		t.Errorf("the seed's followers were requested from cursors %v, want to resume at %d", cursors, cursorID)
+	}
+	if cursorID, _ := s.GetPagingCursor(seed.id, PhaseFollowers); cursorID != 0 {
+		t.Errorf("the paging cursor %d was kept after the last page", cursorID)
+	}
+	if got := s.GetUserByScreenNameOrID(seed.id).LatestTweetID; got != latestTweetID {
+		t.Errorf("the seed's latest tweet moved from %d to %d without new tweets", latestTweetID, got)
+	}
+	checkCollection(t, sim, s, seed)
+}
+
+func TestAnonymizedImportLeavesNoIdentifiers(t *testing.T) {
+	//a reply quoting another tweet, edited once, as twarc2 writes it
+	page := `{"data": [{"id": "1580000000000000003", "text": "@alice_w see https://t.co/abc", "created_at": "2022-10-12T10:00:00.000Z",
+		"lang": "en", "author_id": "1111111111", "in_reply_to_user_id": "2222222222",
+		"referenced_tweets": [{"type": "replied_to", "id": "1570000000000000001"}, {"type": "quoted", "id": "1560000000000000002"}],
+		"entities": {"mentions": [{"id": "2222222222", "username": "alice_w"}]},
+		"edit_history_tweet_ids": ["1579999999999999999", "1580000000000000003"]}],
+		"includes": {"users": [{"id": "1111111111", "username": "bob_k", "name": "Bob Kowalski"}, {"id": "2222222222", "username": "alice_w", "name": "Alice Wong"}],
+		"tweets": [{"id": "1560000000000000002", "text": "ask @bob_k", "author_id": "2222222222",
+		"entities": {"mentions": [{"id": "1111111111", "username": "bob_k"}]}}]}}`
+	blobs := v2Lines([]byte(page))
+	//a v1.1 quote tweet with its permalink
+	blobs = append(blobs, []byte(`{"id": 1590000000000000004, "id_str": "1590000000000000004", "text": "this",
+		"user": {"id": 1111111111, "id_str": "1111111111", "screen_name": "bob_k", "name": "Bob Kowalski"},
+		"is_quote_status": true, "quoted_status_id": 1560000000000000002, "quoted_status_id_str": "1560000000000000002",
+		"quoted_status_permalink": {"url": "https://t.co/xyz", "expanded": "https://twitter.com/alice_w/status/1560000000000000002",
+		"display": "twitter.com/alice_w/status/1…"}, "source": "<a href=\"https://bobk.example\">bob_k's app</a>"}`))
+
+	identifiers := []string{"1580000000000000003", "1579999999999999999", "1570000000000000001", "1560000000000000002",
+		"1590000000000000004", "1111111111", "2222222222", "bob_k", "alice_w", "Bob Kowalski", "Alice Wong"}
+	a := NewAnonymizer([]byte("salt"))
+	for _, blob := range blobs {
+		anonymized, err := a.Tweet(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, identifier := range identifiers {
+			if strings.Contains(string(anonymized), identifier) {
+				t.Errorf("%s is left in the anonymized tweet %s", identifier, anonymized)
+			}
+		}
+	}
+}
+
+//simConversation returns the conversation of the simulated tweet as far as
+//its reply chain is stored: the first stored tweet of the chain, or the
+//tweet replied to by it when that one is not stored.
+func simConversation(tweet simTweet, stored map[int64]bool) int64 {
+	for tweet.replyTo != nil {
+		if !stored[tweet.replyTo.tweet.id] {
+			return tweet.replyTo.tweet.id
+		}
+		tweet = tweet.replyTo.tweet
+	}
+	return tweet.id
+}
+
+func TestConversationsResolvedWhileCollecting(t *testing.T) {
+	sim := NewSimulator(SimulatorConfig{Seed: 11, Users: 40, MeanFriends: 4, MeanTweets: 30, ReplyRate: 0.6})
+	s := NewStorage(filepath.Join(t.TempDir(), "corpus"))
+	defer s.Close()
+	c := newTestCollector(sim, s)
+	c.SeedScreenNames(sim.ScreenNames())
+	c.ProcessScreenNames()
+	s.flush()
+
+	//the users are collected from the last, so that replies are often stored
+	//before the tweets they reply to, while the conversations are resolved
+	done := make(chan struct{})
+	go func() {
+		for i := len(sim.users) - 1; i >= 0; i-- {
+			c.CollectTweets(sim.users[i].id, 0)
+		}
+		close(done)
+	}()
+	for collecting := true; collecting; {
+		select {
+		case <-done:
+			collecting = false
+		default:
+			s.ResolveConversations()
+		}
+	}
+	s.ResolveConversations()
+	s.flush()
+
+	stored := make(map[int64]bool)
+	for _, u := range sim.users {
+		for _, tweet := range u.tweets {
+			stored[tweet.id] = !u.protected
+		}
+	}
+	replies := 0
+	for _, u := range sim.users {
+		if u.protected {
+			continue
+		}
+		for _, tweet := range u.tweets {
+			if tweet.replyTo != nil {
+				replies++
+			}
+			want := simConversation(tweet, stored)
+			if got := s.count("SELECT conversation_id FROM tweets WHERE tweet_id=?", tweet.id); int64(got) != want {
+				t.Errorf("tweet %d is in conversation %d, want %d", tweet.id, got, want)
+			}
+		}
+	}
+	if replies == 0 {
+		t.Fatal("the simulated tweets have no replies")
+	}
+	if unresolved := s.count("SELECT COUNT(*) FROM replies WHERE resolved=0"); unresolved != 0 {
+		t.Errorf("%d replies are left unresolved", unresolved)
+	}
+}
+
+//countingExporter counts the times each tweet is exported
+type countingExporter struct {
+	mutex  sync.Mutex
+	tweets map[int64]int
+}
+
+func (e *countingExporter) ExportUser(blob []byte) {}
+
+func (e *countingExporter) ExportTweet(blob []byte) {
+	var tweet Tweet
+	json.Unmarshal(blob, &tweet)
+	e.mutex.Lock()
+	e.tweets[tweet.ID]++
+	e.mutex.Unlock()
+}
+
+func (e *countingExporter) Close() {}
+
+func TestEditVersionsKeptApart(t *testing.T) {
+	sim := NewSimulator(SimulatorConfig{Seed: 3, Users: 20, MeanFriends: 3, MeanTweets: 10, ReplyRate: 0.5})
+	var u *simUser
+	var reply simTweet
+	for _, candidate := range sim.users {
+		for _, tweet := range candidate.tweets {
+			if u == nil && !candidate.protected && tweet.replyTo != nil {
+				u, reply = candidate, tweet
+			}
+		}
+	}
+	if u == nil {
+		t.Fatal("the simulated tweets have no replies")
+	}
+	editedID := sim.EditTweet(reply.id)
+	latestID := sim.EditTweet(editedID)
+
+	s := NewStorage(filepath.Join(t.TempDir(), "corpus"))
+	defer s.Close()
+	e := &countingExporter{tweets: make(map[int64]int)}
+	c := NewTwitterCollector(WithNetwork(sim), WithStorage(s), WithIntervals(testIntervals),
+		WithLogger(log.New(ioutil.Discard, "", 0)), WithExporter(e))
+	c.SeedScreenNames([]string{simScreenName(u.id)})
+	c.ProcessScreenNames()
+	s.flush()
+	c.CollectTweets(u.id, 0)
+	s.flush()
+	if stored := c.CollectEditHistory(); stored != 2 {
+		t.Errorf("collected %d earlier versions, want 2", stored)
+	}
+	s.flush()
+	if stored := c.CollectEditHistory(); stored != 0 {
+		t.Errorf("collected %d earlier versions again", stored)
+	}
+	s.flush()
+
+	var history []int64
+	for _, tweet := range s.GetEditHistory(latestID) {
+		history = append(history, tweet.ID)
+	}
+	if want := []int64{reply.id, editedID, latestID}; !equalIDs(history, want) {
+		t.Errorf("the edit history is %v, want %v", history, want)
+	}
+	if tweets := s.count("SELECT COUNT(*) FROM tweets WHERE user_id=?", u.id); tweets != len(u.tweets) {
+		t.Errorf("stored %d tweets of the user, want the %d on the timeline", tweets, len(u.tweets))
+	}
+	replies := 0
+	for _, tweet := range u.tweets {
+		if tweet.replyTo != nil {
+			replies++
+		}
+	}
+	if stored := s.count("SELECT COUNT(*) FROM replies WHERE from_user_id=?", u.id); stored != replies {
+		t.Errorf("stored %d replies of the user, want %d", stored, replies)
+	}
+	if e.tweets[reply.id] != 0 || e.tweets[editedID] != 0 {
+		t.Error("earlier versions of an edited tweet were exported")
+	}
+}
+
+func TestStoredTweetsExportedOnce(t *testing.T) {
+	sim := NewSimulator(SimulatorConfig{Seed: 4, Users: 10, MeanFriends: 3, MeanTweets: 20})
+	u := mostFollowedUser(sim)
+	s := NewStorage(filepath.Join(t.TempDir(), "corpus"))
+	defer s.Close()
+	e := &countingExporter{tweets: make(map[int64]int)}
+	c := NewTwitterCollector(WithNetwork(sim), WithStorage(s), WithIntervals(testIntervals),
+		WithLogger(log.New(ioutil.Discard, "", 0)), WithExporter(e))
+	c.SeedScreenNames([]string{simScreenName(u.id)})
+	c.ProcessScreenNames()
+	s.flush()
+	//the whole timeline is read twice, the tweets are stored again the second time
+	c.CollectTweets(u.id, 0)
+	c.CollectTweets(u.id, 0)
+	c.CloseExporter()
+
+	if len(e.tweets) != len(u.tweets) {
+		t.Errorf("exported %d tweets, want the %d on the timeline", len(e.tweets), len(u.tweets))
+	}
+	for id, exported := range e.tweets {
+		if exported != 1 {
+			t.Errorf("tweet %d exported %d times", id, exported)
+		}
+	}
+}
+
+func TestSQLDumpKeepsOnlyAcceptedUsers(t *testing.T) {
+	sim := NewSimulator(SimulatorConfig{Seed: 5, Users: 20, MeanFriends: 4, MeanTweets: 15,
+		RetweetRate: 0.3, ReplyRate: 0.3, QuoteRate: 0.3})
+	s := NewStorage(filepath.Join(t.TempDir(), "corpus"))
+	defer s.Close()
+	//the users with an even ID are accepted, the tweets of every user are stored
+	c := NewTwitterCollector(WithNetwork(sim), WithStorage(s), WithIntervals(testIntervals),
+		WithLogger(log.New(ioutil.Discard, "", 0)), WithFilter(func(blob []byte) bool {
+			var u User
+			json.Unmarshal(blob, &u)
+			return u.ID%2 == 0
+		}))
+	c.SeedScreenNames(sim.ScreenNames())
+	c.ProcessScreenNames()
+	s.flush()
+	for _, u := range sim.users {
+		c.CollectTweets(u.id, 0)
+	}
+	s.flush()
+
+	var dump bytes.Buffer
+	if err := s.ExportSQLDump(&dump, SQLDumpOptions{AcceptedOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "dump"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err = db.Exec(dump.String()); err != nil {
+		t.Fatal(err)
+	}
+	count := func(query string) int {
+		var n int
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	if users := count("SELECT COUNT(*) FROM users"); users != s.count("SELECT COUNT(*) FROM users WHERE accepted=1") {
+		t.Errorf("dumped %d users, want only the accepted ones", users)
+	}
+	acceptedTweets := s.count("SELECT COUNT(*) FROM tweets WHERE user_id IN (SELECT user_id FROM users WHERE accepted=1)")
+	if tweets := count("SELECT COUNT(*) FROM tweets"); tweets != acceptedTweets || tweets == s.count("SELECT COUNT(*) FROM tweets") {
+		t.Errorf("dumped %d tweets, want the %d of the accepted users", tweets, acceptedTweets)
+	}
+	for table, columns := range map[string][]string{
+		"tweets":      {"user_id"},
+		"replies":     {"from_user_id", "to_user_id"},
+		"mentions":    {"from_user_id", "to_user_id"},
+		"quote_edges": {"quoting_user_id", "quoted_user_id"},
+		"retweets":    {"retweeter_id", "original_author_id"},
+	} {
+		if s.count("SELECT COUNT(*) FROM "+table) == 0 {
+			t.Errorf("no %s were collected", table)
+		}
+		for _, column := range columns {
+			if rejected := count("SELECT COUNT(*) FROM " + table + " WHERE " + column + " % 2 = 1"); rejected != 0 {
+				t.Errorf("dumped %d %s with a rejected %s", rejected, table, column)
+			}
+		}
+	}
+}
+
+func TestTailStoresOnlyNewTweets(t *testing.T) {
+	sim := newTestSimulator()
+	s := NewStorage(filepath.Join(t.TempDir(), "corpus"))
+	defer s.Close()
+	e := &countingExporter{tweets: make(map[int64]int)}
+	c := NewTwitterCollector(WithNetwork(sim), WithStorage(s), WithIntervals(testIntervals),
+		WithLogger(log.New(ioutil.Discard, "", 0)), WithExporter(e))
+	c.SeedScreenNames(sim.ScreenNames())
+	c.ProcessScreenNames()
+	s.flush()
+	for _, u := range sim.users {
+		c.CollectTweets(u.id, 0)
+	}
+	s.flush()
+
+	e.tweets = make(map[int64]int)
+	lastTweet := sim.lastTweet
+	sim.Grow(0, 40)
+	//users who posted nothing in the last week are due after tailMaxBackoff intervals
+	interval := time.Millisecond
+	time.Sleep(2 * tailMaxBackoff * interval)
+	collected := c.TailTweets(interval)
+	s.flush()
+
+	newTweets, public := 0, 0
+	for _, u := range sim.users {
+		if u.protected {
+			continue
+		}
+		public++
+		for _, tweet := range u.tweets {
+			if tweet.id > lastTweet {
+				newTweets++
+				if e.tweets[tweet.id] != 1 {
+					t.Errorf("new tweet %d was stored %d times", tweet.id, e.tweets[tweet.id])
+				}
+			}
+		}
+		if tweets := s.count("SELECT COUNT(*) FROM tweets WHERE user_id=?", u.id); tweets != len(u.tweets) {
+			t.Errorf("stored %d tweets of user %d, want %d", tweets, u.id, len(u.tweets))
+		}
+	}
+	if newTweets == 0 {
+		t.Fatal("the simulator posted no new tweets of public users")
+	}
+	if collected != newTweets || len(e.tweets) != newTweets {
+		t.Errorf("tailed %d tweets and stored %d, want only the %d new ones", collected, len(e.tweets), newTweets)
+	}
+	//every public user has tweets stored, so each is polled with one request
+	//using since_id, rather than collected again
+	c.recordAPIUsage()
+	s.flush()
+	polls := 0
+	for _, u := range s.GetAPIUsage("") {
+		if u.Phase == UsageTail {
+			polls += u.Calls
+		}
+	}
+	if polls != public {
+		t.Errorf("made %d requests with since_id to poll %d users", polls, public)
+	}
+}
+
+func TestFullTextSearch(t *testing.T) {
+	probe, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = probe.Exec("CREATE VIRTUAL TABLE probe USING fts5(text)")
+	probe.Close()
+	if err != nil {
+		t.Skip("full-text search needs the sqlite_fts5 build tag")
+	}
+
+	sim := newTestSimulator()
+	dbName := filepath.Join(t.TempDir(), "corpus")
+	s := NewStorage(dbName, WithFullTextSearch(FullTextConfig{Tokenizer: TokenizerUnicode61}))
+	c := newTestCollector(sim, s)
+	c.SeedScreenNames(sim.ScreenNames())
+	c.ProcessScreenNames()
+	s.flush()
+	for _, u := range sim.users {
+		c.CollectTweets(u.id, 0)
+	}
+	s.flush()
+
+	//the tweets matching the query going by the simulated texts, most recent first
+	want := func(match func(text string) bool) []int64 {
+		var IDs []int64
+		for _, u := range sim.users {
+			for _, tweet := range u.tweets {
+				if !u.protected && match(tweet.text) {
+					IDs = append(IDs, tweet.id)
+				}
+			}
+		}
+		sort.Slice(IDs, func(i, j int) bool { return IDs[i] > IDs[j] })
+		return IDs
+	}
+	search := func(s *Storage, text string) []int64 {
+		var IDs []int64
+		for _, tweet := range s.SearchTweets(text, 10000) {
+			IDs = append(IDs, tweet.ID)
+		}
+		return IDs
+	}
+
+	words := want(func(text string) bool {
+		for _, word := range strings.FieldsFunc(text, func(r rune) bool { return r == ' ' || r == ':' }) {
+			if word == "coffee" {
+				return true
+			}
+		}
+		return false
+	})
+	if len(words) == 0 {
+		t.Fatal("no simulated tweet mentions coffee")
+	}
+	if got := search(s, "coffee"); !equalIDs(got, words) {
+		t.Errorf("found %d tweets with the word, want %d", len(got), len(words))
+	}
+	if got := search(s, "offe"); len(got) != 0 {
+		t.Errorf("found %d tweets with part of a word, want whole words only", len(got))
+	}
+	s.Close()
+
+	//reopening with another tokenizer rebuilds the index
+	s = NewStorage(dbName, WithFullTextSearch(FullTextConfig{Tokenizer: TokenizerTrigram}))
+	defer s.Close()
+	substrings := want(func(text string) bool { return strings.Contains(text, "offe") })
+	if got := search(s, "offe"); !equalIDs(got, substrings) {
+		t.Errorf("found %d tweets with the substring, want %d", len(got), len(substrings))
+	}
+}
+
+func TestRecollectTweetsFindsDeletedTweets(t *testing.T) {
+	sim := newTestSimulator()
+	u := mostFollowedUser(sim)
+	s := NewStorage(filepath.Join(t.TempDir(), "corpus"))
+	defer s.Close()
+	c := newTestCollector(sim, s)
+	c.SeedScreenNames([]string{simScreenName(u.id)})
+	c.ProcessScreenNames()
+	s.flush()
+	c.CollectTweets(u.id, 0)
+	s.flush()
+
+	//a tweet in the middle of the timeline is deleted, and one wrongly marked
+	//deleted is still there
+	deletedID, returnedID := u.tweets[1].id, u.tweets[2].id
+	sim.DeleteTweets(deletedID)
+	s.MarkTweetStatus(returnedID, TweetDeleted, time.Now().Unix())
+	s.flush()
+	if _, deleted := c.RecollectTweets(u.id); deleted != 1 {
+		t.Errorf("found %d tweets deleted, want 1", deleted)
+	}
+	s.flush()
+
+	status := func(ID int64) (status string, deletedAt *int64) {
+		err := s.db.QueryRow("SELECT status, deleted_at FROM tweets WHERE tweet_id=?", ID).Scan(&status, &deletedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return status, deletedAt
+	}
+	if got, deletedAt := status(deletedID); got != TweetDeleted || deletedAt == nil {
+		t.Errorf("the deleted tweet is %q, deleted at %v", got, deletedAt)
+	}
+	if got, deletedAt := status(returnedID); got != TweetAvailable || deletedAt != nil {
+		t.Errorf("the tweet returned again is %q, deleted at %v", got, deletedAt)
+	}
+}
@@ -0,0 +1,150 @@
+package callosum
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+//DefaultSpamThreshold is the SpamScore at and above which a user is taken
+//for a spam account, see NotSpam.
+const DefaultSpamThreshold = 0.5
+
+//The weights of the heuristics in SpamScore. Heuristics that can't be
+//computed are left out and the others weigh more.
+const (
+	spamWeightTweetRate     = 0.3
+	spamWeightDefaultImage  = 0.2
+	spamWeightFollowRatio   = 0.3
+	spamWeightDuplicateRate = 0.2
+)
+
+//spamUser holds the fields of a user object the spam heuristics look at
+type spamUser struct {
+	CreatedAt           string `json:"created_at"`
+	StatusesCount       int    `json:"statuses_count"`
+	FollowersCount      int    `json:"followers_count"`
+	FriendsCount        int    `json:"friends_count"`
+	DefaultProfileImage bool   `json:"default_profile_image"`
+}
+
+//clamp maps x from [low, high] to [0, 1]
+func clamp(x, low, high float64) float64 {
+	switch {
+	case x <= low:
+		return 0
+	case x >= high:
+		return 1
+	}
+	return (x - low) / (high - low)
+}
+
+//spamScore combines the heuristics of obvious spam accounts into a score
+//from 0 to 1: tweeting dozens of times a day for the age of the account,
+//the default profile image, following far more accounts than follow back
+//and, when duplicateRate is not negative, the fraction of the user's tweets
+//that are near-duplicates of other tweets.
+func spamScore(u spamUser, duplicateRate float64, now time.Time) float64 {
+	var score, weights float64
+	if createdAt, err := time.Parse(time.RubyDate, u.CreatedAt); err == nil {
+		days := now.Sub(createdAt).Hours()/24 + 1
+		score += spamWeightTweetRate * clamp(float64(u.StatusesCount)/days, 50, 150)
+		weights += spamWeightTweetRate
+	}
+	if u.DefaultProfileImage {
+		score += spamWeightDefaultImage
+	}
+	weights += spamWeightDefaultImage
+	if u.FriendsCount >= 100 {
+		score += spamWeightFollowRatio * clamp(float64(u.FriendsCount)/float64(u.FollowersCount+1), 5, 20)
+	}
+	weights += spamWeightFollowRatio
+	if duplicateRate >= 0 {
+		score += spamWeightDuplicateRate * clamp(duplicateRate, 0.1, 0.5)
+		weights += spamWeightDuplicateRate
+	}
+	return score / weights
+}
+
+//SpamScore scores the user object on heuristics of obvious spam accounts,
+//from 0 for none of the signs to 1: tweeting dozens of times a day since
+//the account was created, the default profile image and following far more
+//accounts than follow back. ScoreSpam adds the rate of duplicate tweets.
+func SpamScore(blob []byte) float64 {
+	var u spamUser
+	if json.Unmarshal(blob, &u) != nil {
+		return 0
+	}
+	return spamScore(u, -1, time.Now())
+}
+
+//NotSpam is a FilterUser that accepts the users with a SpamScore below
+//DefaultSpamThreshold, so that obvious spam accounts are left out of the
+//collection. It is registered as the "not_spam" filter.
+func NotSpam(blob []byte) bool {
+	return SpamScore(blob) < DefaultSpamThreshold
+}
+
+//ScoreSpam scores every stored user, like SpamScore but also on the
+//fraction of their stored tweets that are near-duplicates, which needs
+//MarkNearDuplicates to be run first, and stores the score in the
+//`spam_score` column of the `users` table, as a batch pass over users
+//collected without the NotSpam filter. It returns the number of users with a
+//score of DefaultSpamThreshold or more.
+func (s *Storage) ScoreSpam() int {
+	duplicateRates := make(map[int64]float64)
+	rows, err := s.db.Query(`SELECT user_id, AVG(near_duplicate_of != 0) FROM tweets
+		WHERE simhash IS NOT NULL GROUP BY user_id`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for rows.Next() {
+		var userID int64
+		var rate float64
+		err = rows.Scan(&userID, &rate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		duplicateRates[userID] = rate
+	}
+	rows.Close()
+
+	rows, err = s.db.Query("SELECT user_id, blob FROM users ORDER BY user_id")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var spam int
+	now := time.Now()
+	for rows.Next() {
+		var userID int64
+		var blob []byte
+		err = rows.Scan(&userID, &blob)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var u spamUser
+		if json.Unmarshal(blob, &u) != nil {
+			continue
+		}
+		duplicateRate, ok := duplicateRates[userID]
+		if !ok {
+			duplicateRate = -1
+		}
+		score := spamScore(u, duplicateRate, now)
+		if score >= DefaultSpamThreshold {
+			spam++
+		}
+		chQueryArgs <- &queryArgs{"UPDATE users SET spam_score=? WHERE user_id=?", []interface{}{score, userID}}
+	}
+	return spam
+}
+
+//GetSpamUserIDs gets the IDs of the users with a `spam_score` of threshold
+//or more, see ScoreSpam.
+func (s *Storage) GetSpamUserIDs(threshold float64) []int64 {
+	var results []int64
+	s.queryScreenNamesOrIDs("SELECT user_id FROM users WHERE spam_score >= ? ORDER BY user_id", &results, threshold)
+	return results
+}
@@ -0,0 +1,95 @@
+package callosum
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"io"
+	"log"
+	"strconv"
+)
+
+//The partitions users are split into by SplitUsers
+const (
+	PartitionTrain      = "train"
+	PartitionValidation = "validation"
+	PartitionTest       = "test"
+)
+
+//Split holds the fractions of the accepted users assigned to each partition.
+//Whatever Train and Validation leave over goes to Test.
+type Split struct {
+	Train      float64
+	Validation float64
+}
+
+//DefaultSplit assigns 80% of the users to train, 10% to validation and 10% to test
+var DefaultSplit = Split{Train: 0.8, Validation: 0.1}
+
+//Partition returns the partition of the user for the seed. The assignment
+//only depends on the seed and the user's ID, so a user stays in the same
+//partition as the corpus grows and the split can be reproduced from the seed.
+func (split Split) Partition(seed, userID int64) string {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], uint64(seed))
+	binary.BigEndian.PutUint64(buf[8:], uint64(userID))
+	sum := sha256.Sum256(buf[:])
+	x := float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+
+	switch {
+	case x < split.Train:
+		return PartitionTrain
+	case x < split.Train+split.Validation:
+		return PartitionValidation
+	}
+	return PartitionTest
+}
+
+//SplitUsers assigns every accepted user, and with them their tweets, to a
+//train, validation or test partition and stores the assignments in the
+//`partitions` table, replacing any previous split. Keeping all of a user's
+//tweets in one partition stops models from being evaluated on users they
+//were trained on.
+func (s *Storage) SplitUsers(seed int64, split Split) map[int64]string {
+	if split.Train < 0 || split.Validation < 0 || split.Train+split.Validation > 1 {
+		log.Fatalf("invalid split %+v", split)
+	}
+
+	partitions := make(map[int64]string)
+	chQueryArgs <- &queryArgs{"DELETE FROM partitions", nil}
+	for _, ID := range s.GetAcceptedUserIDs() {
+		partition := split.Partition(seed, ID)
+		partitions[ID] = partition
+		chQueryArgs <- &queryArgs{"INSERT INTO partitions (user_id, partition, seed) VALUES (?, ?, ?)",
+			[]interface{}{ID, partition, seed}}
+	}
+	return partitions
+}
+
+//ExportPartitions writes the partition assignments stored by SplitUsers to w
+//as CSV, along with the number of tweets of each user.
+func (s *Storage) ExportPartitions(w io.Writer) error {
+	rows, err := s.db.Query(`
+		SELECT partitions.user_id, partitions.partition, COUNT(tweets.tweet_id) FROM partitions
+		LEFT JOIN tweets ON tweets.user_id = partitions.user_id
+		GROUP BY partitions.user_id ORDER BY partitions.user_id`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"user_id", "partition", "tweets"})
+	for rows.Next() {
+		var userID int64
+		var partition string
+		var tweets int
+		err = rows.Scan(&userID, &partition, &tweets)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cw.Write([]string{strconv.FormatInt(userID, 10), partition, strconv.Itoa(tweets)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
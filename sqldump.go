@@ -0,0 +1,228 @@
+package callosum
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//SQLDumpOptions configures the dump written by ExportSQLDump
+type SQLDumpOptions struct {
+	//AcceptedOnly keeps only the rows of accepted users: the accepted users,
+	//the rows of the tables with a user_id column, the rows of the tables
+	//keyed by tweet_id whose tweet is by an accepted user, the edges between
+	//users whose ends are both accepted, the lists accepted users own and the
+	//media and polls attached to the tweets kept. The other tables are dumped
+	//whole.
+	AcceptedOnly bool
+}
+
+const (
+	sqlDumpAcceptedUsers  = "SELECT user_id FROM users WHERE accepted=1"
+	sqlDumpAcceptedTweets = "SELECT tweet_id FROM tweets WHERE user_id IN (" + sqlDumpAcceptedUsers + ")"
+)
+
+//sqlDumpFilters are the conditions on the rows of the tables kept with
+//AcceptedOnly that are not filtered by their user_id or tweet_id column
+var sqlDumpFilters = map[string]string{
+	"users":         "accepted=1",
+	"replies":       "from_user_id IN (" + sqlDumpAcceptedUsers + ") AND to_user_id IN (" + sqlDumpAcceptedUsers + ")",
+	"mentions":      "from_user_id IN (" + sqlDumpAcceptedUsers + ") AND to_user_id IN (" + sqlDumpAcceptedUsers + ")",
+	"mention_edges": "from_user_id IN (" + sqlDumpAcceptedUsers + ") AND to_user_id IN (" + sqlDumpAcceptedUsers + ")",
+	"quote_edges":   "quoting_user_id IN (" + sqlDumpAcceptedUsers + ") AND quoted_user_id IN (" + sqlDumpAcceptedUsers + ")",
+	"retweets":      "retweeter_id IN (" + sqlDumpAcceptedUsers + ") AND original_author_id IN (" + sqlDumpAcceptedUsers + ")",
+	"lists":         "owner_id IN (" + sqlDumpAcceptedUsers + ")",
+	"media":         "media_key IN (SELECT attachment_key FROM attachments WHERE tweet_id IN (" + sqlDumpAcceptedTweets + "))",
+	"polls":         "poll_id IN (SELECT attachment_key FROM attachments WHERE tweet_id IN (" + sqlDumpAcceptedTweets + "))",
+}
+
+type sqlDumpColumn struct {
+	name, sqlType string
+	primaryKey    int
+}
+
+//ExportSQLDump writes the schema and the rows of the tables of the database
+//to w as plain SQL, to load the corpus into other SQL engines or archive it
+//in a form that can be read and diffed. The tables are created with portable
+//column types, BIGINT, DOUBLE PRECISION and TEXT, and their primary keys,
+//while indexes, views, triggers and the full-text index are left out. Blobs
+//are JSON and written as text, those that are not valid UTF-8 are written
+//base64 encoded, so that every value fits its column's type. The rows are read in a single transaction, so the dump
+//is consistent even while the collection is running.
+func (s *Storage) ExportSQLDump(w io.Writer, opts SQLDumpOptions) error {
+	s.flush()
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "BEGIN TRANSACTION;")
+	for _, table := range s.dumpTableNames(tx) {
+		columns := s.sqlDumpColumns(tx, table)
+		definitions := make([]string, len(columns))
+		names := make([]string, len(columns))
+		var keys []string
+		hasUserID, hasTweetID := false, false
+		for i, c := range columns {
+			names[i] = quoteIdentifier(c.name)
+			definitions[i] = names[i] + " " + c.sqlType
+			if c.primaryKey > 0 {
+				keys = append(keys, names[i])
+			}
+			hasUserID = hasUserID || c.name == "user_id"
+			hasTweetID = hasTweetID || c.name == "tweet_id"
+		}
+		if len(keys) > 0 {
+			definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(keys, ", ")))
+		}
+		fmt.Fprintf(bw, "\nCREATE TABLE %s (%s);\n", quoteIdentifier(table), strings.Join(definitions, ", "))
+
+		query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(names, ", "), quoteIdentifier(table))
+		if filter, ok := sqlDumpFilters[table]; opts.AcceptedOnly && ok {
+			query += " WHERE " + filter
+		} else if opts.AcceptedOnly && hasUserID {
+			query += " WHERE user_id IN (" + sqlDumpAcceptedUsers + ")"
+		} else if opts.AcceptedOnly && hasTweetID {
+			query += " WHERE tweet_id IN (" + sqlDumpAcceptedTweets + ")"
+		}
+		err = dumpRows(bw, tx, query, table, strings.Join(names, ", "), len(columns))
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(bw, "\nCOMMIT;")
+	return bw.Flush()
+}
+
+//dumpTableNames gets the names of the tables of the database, without the
+//virtual tables, such as the full-text index, and the tables backing them.
+func (s *Storage) dumpTableNames(tx *sql.Tx) []string {
+	rows, err := tx.Query("SELECT name, sql FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names, virtual []string
+	for rows.Next() {
+		var name, definition string
+		err = rows.Scan(&name, &definition)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if strings.HasPrefix(definition, "CREATE VIRTUAL TABLE") {
+			virtual = append(virtual, name+"_")
+			continue
+		}
+		names = append(names, name)
+	}
+	var tables []string
+	for _, name := range names {
+		backing := false
+		for _, prefix := range virtual {
+			backing = backing || strings.HasPrefix(name, prefix)
+		}
+		if !backing {
+			tables = append(tables, name)
+		}
+	}
+	return tables
+}
+
+//sqlDumpColumns gets the columns of the table with the portable SQL types
+//matching their SQLite types.
+func (s *Storage) sqlDumpColumns(tx *sql.Tx, table string) []sqlDumpColumn {
+	rows, err := tx.Query("PRAGMA table_info(" + quoteIdentifier(table) + ")")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var columns []sqlDumpColumn
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue interface{}
+		err = rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sqlType := "TEXT"
+		switch columnType = strings.ToUpper(columnType); {
+		case strings.Contains(columnType, "INT"):
+			sqlType = "BIGINT"
+		case strings.Contains(columnType, "REAL"), strings.Contains(columnType, "FLOA"), strings.Contains(columnType, "DOUB"):
+			sqlType = "DOUBLE PRECISION"
+		}
+		columns = append(columns, sqlDumpColumn{name, sqlType, pk})
+	}
+	return columns
+}
+
+//dumpRows writes the rows selected by query as INSERT statements into table
+func dumpRows(w *bufio.Writer, tx *sql.Tx, query, table, names string, count int) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, count)
+	pointers := make([]interface{}, count)
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	literals := make([]string, count)
+	for rows.Next() {
+		err = rows.Scan(pointers...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, v := range values {
+			literals[i] = sqlLiteral(v)
+		}
+		_, err = fmt.Fprintf(w, "INSERT INTO %s (%s) VALUES (%s);\n", quoteIdentifier(table), names, strings.Join(literals, ", "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//sqlLiteral returns v as an SQL literal
+func sqlLiteral(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case bool:
+		if x {
+			return "1"
+		}
+		return "0"
+	case []byte:
+		if !utf8.Valid(x) {
+			return "'" + base64.StdEncoding.EncodeToString(x) + "'"
+		}
+		return "'" + strings.Replace(string(x), "'", "''", -1) + "'"
+	default:
+		return "'" + strings.Replace(fmt.Sprint(x), "'", "''", -1) + "'"
+	}
+}
+
+//quoteIdentifier returns name as an SQL identifier, in double quotes, which
+//are doubled within it
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
@@ -1,10 +1,14 @@
 package callosum
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3" //sqllite DB driver import
 )
@@ -21,6 +25,10 @@ type UserRow struct {
 	Protected        int
 	Processed        int
 	Accepted         int
+	SkipReason       string
+	Verified         int
+	Failures         int
+	Tier             Tier
 	Blob             []byte
 }
 
@@ -35,7 +43,12 @@ type TweetRow struct {
 
 //Storage holds a open connection the the sqlite database
 type Storage struct {
-	db *sql.DB
+	db       *sql.DB
+	views    bool
+	fullText *FullTextConfig
+	//fullTextIndexed is whether the database has a full-text index, known
+	//once it is opened
+	fullTextIndexed bool
 }
 
 type queryArgs struct {
@@ -47,38 +60,266 @@ var mutex = &sync.Mutex{}
 
 var chQueryArgs chan *queryArgs
 
+//chPriorityArgs is the writer's lane for the small writes to users rows and
+//the queues of users to collect, which the collection phases wait on. The
+//writer executes them ahead of the bulk tweets and edges in chQueryArgs, so
+//that a burst of edges doesn't hold them up.
+var chPriorityArgs chan *queryArgs
+
 var db *sql.DB
 
+//writeDB is the handle the writer goroutine executes the queued statements
+//on. It holds a single connection, SQLite allows one writer at a time anyway,
+//while db holds the pool of connections the queries read through, see
+//PoolConfig.
+var writeDB *sql.DB
+
+//PoolConfig sets up the pool of connections Storage reads through, so that
+//analysis queries against a live crawl run alongside the writer and each
+//other. See the database/sql documentation of the DB methods of the same
+//names. Zero fields leave the setting as is.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+//DefaultPoolConfig is the reader pool NewStorage sets up
+var DefaultPoolConfig = PoolConfig{MaxOpenConns: 8, MaxIdleConns: 4}
+
+//busyTimeout is how long, in milliseconds, a connection waits for another
+//one to finish writing before failing. Besides the writer, schema changes
+//and a few small writes are made through the readers.
+const busyTimeout = 5000
+
+//lastWrite holds the unix nanoseconds of the last statement executed by the writer
+var lastWrite int64
+
+//checkpoints holds the checkpoint updates waiting for the writer, by user
+//and checkpoint, see queueCheckpoint
+var checkpoints = struct {
+	sync.Mutex
+	pending map[string]*queryArgs
+}{pending: make(map[string]*queryArgs)}
+
+//queueCheckpoint queues an update of a user's checkpoint columns. Collecting
+//a big timeline or list of IDs moves the checkpoint many times before the
+//writer catches up, so only the latest update for each key is kept and it is
+//executed at the end of the writer's next flush, after every statement queued
+//before it.
+func queueCheckpoint(key string, qa *queryArgs) {
+	checkpoints.Lock()
+	_, waiting := checkpoints.pending[key]
+	checkpoints.pending[key] = qa
+	checkpoints.Unlock()
+	if !waiting {
+		chPriorityArgs <- &queryArgs{"", nil} //wakes up the writer
+	}
+}
+
+//executeStatements runs the writer. Each flush executes the statements
+//queued up in chQueryArgs followed by the checkpoint updates that were
+//pending when the flush started. Statements in chPriorityArgs are executed
+//as soon as the current statement is done. Checkpoint updates queued during a
+//flush may find their wake up consumed by it, so the writer flushes again
+//right away while any are pending.
 func executeStatements() {
+	var more bool
 	for {
-		if qa, ok := <-chQueryArgs; ok {
-			_, err := db.Exec(qa.query, qa.args...)
-			if err != nil {
-				log.Fatal(err)
+		var first *queryArgs
+		if !more {
+			select {
+			case first = <-chPriorityArgs:
+			case first = <-chQueryArgs:
+			}
+		}
+
+		checkpoints.Lock()
+		pending := checkpoints.pending
+		checkpoints.pending = make(map[string]*queryArgs)
+		checkpoints.Unlock()
+
+		var flushed []chan struct{}
+		run := func(qa *queryArgs) {
+			switch {
+			case qa.query == "" && len(qa.args) > 0: //queued by flush
+				flushed = append(flushed, qa.args[0].(chan struct{}))
+			case qa.query != "":
+				execute(qa)
 			}
 		}
+		runPriority := func() {
+			for {
+				select {
+				case qa := <-chPriorityArgs:
+					run(qa)
+				default:
+					return
+				}
+			}
+		}
+
+		if first != nil {
+			run(first)
+		}
+		for n := len(chQueryArgs); n > 0; n-- {
+			runPriority()
+			run(<-chQueryArgs)
+		}
+		runPriority()
+		for _, qa := range pending {
+			execute(qa)
+		}
+		for _, done := range flushed {
+			close(done)
+		}
+
+		checkpoints.Lock()
+		more = len(checkpoints.pending) > 0
+		checkpoints.Unlock()
+	}
+}
+
+func execute(qa *queryArgs) {
+	mutex.Lock()
+	writer := writeDB
+	mutex.Unlock()
+	if writer == nil {
+		log.Fatal(ErrStorageClosed)
+	}
+	args := qa.args
+	var inserted insertedHook
+	if n := len(args); n > 0 {
+		if hook, ok := args[n-1].(insertedHook); ok {
+			inserted, args = hook, args[:n-1]
+		}
+	}
+	result, err := writer.Exec(qa.query, args...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	atomic.StoreInt64(&lastWrite, time.Now().UnixNano())
+	if inserted != nil {
+		if rows, err := result.RowsAffected(); err == nil && rows > 0 {
+			inserted()
+		}
+	}
+}
+
+//insertedHook, passed as the last of the args of an INSERT OR IGNORE, is
+//called by the writer once the statement inserted its row, and not when
+//the row was already there.
+type insertedHook func()
+
+//WriterStatus reports the number of statements queued up for the writer
+//goroutine, including pending checkpoint updates, and when it last executed one.
+func (s *Storage) WriterStatus() (queued int, lastWriteAt time.Time) {
+	if nanos := atomic.LoadInt64(&lastWrite); nanos != 0 {
+		lastWriteAt = time.Unix(0, nanos)
+	}
+	checkpoints.Lock()
+	defer checkpoints.Unlock()
+	return len(chQueryArgs) + len(chPriorityArgs) + len(checkpoints.pending), lastWriteAt
+}
+
+//flush waits for the writer to execute the statements and checkpoint
+//updates queued so far, in both lanes.
+func (s *Storage) flush() {
+	for {
+		priorityDone, done := make(chan struct{}), make(chan struct{})
+		chPriorityArgs <- &queryArgs{"", []interface{}{priorityDone}}
+		chQueryArgs <- &queryArgs{"", []interface{}{done}}
+		<-priorityDone
+		<-done
+
+		//checkpoints queued after the flush in progress started wait for the next one
+		checkpoints.Lock()
+		pending := len(checkpoints.pending)
+		checkpoints.Unlock()
+		if pending == 0 {
+			return
+		}
 	}
 }
 
+//checkOpen returns ErrStorageClosed once the database was closed with Close
+func (s *Storage) checkOpen() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if db == nil || db != s.db {
+		return ErrStorageClosed
+	}
+	return nil
+}
+
+//Close waits for the queued writes to be executed and closes the database.
+//Storage fails with ErrStorageClosed afterwards, a later NewStorage opens the
+//database again.
+func (s *Storage) Close() error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	s.flush()
+	mutex.Lock()
+	defer mutex.Unlock()
+	err := writeDB.Close()
+	db, writeDB = nil, nil
+	if readErr := s.db.Close(); err == nil {
+		err = readErr
+	}
+	return err
+}
+
+//ConfigurePool sets up the pool of connections used for reading.
+func (s *Storage) ConfigurePool(c PoolConfig) {
+	if c.MaxOpenConns != 0 {
+		s.db.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns != 0 {
+		s.db.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime != 0 {
+		s.db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+}
+
+//CheckWritable makes a small write to the `health` table to check that
+//the database accepts writes.
+func (s *Storage) CheckWritable(ctx context.Context) error {
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "INSERT OR REPLACE INTO health (id, checked_at) VALUES (1, ?)", time.Now().UTC().Unix())
+	return err
+}
+
 //NewStorage creates returns a new Storage object.
 //DBName is the name of the sqllite database file where
 //all the users and tweets data will be collected. NewStorage
 //create the sqlite file, if it is not already present and creates
-//the tables. if the database is present, opens a connection.
-func NewStorage(DBName string) *Storage {
+//the tables. if the database is present, opens a connection. The options
+//apply when the database is opened, see WithViews.
+func NewStorage(DBName string, opts ...StorageOption) *Storage {
 	s := &Storage{}
+	for _, opt := range opts {
+		opt(s)
+	}
 	mutex.Lock()
 	if db == nil {
 		s.checkMakeDatabase(DBName)
 		db = s.db
 		if chQueryArgs == nil {
-			chQueryArgs = make(chan *queryArgs, 100)
+			chQueryArgs = make(chan *queryArgs, writeQueueSize)
+			chPriorityArgs = make(chan *queryArgs, writeQueueSize)
 			go executeStatements()
 		}
 
 		s.setupTables()
+	} else {
+		s.db = db
 	}
 	mutex.Unlock()
+	s.fullTextIndexed = s.fullTextTable() != ""
 	return s
 }
 
@@ -87,7 +328,7 @@ func (s *Storage) setupTables() {
 	s.makeTable(tableName, fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s (
 							user_id INTEGER PRIMARY KEY,
-							screen_name TEXT CONSTRAINT uniquescreenname UNIQUE,
+							screen_name TEXT,
 							description TEXT CONSTRAINT defaultdesc DEFAULT "",
 							last_looked_at INTEGER CONSTRAINT defaultlastlookedat DEFAULT 0,
 							latest_tweet_id INTEGER CONSTRAINT defaultlatesttweetid DEFAULT 0,
@@ -97,6 +338,8 @@ func (s *Storage) setupTables() {
 							processed INTEGER CONSTRAINT defaultprocessed DEFAULT 0,
 							accepted INTEGER CONSTRAINT defaultaccepted DEFAULT 0,
 							blob BLOB)`, tableName))
+	s.dropUniqueScreenName()
+	s.makeTable(tableName, "CREATE INDEX IF NOT EXISTS users_screen_name ON users(screen_name)")
 	tableName = "tweets"
 	s.makeTable(tableName, fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s(tweet_id INTEGER PRIMARY KEY,
@@ -127,11 +370,326 @@ func (s *Storage) setupTables() {
 		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER,
 			following_id INTEGER,
 			CONSTRAINT uniquemap UNIQUE (user_id, following_id))`, tableName))
+
+	tableName = "health"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(id INTEGER PRIMARY KEY,
+			checked_at INTEGER)`, tableName))
+
+	tableName = "user_rank"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER PRIMARY KEY,
+			rank REAL,
+			computed_at INTEGER)`, tableName))
+
+	tableName = "annotations"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(tweet_id INTEGER,
+			key TEXT,
+			value,
+			CONSTRAINT uniqueannotation UNIQUE (tweet_id, key))`, tableName))
+
+	tableName = "partitions"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER PRIMARY KEY,
+			partition TEXT,
+			seed INTEGER)`, tableName))
+
+	tableName = "retweets"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(tweet_id INTEGER PRIMARY KEY,
+			retweeter_id INTEGER,
+			original_author_id INTEGER,
+			original_tweet_id INTEGER)`, tableName))
+
+	tableName = "replies"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(tweet_id INTEGER PRIMARY KEY,
+			from_user_id INTEGER,
+			to_user_id INTEGER,
+			in_reply_to_tweet_id INTEGER)`, tableName))
+
+	tableName = "name_changes"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER,
+			old_screen_name TEXT,
+			new_screen_name TEXT,
+			changed_at INTEGER)`, tableName))
+	s.makeTable(tableName, "CREATE INDEX IF NOT EXISTS name_changes_old_screen_name ON name_changes(old_screen_name)")
+
+	tableName = "name_reuses"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(screen_name TEXT,
+			previous_user_id INTEGER,
+			user_id INTEGER,
+			detected_at INTEGER,
+			CONSTRAINT uniquereuse UNIQUE (screen_name, previous_user_id, user_id))`, tableName))
+
+	tableName = "follower_snapshots"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER,
+			follower_id INTEGER,
+			taken_at INTEGER,
+			CONSTRAINT uniquesnapshot UNIQUE (user_id, taken_at, follower_id))`, tableName))
+	tableName = "follower_churn"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER,
+			previous_taken_at INTEGER,
+			taken_at INTEGER,
+			gained INTEGER,
+			lost INTEGER,
+			CONSTRAINT uniquechurn UNIQUE (user_id, taken_at))`, tableName))
+
+	tableName = "places"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(place_id TEXT PRIMARY KEY,
+			name TEXT,
+			full_name TEXT,
+			place_type TEXT,
+			country_code TEXT,
+			country TEXT,
+			latitude REAL,
+			longitude REAL,
+			bounding_box TEXT)`, tableName))
+
+	tableName = "quote_edges"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(tweet_id INTEGER PRIMARY KEY,
+			quoting_user_id INTEGER,
+			quoted_user_id INTEGER,
+			quoted_tweet_id INTEGER)`, tableName))
+
+	//mention_edges is kept up to date from mentions by a trigger so that a
+	//tweet stored again, or backfilled, is not counted twice.
+	tableName = "mentions"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(tweet_id INTEGER,
+			from_user_id INTEGER,
+			to_user_id INTEGER,
+			CONSTRAINT uniquemention UNIQUE (tweet_id, to_user_id))`, tableName))
+	tableName = "mention_edges"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(from_user_id INTEGER,
+			to_user_id INTEGER,
+			count INTEGER,
+			CONSTRAINT uniqueedge UNIQUE (from_user_id, to_user_id))`, tableName))
+	s.makeTable(tableName, `
+		CREATE TRIGGER IF NOT EXISTS count_mention_edges AFTER INSERT ON mentions
+		BEGIN
+			INSERT INTO mention_edges (from_user_id, to_user_id, count) VALUES (NEW.from_user_id, NEW.to_user_id, 1)
+			ON CONFLICT (from_user_id, to_user_id) DO UPDATE SET count = count + 1;
+		END`)
+
+	//the expansions of tweets imported from the v2 API, see ImportTwarc
+	tableName = "included_tweets"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(tweet_id INTEGER PRIMARY KEY,
+			user_id INTEGER,
+			created_at INTEGER,
+			text TEXT,
+			blob BLOB)`, tableName))
+	tableName = "included_users"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER PRIMARY KEY,
+			screen_name TEXT,
+			blob BLOB)`, tableName))
+	tableName = "media"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(media_key TEXT PRIMARY KEY,
+			type TEXT,
+			url TEXT,
+			alt_text TEXT,
+			blob BLOB)`, tableName))
+	tableName = "polls"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(poll_id INTEGER PRIMARY KEY,
+			voting_status TEXT,
+			ends_at INTEGER,
+			blob BLOB)`, tableName))
+	tableName = "attachments"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(tweet_id INTEGER,
+			attachment_type TEXT,
+			attachment_key TEXT,
+			CONSTRAINT uniqueattachment UNIQUE (tweet_id, attachment_type, attachment_key))`, tableName))
+
+	tableName = "rate_limits"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(endpoint TEXT PRIMARY KEY,
+			remaining INTEGER,
+			reset_at INTEGER)`, tableName))
+
+	tableName = "tweet_consumption"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(month TEXT PRIMARY KEY,
+			tweets INTEGER)`, tableName))
+
+	tableName = "api_usage"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(run TEXT,
+			day TEXT,
+			phase TEXT,
+			endpoint TEXT,
+			calls INTEGER,
+			CONSTRAINT uniqueusage UNIQUE (run, day, phase, endpoint))`, tableName))
+
+	tableName = "user_counts"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER,
+			taken_at INTEGER,
+			followers INTEGER,
+			friends INTEGER,
+			tweets INTEGER,
+			CONSTRAINT uniquecounts UNIQUE (user_id, taken_at))`, tableName))
+
+	tableName = "edits"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(tweet_id INTEGER PRIMARY KEY,
+			initial_tweet_id INTEGER,
+			version INTEGER,
+			user_id INTEGER)`, tableName))
+	s.makeTable(tableName, "CREATE INDEX IF NOT EXISTS edits_initial_tweet_id ON edits(initial_tweet_id)")
+
+	tableName = "edit_versions"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(tweet_id INTEGER PRIMARY KEY,
+			created_at INTEGER,
+			langugage TEXT,
+			desc TEXT,
+			blob BLOB)`, tableName))
+
+	tableName = "bio_entities"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER,
+			entity_type TEXT,
+			value TEXT,
+			CONSTRAINT uniquebioentity UNIQUE (user_id, entity_type, value))`, tableName))
+	s.makeTable(tableName, "CREATE INDEX IF NOT EXISTS bio_entities_value ON bio_entities(entity_type, value)")
+
+	tableName = "paging_cursors"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER,
+			phase TEXT,
+			next_cursor INTEGER,
+			head_id INTEGER,
+			CONSTRAINT uniquecursor UNIQUE (user_id, phase))`, tableName))
+
+	tableName = "user_failures"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER,
+			phase TEXT,
+			failures INTEGER,
+			CONSTRAINT uniquefailures UNIQUE (user_id, phase))`, tableName))
+
+	tableName = "user_attempts"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER,
+			phase TEXT,
+			attempts INTEGER,
+			last_attempt_at INTEGER,
+			last_error TEXT,
+			CONSTRAINT uniqueattempts UNIQUE (user_id, phase))`, tableName))
+
+	tableName = "account_tweets"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(account_id INTEGER,
+			source TEXT,
+			tweet_id INTEGER,
+			collected_at INTEGER,
+			CONSTRAINT uniqueaccounttweet UNIQUE (account_id, source, tweet_id))`, tableName))
+
+	tableName = "list_memberships"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(user_id INTEGER,
+			list_id INTEGER,
+			name TEXT,
+			owner_id INTEGER,
+			member_count INTEGER,
+			blob BLOB,
+			CONSTRAINT uniquemembership UNIQUE (user_id, list_id))`, tableName))
+	s.makeTable(tableName, "CREATE INDEX IF NOT EXISTS list_memberships_list_id ON list_memberships(list_id)")
+
+	tableName = "lists"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(list_id INTEGER PRIMARY KEY,
+			owner_id INTEGER,
+			name TEXT,
+			description TEXT,
+			member_count INTEGER,
+			mode TEXT,
+			blob BLOB)`, tableName))
+	s.makeTable(tableName, "CREATE INDEX IF NOT EXISTS lists_owner_id ON lists(owner_id)")
+	tableName = "list_members"
+	s.makeTable(tableName, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s(list_id INTEGER,
+			user_id INTEGER,
+			CONSTRAINT uniquelistmember UNIQUE (list_id, user_id))`, tableName))
+	s.makeTable(tableName, "CREATE INDEX IF NOT EXISTS list_members_user_id ON list_members(user_id)")
+
+	s.addColumn("users", "skip_reason", `TEXT CONSTRAINT defaultskipreason DEFAULT ""`)
+	s.addColumn("users", "community", `INTEGER CONSTRAINT defaultcommunity DEFAULT 0`)
+	s.addColumn("users", "url", `TEXT CONSTRAINT defaulturl DEFAULT ""`)
+	s.addColumn("users", "verified", "INTEGER CONSTRAINT defaultverified DEFAULT 0")
+	s.addColumn("users", "protected_checked_at", "INTEGER CONSTRAINT defaultprotectedcheckedat DEFAULT 0")
+	s.addColumn("users", "failures", "INTEGER CONSTRAINT defaultfailures DEFAULT 0")
+	//users stored before tiers were collected in full
+	s.addColumn("users", "tier", "INTEGER CONSTRAINT defaulttier DEFAULT 3")
+	s.addColumn("users", "profile_refreshed_at", "INTEGER CONSTRAINT defaultprofilerefreshedat DEFAULT 0")
+	s.addColumn("users", "memberships_collected_at", "INTEGER CONSTRAINT defaultmembershipscollectedat DEFAULT 0")
+	s.addColumn("users", "lists_collected_at", "INTEGER CONSTRAINT defaultlistscollectedat DEFAULT 0")
+	s.addColumn("users", "tweets_collected_at", "INTEGER CONSTRAINT defaulttweetscollectedat DEFAULT 0")
+	s.addColumn("users", "friends_collected_at", "INTEGER CONSTRAINT defaultfriendscollectedat DEFAULT 0")
+	s.addColumn("users", "followers_collected_at", "INTEGER CONSTRAINT defaultfollowerscollectedat DEFAULT 0")
+	s.addColumn("users", "spam_score", "REAL")
+	s.addColumn("users", "attempts", "INTEGER CONSTRAINT defaultattempts DEFAULT 0")
+	s.addColumn("users", "last_attempt_at", "INTEGER CONSTRAINT defaultlastattemptat DEFAULT 0")
+	s.addColumn("users", "last_error", `TEXT CONSTRAINT defaultlasterror DEFAULT ""`)
+	s.addColumn("users", "collected_tweets", "INTEGER CONSTRAINT defaultcollectedtweets DEFAULT 0")
+	s.addColumn("users", "collected_edges", "INTEGER CONSTRAINT defaultcollectededges DEFAULT 0")
+	s.addColumn("users", "missing_pinned_id", "INTEGER CONSTRAINT defaultmissingpinnedid DEFAULT 0")
+	s.addColumn("tweets", "simhash", "INTEGER")
+	s.addColumn("tweets", "near_duplicate_of", `INTEGER CONSTRAINT defaultnearduplicateof DEFAULT 0`)
+	s.addColumn("tweets", "pinned", "INTEGER CONSTRAINT defaultpinned DEFAULT 0")
+	s.addColumn("tweets", "latitude", "REAL")
+	s.addColumn("tweets", "longitude", "REAL")
+	s.addColumn("tweets", "place_id", "TEXT")
+	s.addColumn("tweets", "status", `TEXT CONSTRAINT defaultstatus DEFAULT "available"`)
+	s.addColumn("tweets", "status_checked_at", `INTEGER CONSTRAINT defaultstatuscheckedat DEFAULT 0`)
+	s.addColumn("tweets", "conversation_id", "INTEGER")
+	s.addColumn("tweets", "deleted_at", "INTEGER")
+	s.makeTable("tweets", "CREATE INDEX IF NOT EXISTS tweets_conversation_id ON tweets(conversation_id)")
+	s.makeTable("tweets", "CREATE INDEX IF NOT EXISTS tweets_place_id ON tweets(place_id)")
+	s.makeTable("tweets", "CREATE INDEX IF NOT EXISTS tweets_user_id_created_at ON tweets(user_id, created_at)")
+	s.makeTable("replies", "CREATE INDEX IF NOT EXISTS replies_in_reply_to_tweet_id ON replies(in_reply_to_tweet_id)")
+	s.addColumn("places", "parent_id", `TEXT CONSTRAINT defaultparentid DEFAULT ""`)
+	s.addColumn("places", "blob", "BLOB")
+	s.addColumn("places", "looked_up_at", "INTEGER CONSTRAINT defaultlookedupat DEFAULT 0")
+	s.addColumn("edits", "missing", "INTEGER CONSTRAINT defaultmissing DEFAULT 0")
+	s.addColumn("replies", "resolved", "INTEGER CONSTRAINT defaultresolved DEFAULT 0")
+
+	//the queues are scanned in pages every collection round, these keep the
+	//scans proportional to what is left to process instead of the whole table
+	s.makeTable("userids", "CREATE INDEX IF NOT EXISTS userids_unprocessed ON userids(user_id) WHERE processed=0")
+	s.makeTable("screennames", "CREATE INDEX IF NOT EXISTS screennames_unprocessed ON screennames(screen_name) WHERE processed=0")
+	s.makeTable("replies", "CREATE INDEX IF NOT EXISTS replies_unresolved ON replies(tweet_id) WHERE resolved=0")
+	s.makeTable("users", "CREATE INDEX IF NOT EXISTS users_accepted ON users(user_id) WHERE accepted=1")
+	s.makeTable("users", "CREATE INDEX IF NOT EXISTS users_tweets_collected_at ON users(tweets_collected_at, user_id) WHERE accepted=1")
+	s.makeTable("users", "CREATE INDEX IF NOT EXISTS users_friends_collected_at ON users(friends_collected_at, user_id) WHERE accepted=1")
+	s.makeTable("users", "CREATE INDEX IF NOT EXISTS users_followers_collected_at ON users(followers_collected_at, user_id) WHERE accepted=1")
+
+	if s.views {
+		s.setupViews()
+	}
+	if s.fullText != nil {
+		s.setupFullText()
+	}
 }
 
 func (s *Storage) checkMakeDatabase(DBName string) *sql.DB {
+	//auto vacuum only takes effect for new databases, see Maintain
+	dsn := fmt.Sprintf("%s.db?_busy_timeout=%d&_auto_vacuum=incremental", DBName, busyTimeout)
 	var db *sql.DB
-	db, err := sql.Open("sqlite3", DBName+".db") //?cache=shared&mode=rwc")
+	db, err := sql.Open("sqlite3", dsn) //?cache=shared&mode=rwc")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -139,6 +697,13 @@ func (s *Storage) checkMakeDatabase(DBName string) *sql.DB {
 	db.Exec("PRAGMA journal_mode=WAL;")
 
 	s.db = db
+	s.ConfigurePool(DefaultPoolConfig)
+
+	writeDB, err = sql.Open("sqlite3", dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	writeDB.SetMaxOpenConns(1)
 	return db
 }
 
@@ -150,6 +715,42 @@ func (s *Storage) makeTable(tableName, sqlStmt string) {
 	}
 }
 
+//addColumn adds a column to a table created by an older version of callosum
+//that does not have it yet.
+func (s *Storage) addColumn(tableName, columnName, definition string) {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", tableName))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		err = rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if name == columnName {
+			return
+		}
+	}
+	rows.Close()
+
+	sqlStmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableName, columnName, definition)
+	_, err = s.db.Exec(sqlStmt)
+	if err != nil {
+		log.Fatalf("%q: %s\n", err, sqlStmt)
+	}
+}
+
+//Backup writes a consistent copy of the database to fileName, which must not exist.
+func (s *Storage) Backup(fileName string) error {
+	_, err := s.db.Exec("VACUUM INTO ?", fileName)
+	return err
+}
+
 //StoreScreenName inserts the given screenName into the `screenames` table
 func (s *Storage) StoreScreenName(screenName string) {
 	_, err := s.db.Exec("INSERT OR IGNORE INTO screennames (screen_name) VALUES (?)", screenName)
@@ -158,16 +759,100 @@ func (s *Storage) StoreScreenName(screenName string) {
 	}
 }
 
-//StoreUser inserts the Twitter user details into the `users` table.
+//StoreUser inserts the Twitter user details into the `users` table. Users
+//already stored get their details updated, keeping their collection state,
+//and a change of screen name is recorded in the `name_changes` table. Users
+//are keyed by their ID, a screen name registered again by a different
+//account is recorded in the `name_reuses` table, see GetNameReuses. The
+//homepage URL and the entities of the bio are extracted, see ParseProfile,
+//and the `verified` column is set from the user object. The counts of the
+//user's followers, friends and tweets are kept in the `user_counts` table.
 func (s *Storage) StoreUser(userID int64, screenName, description string, protected bool, blob []byte) {
-	chQueryArgs <- &queryArgs{"INSERT OR IGNORE INTO users (user_id, screen_name, description, protected, blob) VALUES (?, ?, ?, ?, ?)",
-		[]interface{}{userID, screenName, description, protected, blob}}
+	changedAt := time.Now().UTC().Unix()
+	chPriorityArgs <- &queryArgs{`INSERT INTO name_changes (user_id, old_screen_name, new_screen_name, changed_at)
+		SELECT user_id, screen_name, ?, ? FROM users WHERE user_id=? AND screen_name IS NOT NULL AND screen_name != ?`,
+		[]interface{}{screenName, changedAt, userID, screenName}}
+	s.storeNameReuse(userID, screenName, changedAt)
+	profile := ParseProfile(blob)
+	chPriorityArgs <- &queryArgs{`INSERT INTO users (user_id, screen_name, description, protected, url, verified, profile_refreshed_at, blob)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET screen_name=excluded.screen_name, description=excluded.description,
+		protected=excluded.protected, url=excluded.url, verified=excluded.verified,
+		profile_refreshed_at=excluded.profile_refreshed_at, blob=excluded.blob`,
+		[]interface{}{userID, screenName, description, protected, profile.URL, Verified(blob), changedAt, blob}}
+	s.storeProfile(userID, profile)
+	s.storeUserCounts(userID, changedAt, blob)
+}
+
+//StoreNewUser inserts the Twitter user details into the `users` table like
+//StoreUser, but leaves users already stored as they are, for imports of
+//older dumps that would otherwise overwrite newer profiles. Callers check
+//that the user is not stored, as the profile and the counts are stored anyway.
+func (s *Storage) StoreNewUser(userID int64, screenName, description string, protected bool, blob []byte) {
+	storedAt := time.Now().UTC().Unix()
+	s.storeNameReuse(userID, screenName, storedAt)
+	profile := ParseProfile(blob)
+	chPriorityArgs <- &queryArgs{`INSERT OR IGNORE INTO users (user_id, screen_name, description, protected, url, verified, profile_refreshed_at, blob)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		[]interface{}{userID, screenName, description, protected, profile.URL, Verified(blob), storedAt, blob}}
+	s.storeProfile(userID, profile)
+	s.storeUserCounts(userID, storedAt, blob)
+}
+
+//NameChange is a change of a user's screen name, noticed when the user was
+//collected again.
+type NameChange struct {
+	UserID        int64
+	OldScreenName string
+	NewScreenName string
+	ChangedAt     time.Time
+}
+
+//GetNameChanges gets the screen name changes of the user from the
+//`name_changes` table, oldest first.
+func (s *Storage) GetNameChanges(userID int64) []NameChange {
+	rows, err := s.db.Query(`SELECT user_id, old_screen_name, new_screen_name, changed_at FROM name_changes
+		WHERE user_id=? ORDER BY changed_at, rowid`, userID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var changes []NameChange
+	for rows.Next() {
+		var c NameChange
+		var changedAt int64
+		err = rows.Scan(&c.UserID, &c.OldScreenName, &c.NewScreenName, &changedAt)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.ChangedAt = time.Unix(changedAt, 0).UTC()
+		changes = append(changes, c)
+	}
+	return changes
 }
 
-//StoreTweet inserts the tweet details into the `tweets` table.
+//StoreTweet inserts the tweet details into the `tweets` table, its
+//interactions with other users into the interaction tables: `retweets`,
+//`replies`, `mentions` and `quote_edges`, its location into the `places`
+//table and the location columns of `tweets`, the conversation it is part
+//of into `conversation_id`, and the versions of edited tweets into `edits`.
 func (s *Storage) StoreTweet(tweetID, createdAt, userID int64, language, desc string, blob []byte) {
-	chQueryArgs <- &queryArgs{"INSERT OR IGNORE INTO tweets (tweet_id, created_at, langugage, user_id, desc, blob) VALUES (?, ?, ?, ?, ?, ?)",
-		[]interface{}{tweetID, createdAt, language, userID, desc, blob}}
+	s.insertTweet(tweetID, createdAt, userID, language, desc, blob, nil)
+}
+
+//insertTweet is StoreTweet calling inserted, on the writer, when the tweet
+//was not stored yet.
+func (s *Storage) insertTweet(tweetID, createdAt, userID int64, language, desc string, blob []byte, inserted func()) {
+	args := []interface{}{tweetID, createdAt, language, userID, desc, blob}
+	if inserted != nil {
+		args = append(args, insertedHook(inserted))
+	}
+	chQueryArgs <- &queryArgs{"INSERT OR IGNORE INTO tweets (tweet_id, created_at, langugage, user_id, desc, blob) VALUES (?, ?, ?, ?, ?, ?)", args}
+	s.storeInteractions(tweetID, userID, blob)
+	s.storeLocation(tweetID, userID, blob)
+	s.storeConversation(tweetID, userID, blob)
+	s.storeEdits(tweetID, userID, blob)
 }
 
 func (s *Storage) storeFriendOrFollower(userID, friendOrFollowerID int64, query string) {
@@ -201,8 +886,8 @@ func (s *Storage) StoreUserIDs(userIDs []int64) {
 	}
 }
 
-func (s *Storage) queryScreenNamesOrIDs(query string, results interface{}) {
-	rows, err := s.db.Query(query)
+func (s *Storage) queryScreenNamesOrIDs(query string, results interface{}, args ...interface{}) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -253,6 +938,41 @@ func (s *Storage) GetUnprocessedUserIDs() []int64 {
 	return results
 }
 
+//GetUnprocessedUserIDsAfter gets up to limit of the user ids from the `userids`
+//table that are yet to be processed, in the order of the ids, starting after
+//afterID. Pass the last id of a page as afterID to get the next one, so that
+//paging doesn't read the earlier pages again.
+func (s *Storage) GetUnprocessedUserIDsAfter(afterID int64, limit int) []int64 {
+	var results []int64
+	s.queryScreenNamesOrIDs("SELECT user_id from userids where processed=0 AND user_id > ? ORDER BY user_id LIMIT ?", &results, afterID, limit)
+	return results
+}
+
+//GetUserIDsAfter gets up to limit of the user ids from the `userids` table
+//that have already been processed, paged like GetUnprocessedUserIDsAfter.
+func (s *Storage) GetUserIDsAfter(afterID int64, limit int) []int64 {
+	var results []int64
+	s.queryScreenNamesOrIDs("SELECT user_id from userids where processed=1 AND user_id > ? ORDER BY user_id LIMIT ?", &results, afterID, limit)
+	return results
+}
+
+//GetUnprocessedScreenNamesAfter gets up to limit of the screen names from the
+//`screennames` table that are yet to be processed, in order, starting after
+//the screen name after.
+func (s *Storage) GetUnprocessedScreenNamesAfter(after string, limit int) []string {
+	var results []string
+	s.queryScreenNamesOrIDs("SELECT screen_name from screennames where processed=0 AND screen_name > ? ORDER BY screen_name LIMIT ?", &results, after, limit)
+	return results
+}
+
+//GetAcceptedUserIDsAfter gets up to limit of the user ids from the `users`
+//table that the filtering function accepted, paged like GetUnprocessedUserIDsAfter.
+func (s *Storage) GetAcceptedUserIDsAfter(afterID int64, limit int) []int64 {
+	var results []int64
+	s.queryScreenNamesOrIDs("SELECT user_id from users where accepted=1 AND user_id > ? ORDER BY user_id LIMIT ?", &results, afterID, limit)
+	return results
+}
+
 //GetAcceptedUserIDs gets user ids from the `users` table for whom the user filtering
 //function has marked them as accepted for further processing
 func (s *Storage) GetAcceptedUserIDs() []int64 {
@@ -261,11 +981,55 @@ func (s *Storage) GetAcceptedUserIDs() []int64 {
 	return results
 }
 
-//GetUserByScreenNameOrID gets the UserRow for the given screenName or ID
+//CountUsers returns the number of rows in the `users` table
+func (s *Storage) CountUsers() int {
+	return s.count("SELECT COUNT(*) FROM users")
+}
+
+func (s *Storage) count(query string, args ...interface{}) int {
+	var count int
+	err := s.db.QueryRow(query, args...).Scan(&count)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return count
+}
+
+//CountUnprocessedScreenNames returns the number of screen names in the `screennames` table yet to be processed
+func (s *Storage) CountUnprocessedScreenNames() int {
+	return s.count("SELECT COUNT(*) FROM screennames where processed=0")
+}
+
+//CountUnprocessedUserIDs returns the number of user ids in the `userids` table yet to be processed
+func (s *Storage) CountUnprocessedUserIDs() int {
+	return s.count("SELECT COUNT(*) FROM userids where processed=0")
+}
+
+//GetUserByScreenNameOrID gets the UserRow for the given screenName or ID,
+//or nil if the user is not stored. Of the users stored with the same screen
+//name, the one stored last is returned.
 func (s *Storage) GetUserByScreenNameOrID(screenNameOrID interface{}) *UserRow {
+	u, err := s.LookupUser(screenNameOrID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil
+	case err != nil:
+		log.Fatal(err)
+	}
+	return u
+}
+
+//LookupUser gets the UserRow for the given screenName or ID. Screen names
+//can be reused by different accounts, so of the users stored with the
+//screen name the one stored last is returned, see GetUserIDsByScreenName.
+//The error matches ErrNotFound if the user is not stored.
+func (s *Storage) LookupUser(screenNameOrID interface{}) (*UserRow, error) {
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
 	var u UserRow
 	query := `SELECT user_id, 
-					 screen_name,
+					 COALESCE(screen_name, ''),
 					 description,
 					 last_looked_at,
 					 latest_tweet_id,
@@ -274,9 +1038,14 @@ func (s *Storage) GetUserByScreenNameOrID(screenNameOrID interface{}) *UserRow {
 					 protected,
 					 processed,
 					 accepted,
+					 skip_reason,
+					 verified,
+					 failures,
+					 tier,
 					 blob
 				FROM users
-				WHERE %s=?`
+				WHERE %s=?
+				ORDER BY profile_refreshed_at DESC LIMIT 1`
 
 	var row *sql.Row
 
@@ -300,46 +1069,92 @@ func (s *Storage) GetUserByScreenNameOrID(screenNameOrID interface{}) *UserRow {
 		&u.Protected,
 		&u.Processed,
 		&u.Accepted,
+		&u.SkipReason,
+		&u.Verified,
+		&u.Failures,
+		&u.Tier,
 		&u.Blob)
 
-	switch {
-	case err == sql.ErrNoRows:
-		return nil
-	case err != nil:
-		log.Fatal(err)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user %v: %w", screenNameOrID, ErrNotFound)
 	}
-	return &u
-
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
 }
 
 //MarkUserLatestTweetsCollected updates the `last_looked_at` timestamp and the `latest_tweet_id` for
-//the given user in the `users` table
+//the given user in the `users` table. Updates are coalesced, see queueCheckpoint.
 func (s *Storage) MarkUserLatestTweetsCollected(userID int64, lastLookedAt, latestTweetID int64) {
-	chQueryArgs <- &queryArgs{"UPDATE users SET last_looked_at=?, latest_tweet_id=? where user_id=?", []interface{}{lastLookedAt, latestTweetID, userID}}
+	queueCheckpoint(fmt.Sprintf("latest_tweet_id %d", userID),
+		&queryArgs{"UPDATE users SET last_looked_at=?, latest_tweet_id=? where user_id=?", []interface{}{lastLookedAt, latestTweetID, userID}})
 }
 
 //MarkUserLatestFriendsCollected sets the `latest_following_id` to the latest id of the users given userID
-//is following
+//is following. Updates are coalesced, see queueCheckpoint.
 func (s *Storage) MarkUserLatestFriendsCollected(userID, latestFriendID int64) {
-	chQueryArgs <- &queryArgs{"UPDATE users SET latest_following_id=? where user_id=?", []interface{}{latestFriendID, userID}}
+	queueCheckpoint(fmt.Sprintf("latest_following_id %d", userID),
+		&queryArgs{"UPDATE users SET latest_following_id=? where user_id=?", []interface{}{latestFriendID, userID}})
 }
 
-//MarkUserLatestFollowersCollected sets the `latest_follower_id` to the latest id of the followers collected
+//MarkUserLatestFollowersCollected sets the `latest_follower_id` to the latest id of the followers collected.
+//Updates are coalesced, see queueCheckpoint.
 func (s *Storage) MarkUserLatestFollowersCollected(userID, latestFollowerID int64) {
-	chQueryArgs <- &queryArgs{"UPDATE users SET latest_follower_id=? where user_id=?", []interface{}{latestFollowerID, userID}}
+	queueCheckpoint(fmt.Sprintf("latest_follower_id %d", userID),
+		&queryArgs{"UPDATE users SET latest_follower_id=? where user_id=?", []interface{}{latestFollowerID, userID}})
 }
 
-//MarkUserProcessed sets the `processed` and the `accepted` flags for the user in the `users` table
+//MarkUserProcessed sets the `processed` and the `accepted` flags for the user
+//in the `users` table. Accepted users are collected in full, see MarkUserTier.
 func (s *Storage) MarkUserProcessed(ID int64, processed, accepted bool) {
-	chQueryArgs <- &queryArgs{"UPDATE users SET processed=?, accepted=? where user_id=?", []interface{}{processed, accepted, ID}}
+	tier := TierNone
+	if accepted {
+		tier = TierFull
+	}
+	s.MarkUserTier(ID, processed, tier)
+}
+
+//MarkUserSkipped records in the `skip_reason` column of the `users` table why
+//the collection of the user's tweets, friends or followers was skipped
+func (s *Storage) MarkUserSkipped(userID int64, reason string) {
+	chPriorityArgs <- &queryArgs{"UPDATE users SET skip_reason=? where user_id=?", []interface{}{reason, userID}}
+}
+
+//MarkUserFailures sets the number of times in a row Twitter refused the
+//phase's collection for the user in the `user_failures` table, and the most
+//of any phase in the `failures` column of the `users` table
+func (s *Storage) MarkUserFailures(userID int64, phase string, failures int) {
+	chPriorityArgs <- &queryArgs{"INSERT OR REPLACE INTO user_failures (user_id, phase, failures) VALUES (?, ?, ?)",
+		[]interface{}{userID, phase, failures}}
+	chPriorityArgs <- &queryArgs{`UPDATE users SET failures=(SELECT MAX(failures) FROM user_failures WHERE user_id=?)
+		WHERE user_id=?`, []interface{}{userID, userID}}
+}
+
+//ClearUserFailures forgets the refusals of the collection for the user in
+//every phase
+func (s *Storage) ClearUserFailures(userID int64) {
+	chPriorityArgs <- &queryArgs{"DELETE FROM user_failures WHERE user_id=?", []interface{}{userID}}
+	chPriorityArgs <- &queryArgs{"UPDATE users SET failures=0 WHERE user_id=?", []interface{}{userID}}
+}
+
+//GetUserFailures gets the number of times in a row Twitter refused the
+//phase's collection for the user from the `user_failures` table
+func (s *Storage) GetUserFailures(userID int64, phase string) int {
+	var failures int
+	err := s.db.QueryRow("SELECT failures FROM user_failures WHERE user_id=? AND phase=?", userID, phase).Scan(&failures)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Fatal(err)
+	}
+	return failures
 }
 
 //MarkUserIDProcessed sets the `processed` flag for the given user id in the `userids` table
 func (s *Storage) MarkUserIDProcessed(ID int64, processed bool) {
-	chQueryArgs <- &queryArgs{"UPDATE userids SET processed=? where user_id=?", []interface{}{processed, ID}}
+	chPriorityArgs <- &queryArgs{"UPDATE userids SET processed=? where user_id=?", []interface{}{processed, ID}}
 }
 
 //MarkScreenNameProcessed sets the `processed` flag for the given screenName in the `screennames` table
 func (s *Storage) MarkScreenNameProcessed(screenName string, processed bool) {
-	chQueryArgs <- &queryArgs{"UPDATE screennames SET processed=? where screen_name=?", []interface{}{processed, screenName}}
+	chPriorityArgs <- &queryArgs{"UPDATE screennames SET processed=? where screen_name=?", []interface{}{processed, screenName}}
 }
@@ -0,0 +1,185 @@
+package callosum
+
+import (
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//tailMaxBackoff is the most times the tail interval a user who rarely posts
+//waits between two polls
+const tailMaxBackoff = 60
+
+//tailRateWindow is how far back the tweets are counted to estimate how
+//often a user posts
+const tailRateWindow = 7 * 24 * time.Hour
+
+//timelineSince is implemented by APIs that can get the tweets of a timeline
+//posted after a tweet
+type timelineSince interface {
+	GetUserTimelineSince(screenNameOrID interface{}, sinceID int64) (Tweets, error)
+}
+
+//GetUserTimelineSince makes one API request to the user's timeline for the
+//tweets posted after sinceID, up to a page of them, newest first.
+func (n *Network) GetUserTimelineSince(screenNameOrID interface{}, sinceID int64) (Tweets, error) {
+	v := url.Values{}
+
+	n.addscreenNameOrID(&v, screenNameOrID)
+	v.Add("trim_user", "true")
+	v.Add("count", strconv.Itoa(timelinePageSize))
+	v.Add("since_id", strconv.FormatInt(sinceID, 10))
+	return n.getTweets("statuses/user_timeline", v)
+}
+
+//WithTailing keeps StartCollection polling the timelines of the accepted
+//users once the collection is complete, instead of returning, so that the
+//corpus stays within minutes of what they post, see TailTweets. interval is
+//the time between two polls of the users who post the most.
+func WithTailing(interval time.Duration) Option {
+	return func(t *TwitterCollector) {
+		t.tailInterval = interval
+	}
+}
+
+//tail polls the timelines with TailTweets every tail interval, forever.
+func (t *TwitterCollector) tail() {
+	t.logger.Printf("tailing the timelines of the accepted users every %s", t.tailInterval)
+	Repeat(func() {
+		t.TailTweets(t.tailInterval)
+		t.recordAPIUsage()
+	}, t.tailInterval)
+}
+
+//TailTweets polls the timelines of the accepted users of TierTweets and
+//above that are due, for the tweets they posted since their latest stored
+//tweet, and returns the number of new tweets. How often a user is due
+//depends on how often they posted in the last week: users who post at least
+//every interval are due every interval, users who post less wait as long as
+//they usually do between two tweets, up to tailMaxBackoff intervals. The
+//due users are read userIDsBatchSize at a time, then polled, those who post
+//the most first. A timeline is polled with a single request
+//using since_id where the API supports it, and collected like CollectTweets
+//when more than a page of tweets was posted.
+func (t *TwitterCollector) TailTweets(interval time.Duration) int {
+	var due []tailCandidate
+	var afterID int64
+	for {
+		batch, lastID := t.s.getDueTailUsers(interval, afterID, userIDsBatchSize)
+		if lastID == 0 {
+			break
+		}
+		afterID = lastID
+		due = append(due, batch...)
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].Rate > due[j].Rate })
+
+	userIDs := make([]int64, len(due))
+	latest := make(map[int64]int64, len(due))
+	for index, u := range due {
+		userIDs[index] = u.ID
+		latest[u.ID] = u.LatestTweetID
+	}
+	collected := t.eachUser(userIDs, func(userID int64) int {
+		count := t.tailUser(userID, latest[userID])
+		t.s.MarkUserCollectedAt(userID, collectedAtColumns[PhaseTweets], time.Now().Unix())
+		return count
+	})
+	if collected > 0 {
+		t.logger.Printf("tailed %d new tweets of %d users", collected, len(userIDs))
+	}
+	return collected
+}
+
+//tailCandidate is an accepted user considered by TailTweets, with the number of
+//tweets per day they posted in the last tailRateWindow
+type tailCandidate struct {
+	ID            int64
+	LatestTweetID int64
+	Rate          float64
+}
+
+//tailBackoff is how long a user posting rate tweets per day waits between
+//two polls when the users posting the most are polled every interval
+func tailBackoff(rate float64, interval time.Duration) time.Duration {
+	backoff := tailMaxBackoff * interval
+	if rate > 0 && time.Duration(float64(24*time.Hour)/rate) < backoff {
+		backoff = time.Duration(float64(24*time.Hour) / rate)
+	}
+	if backoff < interval {
+		backoff = interval
+	}
+	return backoff
+}
+
+//getDueTailUsers gets the users due to be polled by TailTweets among up to
+//limit accepted users of TierTweets and above after afterID, with their
+//posting rates going by the stored tweets. It also returns the last ID
+//read, 0 when there are no more users.
+func (s *Storage) getDueTailUsers(interval time.Duration, afterID int64, limit int) ([]tailCandidate, int64) {
+	now := time.Now()
+	days := tailRateWindow.Hours() / 24
+	rows, err := s.db.Query(`SELECT user_id, latest_tweet_id, tweets_collected_at,
+			(SELECT COUNT(*) FROM tweets WHERE tweets.user_id = users.user_id AND tweets.created_at >= ?)
+		FROM users WHERE accepted=1 AND tier >= ? AND user_id > ?
+		ORDER BY user_id LIMIT ?`, now.Add(-tailRateWindow).Unix(), TierTweets, afterID, limit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	var due []tailCandidate
+	var lastID int64
+	for rows.Next() {
+		var u tailCandidate
+		var collectedAt int64
+		var count int
+		err = rows.Scan(&u.ID, &u.LatestTweetID, &collectedAt, &count)
+		if err != nil {
+			log.Fatal(err)
+		}
+		lastID = u.ID
+		u.Rate = float64(count) / days
+		if now.Sub(time.Unix(collectedAt, 0)) >= tailBackoff(u.Rate, interval) {
+			due = append(due, u)
+		}
+	}
+	return due, lastID
+}
+
+//tailUser gets the tweets userID posted after latestTweetID with one request
+//using since_id, and falls back to CollectTweets when the API doesn't
+//support it, the user has no tweets stored or more than a page was posted.
+func (t *TwitterCollector) tailUser(userID, latestTweetID int64) int {
+	s, ok := t.n.(timelineSince)
+	if !ok || latestTweetID == 0 {
+		return t.CollectTweets(userID, latestTweetID)
+	}
+	if t.skipProtected(userID, PhaseTweets) {
+		return 0
+	}
+	t.waitForBudget()
+	t.waitForWriter()
+	t.countRequest(UsageTail, "statuses/user_timeline")
+	tweets, err := s.GetUserTimelineSince(userID, latestTweetID)
+	if err == nil {
+		t.consume(len(tweets))
+		if len(tweets) >= timelinePageSize {
+			//CollectTweets counts the attempt
+			return t.CollectTweets(userID, latestTweetID)
+		}
+	}
+	if t.skipUnavailable(userID, PhaseTweets, err) {
+		return 0
+	}
+	for _, tweet := range tweets {
+		t.storeTweet(userID, tweet)
+	}
+	t.s.AddUserCollected(userID, len(tweets), 0)
+	if len(tweets) > 0 {
+		t.s.MarkUserLatestTweetsCollected(userID, time.Now().UTC().Unix(), tweets[0].ID)
+	}
+	return len(tweets)
+}
@@ -0,0 +1,123 @@
+package callosum
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"log"
+	"strings"
+)
+
+//TextOptions configures the normalization of tweet text by CleanText and
+//the documents written by ExportText.
+type TextOptions struct {
+	StripURLs     bool
+	StripMentions bool
+	//StripHashtagSigns keeps the hashtag's word but drops the #
+	StripHashtagSigns bool
+	Lowercase         bool
+
+	//PerUser writes one document per user, their tweets joined in
+	//chronological order, instead of one document per tweet.
+	PerUser bool
+	//AcceptedOnly leaves out the tweets of users not accepted by the filter.
+	AcceptedOnly bool
+	//SkipRetweets leaves out tweets starting with "RT @".
+	SkipRetweets bool
+	//SkipNearDuplicates leaves out tweets marked by MarkNearDuplicates.
+	SkipNearDuplicates bool
+	//Languages keeps only tweets in these languages, when not empty.
+	Languages []string
+	//Partition keeps only the tweets of users assigned to this partition by
+	//SplitUsers, when not empty.
+	Partition string
+}
+
+//CleanText normalizes a tweet's text for tokenizers: HTML entities are
+//unescaped, URLs, mentions and hashtag signs are dropped as configured, and
+//all whitespace, including newlines, is collapsed into single spaces.
+func CleanText(text string, opts TextOptions) string {
+	text = html.UnescapeString(text)
+	if opts.StripURLs {
+		text = urlPattern.ReplaceAllString(text, " ")
+	}
+	if opts.StripMentions {
+		text = mentionPattern.ReplaceAllString(text, " ")
+	}
+	if opts.StripHashtagSigns {
+		text = strings.Replace(text, "#", "", -1)
+	}
+	if opts.Lowercase {
+		text = strings.ToLower(text)
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+//ExportText writes the cleaned text of the stored tweets to w, one document
+//per line, for feeding into tokenizers and language model training pipelines.
+//Tweets left empty by the cleaning, and deleted tweets, are skipped.
+func (s *Storage) ExportText(w io.Writer, opts TextOptions) error {
+	query := `SELECT tweets.user_id, tweets."desc" FROM tweets`
+	conditions := []string{"tweets.status != '" + TweetDeleted + "'"}
+	var args []interface{}
+	if opts.AcceptedOnly {
+		query += " JOIN users ON users.user_id = tweets.user_id"
+		conditions = append(conditions, "users.accepted=1")
+	}
+	if opts.Partition != "" {
+		query += " JOIN partitions ON partitions.user_id = tweets.user_id"
+		conditions = append(conditions, "partitions.partition=?")
+		args = append(args, opts.Partition)
+	}
+	if opts.SkipRetweets {
+		conditions = append(conditions, `tweets."desc" NOT LIKE 'RT @%'`)
+	}
+	if opts.SkipNearDuplicates {
+		conditions = append(conditions, "tweets.near_duplicate_of=0")
+	}
+	if len(opts.Languages) > 0 {
+		conditions = append(conditions, "tweets.langugage IN (?"+strings.Repeat(", ?", len(opts.Languages)-1)+")")
+		for _, language := range opts.Languages {
+			args = append(args, language)
+		}
+	}
+	query += " WHERE " + strings.Join(conditions, " AND ")
+	query += " ORDER BY tweets.user_id, tweets.created_at, tweets.tweet_id"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	bw := bufio.NewWriter(w)
+	var document []string
+	var documentUserID int64
+	flush := func() {
+		if len(document) > 0 {
+			bw.WriteString(strings.Join(document, " "))
+			bw.WriteByte('\n')
+			document = document[:0]
+		}
+	}
+
+	for rows.Next() {
+		var userID int64
+		var text string
+		err = rows.Scan(&userID, &text)
+		if err != nil {
+			log.Fatal(err)
+		}
+		text = CleanText(text, opts)
+		if text == "" {
+			continue
+		}
+		if !opts.PerUser || userID != documentUserID {
+			flush()
+		}
+		documentUserID = userID
+		document = append(document, text)
+	}
+	flush()
+	return bw.Flush()
+}
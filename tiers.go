@@ -0,0 +1,114 @@
+package callosum
+
+import (
+	"log"
+	"sort"
+)
+
+//Tier is how much of a user the collector collects, so that the API budget
+//is spent on the most relevant accounts. Every tier includes the ones below it.
+type Tier int
+
+//The collection tiers
+const (
+	//TierNone users are stored but not accepted, as users a FilterUser rejects.
+	TierNone Tier = iota
+	//TierProfile users are accepted, but only their profile is collected.
+	TierProfile
+	//TierTweets users also get their tweets collected.
+	TierTweets
+	//TierFull users also get their friends and followers collected, as
+	//users a FilterUser accepts.
+	TierFull
+)
+
+//TierUser is a scoring function, used in place of a FilterUser, that takes
+//the byte blob with Twitter's JSON response for a user and returns the
+//tier the user is collected with.
+type TierUser func(blob []byte) Tier
+
+//tier applies the TierUser set with WithTiers to the user, or else the
+//filter function, which collects accepted users in full.
+func (t *TwitterCollector) tier(blob []byte) Tier {
+	if t.tierUser != nil {
+		return t.tierUser(blob)
+	}
+	if t.filterUser(blob) {
+		return TierFull
+	}
+	return TierNone
+}
+
+//WithTiers sets the scoring function applied to every collected user, in
+//place of the filter function. Users are accepted when their tier is above
+//TierNone, and the phases only collect the users of the tiers they need:
+//the tweets of TierTweets users and above, and the friends and followers of
+//TierFull users.
+func WithTiers(tu TierUser) Option {
+	return func(t *TwitterCollector) {
+		t.tierUser = tu
+	}
+}
+
+//eachUserInTier calls eachUser for the accepted users of the tier or above,
+//reading them userIDsBatchSize at a time.
+func (t *TwitterCollector) eachUserInTier(tier Tier, collect func(userID int64) int) int {
+	var total int
+	var afterID int64
+	for {
+		userIDs := t.s.GetUserIDsInTierAfter(tier, afterID, userIDsBatchSize)
+		if len(userIDs) == 0 {
+			return total
+		}
+		afterID = userIDs[len(userIDs)-1]
+		total += t.eachUser(userIDs, collect)
+	}
+}
+
+//GetUserIDsInTierAfter gets up to limit of the ids of the accepted users of
+//the tier, or above, from the `users` table, paged like GetUnprocessedUserIDsAfter.
+func (s *Storage) GetUserIDsInTierAfter(tier Tier, afterID int64, limit int) []int64 {
+	var results []int64
+	s.queryScreenNamesOrIDs("SELECT user_id from users where accepted=1 AND tier >= ? AND user_id > ? ORDER BY user_id LIMIT ?", &results, tier, afterID, limit)
+	return results
+}
+
+//MarkUserTier sets the `processed` flag and the `tier` of the user in the
+//`users` table, and the `accepted` flag for tiers above TierNone.
+func (s *Storage) MarkUserTier(ID int64, processed bool, tier Tier) {
+	chPriorityArgs <- &queryArgs{"UPDATE users SET processed=?, accepted=?, tier=? where user_id=?",
+		[]interface{}{processed, tier > TierNone, tier, ID}}
+}
+
+var tierUsers = map[string]TierUser{}
+
+//RegisterTiers makes a TierUser available under the given name so that it
+//can be referred to from a Config file.
+func RegisterTiers(name string, tu TierUser) {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	if _, ok := tierUsers[name]; ok {
+		log.Fatalf("tiers %q are already registered", name)
+	}
+	tierUsers[name] = tu
+}
+
+//LookupTiers returns the TierUser registered under name and whether it was found.
+func LookupTiers(name string) (TierUser, bool) {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	tu, ok := tierUsers[name]
+	return tu, ok
+}
+
+//TiersNames returns the names of all the registered TierUsers in sorted order.
+func TiersNames() []string {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	var names []string
+	for name := range tierUsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
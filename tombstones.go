@@ -0,0 +1,88 @@
+package callosum
+
+import (
+	"time"
+)
+
+//RecollectTweets gets the user's whole timeline from Twitter again, as far
+//back as Twitter returns it, and stores the tweets that are not stored yet.
+//Stored tweets of the user within the span of the timeline that Twitter no
+//longer returns are marked deleted, with the time in `deleted_at`, see
+//MarkTweetStatus, and stored tweets marked deleted that it returns again
+//are marked available. Earlier versions of edited tweets are not on the timeline
+//and are left as they are. RecollectTweets returns the number of new
+//tweets and of tweets found deleted.
+func (t *TwitterCollector) RecollectTweets(userID int64) (collected, deleted int) {
+	if t.skipProtected(userID, PhaseTweets) {
+		return 0, 0
+	}
+	seen := make(map[int64]bool)
+	var newestTweetID, oldestTweetID int64
+	_, err := t.StreamTweets(userID, 0, func(tweets Tweets) {
+		if newestTweetID == 0 {
+			newestTweetID = tweets[0].ID
+		}
+		oldestTweetID = tweets[len(tweets)-1].ID
+		for _, tweet := range tweets {
+			seen[tweet.ID] = true
+			if !t.s.HasTweet(tweet.ID) {
+				t.storeTweet(userID, tweet)
+				collected++
+			}
+		}
+	})
+	t.s.AddUserCollected(userID, collected, 0)
+	if t.skipUnavailable(userID, PhaseTweets, err) || newestTweetID == 0 {
+		return collected, 0
+	}
+
+	checkedAt := time.Now().UTC().Unix()
+	for _, ID := range t.s.getTimelineTweetIDs(userID, oldestTweetID, newestTweetID) {
+		if !seen[ID] {
+			t.s.MarkTweetStatus(ID, TweetDeleted, checkedAt)
+			deleted++
+		}
+	}
+	for _, ID := range t.s.getDeletedTweetIDs(userID, oldestTweetID, newestTweetID) {
+		if seen[ID] {
+			t.s.MarkTweetStatus(ID, TweetAvailable, checkedAt)
+		}
+	}
+	if u := t.s.GetUserByScreenNameOrID(userID); u != nil && newestTweetID > u.LatestTweetID {
+		t.s.MarkUserLatestTweetsCollected(userID, checkedAt, newestTweetID)
+	}
+	t.logger.Printf("recollected the timeline of user %d, %d new and %d deleted tweets", userID, collected, deleted)
+	return collected, deleted
+}
+
+//RecollectAllTweets runs RecollectTweets for the users of TierTweets and
+//above, see WithTiers, and returns the number of tweets found deleted.
+func (t *TwitterCollector) RecollectAllTweets() int {
+	return t.eachUserInTier(TierTweets, func(userID int64) int {
+		_, deleted := t.RecollectTweets(userID)
+		return deleted
+	})
+}
+
+//getTimelineTweetIDs gets the IDs of the user's stored tweets between
+//oldestID and newestID, which are not already marked deleted and are not
+//earlier versions of edited tweets.
+func (s *Storage) getTimelineTweetIDs(userID, oldestID, newestID int64) []int64 {
+	var results []int64
+	s.queryScreenNamesOrIDs(`SELECT tweet_id FROM tweets
+		WHERE user_id=? AND tweet_id BETWEEN ? AND ? AND status != ?
+		AND tweet_id NOT IN (SELECT tweet_id FROM edits e
+			WHERE version < (SELECT MAX(version) FROM edits WHERE initial_tweet_id=e.initial_tweet_id))
+		ORDER BY tweet_id`, &results, userID, oldestID, newestID, TweetDeleted)
+	return results
+}
+
+//getDeletedTweetIDs gets the IDs of the user's stored tweets between oldestID
+//and newestID that are marked deleted.
+func (s *Storage) getDeletedTweetIDs(userID, oldestID, newestID int64) []int64 {
+	var results []int64
+	s.queryScreenNamesOrIDs(`SELECT tweet_id FROM tweets
+		WHERE user_id=? AND tweet_id BETWEEN ? AND ? AND status=?
+		ORDER BY tweet_id`, &results, userID, oldestID, newestID, TweetDeleted)
+	return results
+}
@@ -0,0 +1,420 @@
+package callosum
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"strconv"
+	"time"
+)
+
+//TwarcFormat is a flavor of twarc's JSON lines output
+type TwarcFormat int
+
+//The twarc flavors. TwarcV1 is twarc's output for the v1.1 API, one tweet
+//object per line. TwarcV2 is twarc2's output for the v2 API, one response
+//page per line with the tweets in data and their authors and referenced
+//tweets in includes.
+const (
+	TwarcV1 TwarcFormat = iota
+	TwarcV2
+)
+
+//twarcPageSize is the number of tweets per line of TwarcV2 exports, the
+//page size of twarc2's timeline requests
+const twarcPageSize = 100
+
+const v2TimeLayout = "2006-01-02T15:04:05.000Z"
+
+type v2Tweet struct {
+	ID               string              `json:"id"`
+	Text             string              `json:"text"`
+	CreatedAt        string              `json:"created_at,omitempty"`
+	Lang             string              `json:"lang,omitempty"`
+	AuthorID         string              `json:"author_id,omitempty"`
+	InReplyToUserID  string              `json:"in_reply_to_user_id,omitempty"`
+	ReferencedTweets []v2ReferencedTweet `json:"referenced_tweets,omitempty"`
+	Entities         *struct {
+		Mentions []v2Mention `json:"mentions,omitempty"`
+	} `json:"entities,omitempty"`
+	PublicMetrics       map[string]int `json:"public_metrics,omitempty"`
+	EditHistoryTweetIDs []string       `json:"edit_history_tweet_ids,omitempty"`
+	//Author is set in twarc2's flattened output
+	Author *v2User `json:"author,omitempty"`
+}
+
+type v2ReferencedTweet struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type v2Mention struct {
+	ID       string `json:"id,omitempty"`
+	Username string `json:"username"`
+}
+
+type v2User struct {
+	ID            string         `json:"id"`
+	Username      string         `json:"username"`
+	Name          string         `json:"name"`
+	Description   string         `json:"description"`
+	Location      string         `json:"location,omitempty"`
+	CreatedAt     string         `json:"created_at,omitempty"`
+	Protected     bool           `json:"protected"`
+	Verified      bool           `json:"verified"`
+	PublicMetrics map[string]int `json:"public_metrics,omitempty"`
+}
+
+type v2Page struct {
+	Data     []v2Tweet `json:"data"`
+	Includes struct {
+		Users  []v2User  `json:"users,omitempty"`
+		Tweets []v2Tweet `json:"tweets,omitempty"`
+	} `json:"includes"`
+	Twarc map[string]interface{} `json:"__twarc,omitempty"`
+}
+
+//v1 tweet and user objects as stored, with the fields converted to and from v2
+type v1Tweet struct {
+	ID                int64           `json:"id"`
+	Text              string          `json:"text"`
+	FullText          string          `json:"full_text"`
+	CreatedAt         string          `json:"created_at"`
+	Lang              string          `json:"lang"`
+	User              v1User          `json:"user"`
+	InReplyToStatusID int64           `json:"in_reply_to_status_id"`
+	InReplyToUserID   int64           `json:"in_reply_to_user_id"`
+	QuotedStatusID    int64           `json:"quoted_status_id"`
+	RetweetedStatus   json.RawMessage `json:"retweeted_status"`
+	QuotedStatus      json.RawMessage `json:"quoted_status"`
+	RetweetCount      int             `json:"retweet_count"`
+	FavoriteCount     int             `json:"favorite_count"`
+	Entities          struct {
+		UserMentions []struct {
+			ID         int64  `json:"id"`
+			ScreenName string `json:"screen_name"`
+		} `json:"user_mentions"`
+	} `json:"entities"`
+}
+
+type v1User struct {
+	ID             int64  `json:"id"`
+	ScreenName     string `json:"screen_name"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	Location       string `json:"location"`
+	CreatedAt      string `json:"created_at"`
+	Protected      bool   `json:"protected"`
+	Verified       bool   `json:"verified"`
+	FollowersCount int    `json:"followers_count"`
+	FriendsCount   int    `json:"friends_count"`
+	StatusesCount  int    `json:"statuses_count"`
+	ListedCount    int    `json:"listed_count"`
+}
+
+//convertTime converts between the timestamp layouts of the v1.1 and v2 APIs,
+//returning "" for timestamps it can't parse
+func convertTime(timestamp, from, to string) string {
+	t, err := time.Parse(from, timestamp)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(to)
+}
+
+func (u *v1User) v2() v2User {
+	return v2User{
+		ID:          strconv.FormatInt(u.ID, 10),
+		Username:    u.ScreenName,
+		Name:        u.Name,
+		Description: u.Description,
+		Location:    u.Location,
+		CreatedAt:   convertTime(u.CreatedAt, time.RubyDate, v2TimeLayout),
+		Protected:   u.Protected,
+		Verified:    u.Verified,
+		PublicMetrics: map[string]int{
+			"followers_count": u.FollowersCount,
+			"following_count": u.FriendsCount,
+			"tweet_count":     u.StatusesCount,
+			"listed_count":    u.ListedCount,
+		},
+	}
+}
+
+func (t *v1Tweet) v2() v2Tweet {
+	text := t.Text
+	if t.FullText != "" {
+		text = t.FullText
+	}
+	tweet := v2Tweet{
+		ID:        strconv.FormatInt(t.ID, 10),
+		Text:      text,
+		CreatedAt: convertTime(t.CreatedAt, time.RubyDate, v2TimeLayout),
+		Lang:      t.Lang,
+		AuthorID:  strconv.FormatInt(t.User.ID, 10),
+		PublicMetrics: map[string]int{
+			"retweet_count": t.RetweetCount,
+			"like_count":    t.FavoriteCount,
+		},
+	}
+	if t.InReplyToUserID != 0 {
+		tweet.InReplyToUserID = strconv.FormatInt(t.InReplyToUserID, 10)
+	}
+	var retweeted v1Tweet
+	if len(t.RetweetedStatus) > 0 && json.Unmarshal(t.RetweetedStatus, &retweeted) == nil && retweeted.ID != 0 {
+		tweet.ReferencedTweets = append(tweet.ReferencedTweets, v2ReferencedTweet{"retweeted", strconv.FormatInt(retweeted.ID, 10)})
+	}
+	if t.QuotedStatusID != 0 {
+		tweet.ReferencedTweets = append(tweet.ReferencedTweets, v2ReferencedTweet{"quoted", strconv.FormatInt(t.QuotedStatusID, 10)})
+	}
+	if t.InReplyToStatusID != 0 {
+		tweet.ReferencedTweets = append(tweet.ReferencedTweets, v2ReferencedTweet{"replied_to", strconv.FormatInt(t.InReplyToStatusID, 10)})
+	}
+	for _, m := range t.Entities.UserMentions {
+		if tweet.Entities == nil {
+			tweet.Entities = &struct {
+				Mentions []v2Mention `json:"mentions,omitempty"`
+			}{}
+		}
+		tweet.Entities.Mentions = append(tweet.Entities.Mentions, v2Mention{strconv.FormatInt(m.ID, 10), m.ScreenName})
+	}
+	return tweet
+}
+
+//ExportTwarc writes the stored tweets to w as twarc compatible JSON lines,
+//so the corpus can be used with twarc and the tools built on it. Deleted
+//tweets are left out. Tweets whose blobs carry no user object other than
+//the ID get the stored user's object.
+func (s *Storage) ExportTwarc(w io.Writer, format TwarcFormat) error {
+	rows, err := s.db.Query(`SELECT tweets.blob, users.blob FROM tweets
+		LEFT JOIN users ON users.user_id = tweets.user_id
+		WHERE tweets.status != ?
+		ORDER BY tweets.tweet_id`, TweetDeleted)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	bw := bufio.NewWriter(w)
+	page := &v2Page{}
+	writePage := func() {
+		if len(page.Data) > 0 {
+			page.Twarc = map[string]interface{}{"version": "callosum", "retrieved_at": time.Now().UTC().Format(time.RFC3339)}
+			bw.Write(mustMarshal(page))
+			bw.WriteByte('\n')
+		}
+		page = &v2Page{}
+	}
+	seenUsers := make(map[string]bool)
+	addUser := func(u v1User) {
+		user := u.v2()
+		if u.ID != 0 && !seenUsers[user.ID] {
+			seenUsers[user.ID] = true
+			page.Includes.Users = append(page.Includes.Users, user)
+		}
+	}
+
+	for rows.Next() {
+		var tweetBlob, userBlob []byte
+		err = rows.Scan(&tweetBlob, &userBlob)
+		if err != nil {
+			log.Fatal(err)
+		}
+		blob := tweetBlob
+		var t v1Tweet
+		if json.Unmarshal(tweetBlob, &t) != nil {
+			continue
+		}
+		if t.User.ScreenName == "" && userBlob != nil {
+			var fields map[string]json.RawMessage
+			if json.Unmarshal(tweetBlob, &fields) == nil {
+				fields["user"] = userBlob
+				blob = mustMarshal(fields)
+				json.Unmarshal(userBlob, &t.User)
+			}
+		}
+
+		if format == TwarcV1 {
+			bw.Write(blob)
+			bw.WriteByte('\n')
+			continue
+		}
+
+		page.Data = append(page.Data, t.v2())
+		addUser(t.User)
+		for _, referenced := range []json.RawMessage{t.RetweetedStatus, t.QuotedStatus} {
+			var r v1Tweet
+			if len(referenced) > 0 && json.Unmarshal(referenced, &r) == nil && r.ID != 0 {
+				page.Includes.Tweets = append(page.Includes.Tweets, r.v2())
+				addUser(r.User)
+			}
+		}
+		if len(page.Data) == twarcPageSize {
+			writePage()
+			seenUsers = make(map[string]bool)
+		}
+	}
+	if format == TwarcV2 {
+		writePage()
+	}
+	return bw.Flush()
+}
+
+//ImportTwarc stores the tweets and users of twarc's JSON lines output read
+//from r. Both flavors are understood: v1.1 tweet objects, as with
+//ImportJSONL, and twarc2's v2 response pages or flattened tweets, which are
+//converted to v1.1 objects as far as the v2 fields allow. The expansions of
+//v2 response pages are kept as v2 objects in their own tables, see
+//GetTweetMedia and GetTweetPoll. Large imports are followed by Analyze.
+func (t *TwitterCollector) ImportTwarc(r io.Reader) (*ImportStats, error) {
+	stats := &ImportStats{}
+	defer t.analyzeImport(stats)
+	seenUsers := make(map[int64]bool)
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			for _, converted := range v2Lines(line) {
+				t.importLine(converted, stats, seenUsers)
+			}
+			stats.Includes += t.s.storeIncludes(line)
+		}
+		if err == io.EOF {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, err
+		}
+	}
+}
+
+//v2Lines converts a line of twarc2 output into v1.1 tweet objects. Lines of
+//v1.1 objects are returned as they are.
+func v2Lines(line []byte) [][]byte {
+	var probe struct {
+		Data     json.RawMessage `json:"data"`
+		ID       json.RawMessage `json:"id"`
+		AuthorID string          `json:"author_id"`
+	}
+	if json.Unmarshal(line, &probe) != nil {
+		return [][]byte{line}
+	}
+
+	page := &v2Page{}
+	switch {
+	case len(probe.Data) > 0:
+		if json.Unmarshal(line, page) != nil {
+			return [][]byte{line}
+		}
+	case probe.AuthorID != "" && bytes.HasPrefix(bytes.TrimSpace(probe.ID), []byte(`"`)):
+		//a tweet of twarc2's flattened output
+		var tweet v2Tweet
+		if json.Unmarshal(line, &tweet) != nil {
+			return [][]byte{line}
+		}
+		page.Data = []v2Tweet{tweet}
+		if tweet.Author != nil {
+			page.Includes.Users = []v2User{*tweet.Author}
+		}
+	default:
+		return [][]byte{line}
+	}
+
+	users := make(map[string]v2User)
+	for _, u := range page.Includes.Users {
+		users[u.ID] = u
+	}
+	tweets := make(map[string]v2Tweet)
+	for _, tweet := range page.Includes.Tweets {
+		tweets[tweet.ID] = tweet
+	}
+	var lines [][]byte
+	for _, tweet := range page.Data {
+		lines = append(lines, mustMarshal(v1Object(tweet, users, tweets, true)))
+	}
+	return lines
+}
+
+func v2ID(ID string) interface{} {
+	if n, err := strconv.ParseInt(ID, 10, 64); err == nil {
+		return n
+	}
+	return nil
+}
+
+func v1UserObject(u v2User, ID string) map[string]interface{} {
+	user := map[string]interface{}{"id": v2ID(ID), "id_str": ID}
+	if u.ID == "" {
+		return user
+	}
+	user["screen_name"] = u.Username
+	user["name"] = u.Name
+	user["description"] = u.Description
+	user["location"] = u.Location
+	user["created_at"] = convertTime(u.CreatedAt, v2TimeLayout, time.RubyDate)
+	user["protected"] = u.Protected
+	user["verified"] = u.Verified
+	user["followers_count"] = u.PublicMetrics["followers_count"]
+	user["friends_count"] = u.PublicMetrics["following_count"]
+	user["statuses_count"] = u.PublicMetrics["tweet_count"]
+	user["listed_count"] = u.PublicMetrics["listed_count"]
+	return user
+}
+
+//v1Object converts a v2 tweet to a v1.1 tweet object, looking up its author
+//and, when nest is set, the tweets it references in the page's includes.
+func v1Object(tweet v2Tweet, users map[string]v2User, tweets map[string]v2Tweet, nest bool) map[string]interface{} {
+	createdAt := convertTime(tweet.CreatedAt, time.RFC3339Nano, time.RubyDate)
+	object := map[string]interface{}{
+		"id":             v2ID(tweet.ID),
+		"id_str":         tweet.ID,
+		"text":           tweet.Text,
+		"created_at":     createdAt,
+		"lang":           tweet.Lang,
+		"user":           v1UserObject(users[tweet.AuthorID], tweet.AuthorID),
+		"retweet_count":  tweet.PublicMetrics["retweet_count"],
+		"favorite_count": tweet.PublicMetrics["like_count"],
+	}
+	if tweet.InReplyToUserID != "" {
+		object["in_reply_to_user_id"] = v2ID(tweet.InReplyToUserID)
+		object["in_reply_to_user_id_str"] = tweet.InReplyToUserID
+		if u, ok := users[tweet.InReplyToUserID]; ok {
+			object["in_reply_to_screen_name"] = u.Username
+		}
+	}
+	for _, ref := range tweet.ReferencedTweets {
+		referenced, included := tweets[ref.ID]
+		switch ref.Type {
+		case "replied_to":
+			object["in_reply_to_status_id"] = v2ID(ref.ID)
+			object["in_reply_to_status_id_str"] = ref.ID
+		case "quoted":
+			object["is_quote_status"] = true
+			object["quoted_status_id"] = v2ID(ref.ID)
+			object["quoted_status_id_str"] = ref.ID
+			if included && nest {
+				object["quoted_status"] = v1Object(referenced, users, tweets, false)
+			}
+		case "retweeted":
+			if included && nest {
+				object["retweeted_status"] = v1Object(referenced, users, tweets, false)
+			}
+		}
+	}
+	var mentions []interface{}
+	if tweet.Entities != nil {
+		for _, m := range tweet.Entities.Mentions {
+			mentions = append(mentions, map[string]interface{}{"id": v2ID(m.ID), "id_str": m.ID, "screen_name": m.Username})
+		}
+	}
+	object["entities"] = map[string]interface{}{"user_mentions": mentions}
+	if len(tweet.EditHistoryTweetIDs) > 0 {
+		object["edit_history"] = map[string]interface{}{
+			"initial_tweet_id": tweet.EditHistoryTweetIDs[0],
+			"edit_tweet_ids":   tweet.EditHistoryTweetIDs,
+		}
+	}
+	return object
+}
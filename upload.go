@@ -0,0 +1,331 @@
+package callosum
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//ObjectStore is a cloud object storage target that exports and backups can
+//be uploaded to.
+type ObjectStore interface {
+	//Upload stores the contents of r under key.
+	Upload(key string, r io.Reader) error
+}
+
+//DefaultPartSize is the size of the parts of a multipart upload. S3 requires
+//parts, except the last one, to be at least 5MB.
+const DefaultPartSize = 16 << 20
+
+//S3Store uploads to an S3 compatible object store with multipart uploads
+//signed with AWS Signature Version 4. Objects are stored in Bucket under
+//Prefix. Use NewS3Store for Amazon S3 and NewGCSStore for Google Cloud Storage.
+type S3Store struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PartSize        int
+	Client          *http.Client
+}
+
+//NewS3Store returns an S3Store for an Amazon S3 bucket in region. When accessKeyID
+//is empty the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables are used.
+func NewS3Store(region, bucket, prefix, accessKeyID, secretAccessKey string) *S3Store {
+	if accessKeyID == "" {
+		accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	return &S3Store{
+		Endpoint:        fmt.Sprintf("https://s3.%s.amazonaws.com", region),
+		Region:          region,
+		Bucket:          bucket,
+		Prefix:          prefix,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		PartSize:        DefaultPartSize,
+		Client:          http.DefaultClient,
+	}
+}
+
+//NewGCSStore returns an S3Store for a Google Cloud Storage bucket, using the
+//XML API's S3 interoperability. accessKeyID and secret are a HMAC key of a
+//service account.
+func NewGCSStore(bucket, prefix, accessKeyID, secret string) *S3Store {
+	return &S3Store{
+		Endpoint:        "https://storage.googleapis.com",
+		Region:          "auto",
+		Bucket:          bucket,
+		Prefix:          prefix,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secret,
+		PartSize:        DefaultPartSize,
+		Client:          http.DefaultClient,
+	}
+}
+
+//Upload stores the contents of r under Prefix + key. Content that fits in one
+//part is uploaded with a single request, anything larger with a multipart upload.
+func (s *S3Store) Upload(key string, r io.Reader) error {
+	key = path.Join(s.Prefix, key)
+	part := make([]byte, s.PartSize)
+
+	n, err := io.ReadFull(r, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		_, err = s.do("PUT", key, nil, part[:n])
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	uploadID, err := s.createMultipartUpload(key)
+	if err != nil {
+		return err
+	}
+	var completed completeMultipartUpload
+	for partNumber := 1; n > 0; partNumber++ {
+		var etag string
+		etag, err = s.uploadPart(key, uploadID, partNumber, part[:n])
+		if err != nil {
+			break
+		}
+		completed.Parts = append(completed.Parts, completedPart{partNumber, etag})
+
+		n, err = io.ReadFull(r, part)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = nil
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = s.completeMultipartUpload(key, uploadID, completed)
+	}
+	if err != nil {
+		s.do("DELETE", key, url.Values{"uploadId": {uploadID}}, nil)
+	}
+	return err
+}
+
+type completedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+func (s *S3Store) createMultipartUpload(key string) (string, error) {
+	resp, err := s.do("POST", key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.Unmarshal(resp.body, &result)
+	return result.UploadID, err
+}
+
+func (s *S3Store) uploadPart(key, uploadID string, partNumber int, part []byte) (string, error) {
+	resp, err := s.do("PUT", key, url.Values{
+		"partNumber": {fmt.Sprint(partNumber)},
+		"uploadId":   {uploadID},
+	}, part)
+	if err != nil {
+		return "", err
+	}
+	return resp.header.Get("ETag"), nil
+}
+
+func (s *S3Store) completeMultipartUpload(key, uploadID string, completed completeMultipartUpload) error {
+	body, err := xml.Marshal(completed)
+	if err != nil {
+		return err
+	}
+	_, err = s.do("POST", key, url.Values{"uploadId": {uploadID}}, body)
+	return err
+}
+
+type s3Response struct {
+	header http.Header
+	body   []byte
+}
+
+func (s *S3Store) do(method, key string, query url.Values, body []byte) (*s3Response, error) {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = "/" + s.Bucket + "/" + key
+	u.RawPath = canonicalURI(u.Path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, u.Path, resp.Status, respBody)
+	}
+	return &s3Response{resp.Header, respBody}, nil
+}
+
+//sign adds the AWS Signature Version 4 Authorization header to req.
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+//uriEncode encodes everything but the unreserved characters, as Signature Version 4 requires.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func canonicalURI(p string) string {
+	segments := strings.Split(p, "/")
+	for i := range segments {
+		segments[i] = uriEncode(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(query url.Values) string {
+	var keys []string
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			params = append(params, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+//UploadFile uploads the file to the store, keyed by its base name.
+func UploadFile(store ObjectStore, fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return store.Upload(filepath.Base(fileName), f)
+}
+
+//UploadOnRoll returns a function to set as a JSONLExporter's OnRoll so that
+//every rolled over export file is uploaded to the store. With remove set, the
+//local file is deleted once it is uploaded. Failed uploads are logged and the
+//file is kept.
+func UploadOnRoll(store ObjectStore, logger *log.Logger, remove bool) func(fileName string) {
+	return func(fileName string) {
+		err := UploadFile(store, fileName)
+		if err != nil {
+			logger.Printf("uploading %s: %s", fileName, err)
+			return
+		}
+		if remove {
+			os.Remove(fileName)
+		}
+	}
+}
+
+//UploadBackup writes a backup of the database to a temporary file and uploads
+//it to the store under a key with the current time.
+func (s *Storage) UploadBackup(store ObjectStore) error {
+	dir, err := ioutil.TempDir("", "callosum-backup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	fileName := filepath.Join(dir, fmt.Sprintf("backup-%s.db", time.Now().UTC().Format("20060102T150405")))
+	err = s.Backup(fileName)
+	if err != nil {
+		return err
+	}
+	return UploadFile(store, fileName)
+}
@@ -0,0 +1,109 @@
+package callosum
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//The tasks besides the collection phases that requests to Twitter's API are
+//attributed to in the `api_usage` table
+const (
+	UsageCompliance = "compliance"
+	UsageProtected  = "protected"
+	UsagePlaces     = "places"
+	UsageTail       = "tail"
+	UsageHealth     = "health"
+)
+
+//APIUsage is the number of requests made to an endpoint of Twitter's API on
+//a day, in UTC, by a phase of a run of the collector, see WithRun. The phase
+//is one of the Phase constants or, for requests made outside the phases, one
+//of the Usage constants.
+type APIUsage struct {
+	Run      string `json:"run"`
+	Day      string `json:"day"`
+	Endpoint string `json:"endpoint"`
+	Phase    string `json:"phase,omitempty"`
+	Calls    int    `json:"calls"`
+}
+
+type phaseEndpoint struct {
+	phase    string
+	endpoint string
+}
+
+//usage keeps track of the requests not yet recorded in the `api_usage` table
+type usage struct {
+	mutex   sync.Mutex
+	pending map[phaseEndpoint]int
+}
+
+//countRequest counts a request the phase made to the endpoint, to be recorded
+//by recordAPIUsage. It is called where the request is made, since that is
+//where the phase is known: the same endpoint is requested by several phases.
+func (t *TwitterCollector) countRequest(phase, endpoint string) {
+	t.usage.mutex.Lock()
+	defer t.usage.mutex.Unlock()
+	if t.usage.pending == nil {
+		t.usage.pending = make(map[phaseEndpoint]int)
+	}
+	t.usage.pending[phaseEndpoint{phase, endpoint}]++
+}
+
+//countingGetter returns getter counting each page it requests from the
+//endpoint for the phase, see countRequest.
+func (t *TwitterCollector) countingGetter(phase, endpoint string, getter listGetter) listGetter {
+	return func(screenNameOrID interface{}, cursorID int64) ([]int64, int64, error) {
+		t.countRequest(phase, endpoint)
+		return getter(screenNameOrID, cursorID)
+	}
+}
+
+//recordAPIUsage adds the requests counted since the last time to the
+//`api_usage` table, for the day they are recorded on.
+func (t *TwitterCollector) recordAPIUsage() {
+	t.usage.mutex.Lock()
+	defer t.usage.mutex.Unlock()
+	day := time.Now().UTC().Format("2006-01-02")
+	for key, calls := range t.usage.pending {
+		t.s.AddAPIUsage(t.run, day, key.phase, key.endpoint, calls)
+	}
+	t.usage.pending = nil
+}
+
+//AddAPIUsage adds to the number of requests made to the endpoint on the day,
+//formatted as 2006-01-02, by the phase of the run in the `api_usage` table.
+func (s *Storage) AddAPIUsage(run, day, phase, endpoint string, calls int) {
+	chQueryArgs <- &queryArgs{`INSERT INTO api_usage (run, day, phase, endpoint, calls) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (run, day, phase, endpoint) DO UPDATE SET calls=calls+excluded.calls`,
+		[]interface{}{run, day, phase, endpoint, calls}}
+}
+
+//GetAPIUsage gets the requests made to each endpoint per day and phase by the
+//run from the `api_usage` table, or by every run when run is empty, ordered
+//by run, day, endpoint and phase.
+func (s *Storage) GetAPIUsage(run string) []APIUsage {
+	rows, err := s.db.Query(`SELECT run, day, endpoint, phase, calls FROM api_usage
+		WHERE ?='' OR run=? ORDER BY run, day, endpoint, phase`, run, run)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	usages := []APIUsage{}
+	for rows.Next() {
+		var u APIUsage
+		err = rows.Scan(&u.Run, &u.Day, &u.Endpoint, &u.Phase, &u.Calls)
+		if err != nil {
+			log.Fatal(err)
+		}
+		usages = append(usages, u)
+	}
+	return usages
+}
+
+func (s *Storage) serveUsage(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.GetAPIUsage(r.FormValue("run")))
+}
@@ -0,0 +1,30 @@
+package callosum
+
+import "encoding/json"
+
+//Verified is a FilterUser that accepts the users with a verified account,
+//going by the `verified` field of the user object. It is registered as the
+//"verified" filter.
+func Verified(blob []byte) bool {
+	var u struct {
+		Verified bool `json:"verified"`
+	}
+	if json.Unmarshal(blob, &u) != nil {
+		return false
+	}
+	return u.Verified
+}
+
+//Unverified is a FilterUser that accepts the users without a verified
+//account, the opposite of Verified. It is registered as the "unverified" filter.
+func Unverified(blob []byte) bool {
+	return !Verified(blob)
+}
+
+//GetVerifiedUserIDs gets the IDs of the users in the `users` table with a
+//verified account.
+func (s *Storage) GetVerifiedUserIDs() []int64 {
+	var results []int64
+	s.queryScreenNamesOrIDs("SELECT user_id FROM users WHERE verified=1 ORDER BY user_id", &results)
+	return results
+}
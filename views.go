@@ -0,0 +1,47 @@
+package callosum
+
+import "fmt"
+
+//StorageOption configures the database set up by NewStorage
+type StorageOption func(*Storage)
+
+//WithViews makes NewStorage create SQL views over the raw tables, for
+//analysts working on the SQLite file directly:
+//
+//	accepted_users            the users accepted by the filter function
+//	tweets_with_screen_names  tweets with their author's screen name, readable
+//	                          timestamps and the text column named text
+//	mutual_follows            pairs of users following each other, once per pair
+//	                          with the smaller ID as user_id
+//	follows                   every follow edge known from either the `following`
+//	                          or the `followers` table
+//
+//The views are created with the tables, when NewStorage opens the database.
+func WithViews() StorageOption {
+	return func(s *Storage) {
+		s.views = true
+	}
+}
+
+var views = []struct {
+	name, query string
+}{
+	{"accepted_users", `SELECT user_id, screen_name, description, protected, skip_reason, blob
+		FROM users WHERE accepted=1`},
+	{"tweets_with_screen_names", `SELECT tweets.tweet_id, tweets.user_id, users.screen_name,
+			datetime(tweets.created_at, 'unixepoch') AS created_at, tweets.langugage AS language,
+			tweets."desc" AS text, tweets.conversation_id, tweets.status, tweets.blob
+		FROM tweets LEFT JOIN users ON users.user_id = tweets.user_id`},
+	{"follows", `SELECT user_id AS follower_id, following_id AS followed_id FROM following
+		UNION
+		SELECT follower_id, user_id FROM followers`},
+	{"mutual_follows", `SELECT a.follower_id AS user_id, a.followed_id AS other_user_id
+		FROM follows a JOIN follows b ON b.follower_id = a.followed_id AND b.followed_id = a.follower_id
+		WHERE a.follower_id < a.followed_id`},
+}
+
+func (s *Storage) setupViews() {
+	for _, v := range views {
+		s.makeTable(v.name, fmt.Sprintf("CREATE VIEW IF NOT EXISTS %s AS %s", v.name, v.query))
+	}
+}